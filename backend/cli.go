@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"FATE-Vault/backend/routes"
+)
+
+// runCreateAdmin implements `create-admin -username NAME`. The password is read from
+// ADMIN_PASSWORD or, if unset, from the first line of stdin so it stays out of shell history.
+func runCreateAdmin(args []string) error {
+	fs := flag.NewFlagSet("create-admin", flag.ContinueOnError)
+	username := fs.String("username", "", "username of the admin account to create or promote")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *username == "" {
+		return errors.New("-username is required")
+	}
+
+	password := os.Getenv("ADMIN_PASSWORD")
+	if password == "" {
+		fmt.Fprint(os.Stderr, "Password: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("read password: %w", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+
+	policy, err := routes.LoadPasswordPolicyFromEnv()
+	if err != nil {
+		return err
+	}
+	routes.SetPasswordPolicy(policy)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := routes.CreateAdminUser(ctx, *username, password)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Admin user %q (%s) is ready\n", user.Username, user.ID)
+	return nil
+}
+
+// runSeedTemplates implements `seed-templates [-file PATH]`. Seeding is idempotent: templates
+// that did not change in the file are left alone, changed ones get a new version.
+func runSeedTemplates(args []string) error {
+	fs := flag.NewFlagSet("seed-templates", flag.ContinueOnError)
+	file := fs.String("file", "extra/templates.json", "JSON file with the shared templates")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	templates, err := routes.LoadTemplateFile(*file)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := routes.SeedTemplates(ctx, templates)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Templates: %d created, %d updated, %d unchanged\n", result.Created, result.Updated, result.Unchanged)
+	return nil
+}
@@ -52,5 +52,20 @@ func main() {
 		}
 	}()
 
+	if len(os.Args) > 1 && os.Args[1] == "create-admin" {
+		if err := runCreateAdmin(os.Args[2:]); err != nil {
+			log.Printf("create-admin: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "seed-templates" {
+		if err := runSeedTemplates(os.Args[2:]); err != nil {
+			log.Printf("seed-templates: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	server.Run("localhost:8080")
 }
@@ -2,9 +2,16 @@ package models
 
 import "time"
 
+// CharacterCategory is one node of a user's category tree. Nodes are stored as
+// separate documents linked by ParentID; Subcategories is only filled in when
+// the tree is assembled for a response (and in documents from before the tree
+// was flattened, which the category migration converts).
 type CharacterCategory struct {
-	ID   string `json:"_id" bson:"_id,omitempty"`
-	Name string `json:"name" bson:"name"`
+	ID       string `json:"_id" bson:"_id,omitempty"`
+	Name     string `json:"name" bson:"name"`
+	OwnerID  string `json:"ownerId,omitempty" bson:"ownerId,omitempty"`
+	ParentID string `json:"parentId,omitempty" bson:"parentId,omitempty"`
+	Position int    `json:"position" bson:"position"`
 
 	Subcategories []CharacterCategory `json:"subcategories,omitempty" bson:"subcategories,omitempty"`
 	CharacterIDs  []string            `json:"characterIds,omitempty" bson:"characterIds,omitempty"`
@@ -12,10 +12,12 @@ const (
 )
 
 type Aspect struct {
-	Type  string `json:"type" bson:"type"`   // e.g. "High Concept", "Trouble", or custom
+	Type  string `json:"type" bson:"type"`   // e.g. "highConcept", "trouble", or custom
 	Value string `json:"value" bson:"value"` // the aspect text
 }
 
+// Refresh is the character's fate points: Current is how many they have now and
+// Max the refresh they start each session with.
 type Refresh struct {
 	Current int `json:"current" bson:"current"`
 	Max     int `json:"max" bson:"max"`
@@ -38,15 +40,25 @@ type Stress struct {
 	Boxes []StressBox `json:"boxes" bson:"boxes"` // boxes array
 }
 
-// New: SkillGroup and Stunt types (slices only)
+// SkillGroup is the skills (or approaches) rated at one level. Skills from the
+// game vocabulary are stored by identifier, e.g. "fight"; custom skills by name.
 type SkillGroup struct {
 	Level  string   `json:"level" bson:"level"`
 	Skills []string `json:"skills" bson:"skills"`
 }
 
+// CharacterStunt is a stunt on a character sheet. It is either the character's
+// own, or links to a library stunt by StuntID and keeps a copy of its text and
+// mechanics. Override marks a linked stunt that was changed on the sheet; library
+// edits are not copied over it.
 type CharacterStunt struct {
-	Name        string `json:"name" bson:"name"`
-	Description string `json:"description" bson:"description"`
+	StuntID        string `json:"stuntId,omitempty" bson:"stuntId,omitempty"`
+	Name           string `json:"name" bson:"name"`
+	Description    string `json:"description" bson:"description"`
+	StuntMechanics `bson:",inline"`
+	Override       bool `json:"override,omitempty" bson:"override,omitempty"`
+	// Used counts uses since the counters were last reset for the stunt's limit.
+	Used int `json:"used,omitempty" bson:"used,omitempty"`
 }
 
 type Character struct {
@@ -65,8 +77,13 @@ type Character struct {
 	Stress       []Stress         `json:"stress" bson:"stress"`
 	Consequences []Consequence    `json:"consequences" bson:"consequences"`
 
-	IsPublished bool   `json:"isPublished" bson:"isPublished"`
-	CreatorID   string `json:"creatorId,omitempty" bson:"creatorId,omitempty"`
+	Tags        []string `json:"tags" bson:"tags"`
+	IsPublished bool     `json:"isPublished" bson:"isPublished"`
+	CreatorID   string   `json:"creatorId,omitempty" bson:"creatorId,omitempty"`
+
+	// The template and version the character was created from, if any.
+	TemplateID      string `json:"templateId,omitempty" bson:"templateId,omitempty"`
+	TemplateVersion int    `json:"templateVersion,omitempty" bson:"templateVersion,omitempty"`
 
 	CreatedAt time.Time `json:"createdAt,omitempty" bson:"createdAt,omitempty"`
 	UpdatedAt time.Time `json:"updatedAt,omitempty" bson:"updatedAt,omitempty"`
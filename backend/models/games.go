@@ -24,6 +24,11 @@ type Game struct {
 	Description         string       `json:"description" bson:"description"`
 	GameAspects         []GameAspect `json:"gameAspects" bson:"gameAspects"`
 	LinkedCharactersIds []string     `json:"linkedCharactersIds" bson:"linkedCharactersIds"`
+	Tags                []string     `json:"tags" bson:"tags"`
+
+	// OwnerID is the user who created the game. Only they can change it, which
+	// includes linking characters and so deciding who is a member.
+	OwnerID string `json:"ownerId,omitempty" bson:"ownerId,omitempty"`
 
 	CreatedAt time.Time `json:"createdAt,omitempty" bson:"createdAt,omitempty"`
 	UpdatedAt time.Time `json:"updatedAt,omitempty" bson:"updatedAt,omitempty"`
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// SavedSearch is a named character query that is re-run every time it is read,
+// so it works as a collection that keeps itself up to date.
+type SavedSearch struct {
+	ID      string `json:"_id" bson:"_id,omitempty"`
+	Name    string `json:"name" bson:"name"`
+	OwnerID string `json:"ownerId" bson:"ownerId"`
+	// Query holds /characters/find filter parameters in query-string form,
+	// e.g. "edition=accelerated&aspect=злодей&skill=Провокация:+3".
+	Query string `json:"query" bson:"query"`
+	// GameIDs shares the search with the members of these games.
+	GameIDs []string `json:"gameIds" bson:"gameIds"`
+
+	CreatedAt time.Time `json:"createdAt,omitempty" bson:"createdAt,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt,omitempty" bson:"updatedAt,omitempty"`
+}
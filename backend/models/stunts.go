@@ -2,11 +2,50 @@ package models
 
 import "time"
 
+// StuntAction is the action a stunt improves.
+type StuntAction string
+
+const (
+	OvercomeAction        StuntAction = "overcome"
+	CreateAdvantageAction StuntAction = "createAdvantage"
+	AttackAction          StuntAction = "attack"
+	DefendAction          StuntAction = "defend"
+)
+
+// StuntLimit is how often a limited stunt can be used.
+type StuntLimit string
+
+const (
+	UnlimitedStunt  StuntLimit = ""
+	PerSceneStunt   StuntLimit = "scene"
+	PerSessionStunt StuntLimit = "session"
+)
+
+// StuntMechanics is what a stunt does in play, for suggesting stunts on a roll
+// and counting their uses. The description stays the rules text.
+type StuntMechanics struct {
+	// Skill is the skill, or the approach in Accelerated, the stunt works with.
+	Skill  string      `json:"skill,omitempty" bson:"skill"`
+	Action StuntAction `json:"action,omitempty" bson:"action"`
+	// Bonus added to the roll, usually +2. Zero for stunts that change the rules
+	// rather than the roll.
+	Bonus      int    `json:"bonus,omitempty" bson:"bonus"`
+	Conditions string `json:"conditions,omitempty" bson:"conditions"`
+	// Limit and Uses: a stunt usable twice per session has Limit "session" and
+	// Uses 2.
+	Limit         StuntLimit `json:"limit,omitempty" bson:"limit"`
+	Uses          int        `json:"uses,omitempty" bson:"uses"`
+	FatePointCost int        `json:"fatePointCost,omitempty" bson:"fatePointCost"`
+}
+
+// Stunt is a stunt in the shared library. Characters link to it by ID.
 type Stunt struct {
-	ID          string  `json:"_id" bson:"_id,omitempty"`
-	Edition     Edition `json:"edition" bson:"edition"`
-	Name        string  `json:"name" bson:"name"`
-	Description string  `json:"description" bson:"description"`
+	ID             string  `json:"_id" bson:"_id,omitempty"`
+	Edition        Edition `json:"edition" bson:"edition"`
+	Name           string  `json:"name" bson:"name"`
+	Description    string  `json:"description" bson:"description"`
+	StuntMechanics `bson:",inline"`
+	Tags           []string `json:"tags" bson:"tags"`
 
 	CreatedAt time.Time `json:"createdAt,omitempty" bson:"createdAt,omitempty"`
 	UpdatedAt time.Time `json:"updatedAt,omitempty" bson:"updatedAt,omitempty"`
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Template is a blank character sheet that new characters start from. Shared
+// templates have no CreatorID and are managed by users who may write templates;
+// anyone can keep custom templates of their own.
+type Template struct {
+	Character `bson:",inline"`
+
+	Title string `json:"title" bson:"title"`
+	// Translations hold the title and description in other locales, keyed by
+	// locale. Skills and other game terms need none; they are stored as
+	// identifiers and named in the reader's locale.
+	Translations map[string]TemplateText `json:"translations,omitempty" bson:"translations,omitempty"`
+	// Key identifies a template seeded from extra/templates.json, normally its
+	// edition, so seeding again updates it instead of adding a copy.
+	Key string `json:"key,omitempty" bson:"key,omitempty"`
+	// Version counts edits, starting at 1. Earlier versions are kept as
+	// TemplateVersion documents.
+	Version int `json:"version" bson:"version"`
+}
+
+// TemplateText is the text of a template in one locale.
+type TemplateText struct {
+	Title       string `json:"title" bson:"title"`
+	Description string `json:"description,omitempty" bson:"description,omitempty"`
+}
+
+// TemplateVersion is a template as it was before an edit.
+type TemplateVersion struct {
+	ID         string    `json:"_id" bson:"_id,omitempty"`
+	TemplateID string    `json:"templateId" bson:"templateId"`
+	Version    int       `json:"version" bson:"version"`
+	Template   Template  `json:"template" bson:"template"`
+	ReplacedBy string    `json:"replacedBy,omitempty" bson:"replacedBy,omitempty"`
+	ReplacedAt time.Time `json:"replacedAt" bson:"replacedAt"`
+}
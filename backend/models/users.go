@@ -1,6 +1,7 @@
 package models
 
 import (
+	"sync"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -12,12 +13,33 @@ type Users struct {
 	Username       string `json:"username" bson:"username"`
 	HashedPassword string `json:"hashedPassword" bson:"hashedPassword"`
 	ProfilePicture string `json:"profilePicture,omitempty" bson:"profilePicture,omitempty"`
-	Role           string `json:"role" bson:"role" validate:"oneof=admin user"`
+	// Role names a role definition; see routes.Role for what each one may do.
+	Role string `json:"role" bson:"role" validate:"required"`
+	// Locale is the language the user reads sheets in; see package vocab.
+	Locale string `json:"locale,omitempty" bson:"locale,omitempty"`
+
+	// Two-factor authentication. Secrets and recovery code hashes never leave the server.
+	TOTPEnabled       bool     `json:"totpEnabled" bson:"totpEnabled,omitempty"`
+	TOTPSecret        string   `json:"-" bson:"totpSecret,omitempty"`
+	PendingTOTPSecret string   `json:"-" bson:"pendingTotpSecret,omitempty"`
+	TOTPLastStep      int64    `json:"-" bson:"totpLastStep,omitempty"`
+	RecoveryCodes     []string `json:"-" bson:"recoveryCodes,omitempty"`
+
+	// External OpenID Connect identities that can log in as this user.
+	Identities []ExternalIdentity `json:"identities,omitempty" bson:"identities,omitempty"`
 
 	CreatedAt time.Time `json:"createdAt,omitempty" bson:"createdAt,omitempty"`
 	UpdatedAt time.Time `json:"updatedAt,omitempty" bson:"updatedAt,omitempty"`
 }
 
+// ExternalIdentity links a subject at an OpenID provider to a user.
+type ExternalIdentity struct {
+	Issuer   string    `json:"issuer" bson:"issuer"`
+	Subject  string    `json:"subject" bson:"subject"`
+	Email    string    `json:"email,omitempty" bson:"email,omitempty"`
+	LinkedAt time.Time `json:"linkedAt" bson:"linkedAt"`
+}
+
 func (u *Users) SetPassword(plain string) error {
 	hash, err := bcrypt.GenerateFromPassword([]byte(plain), bcrypt.DefaultCost)
 	if err != nil {
@@ -36,3 +58,20 @@ func (u *Users) CheckPassword(plain string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(u.HashedPassword), []byte(plain))
 	return err == nil
 }
+
+// dummyPasswordHash is compared against when a login names an unknown user, so
+// the response takes as long as a real password check.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("not-a-real-password"), bcrypt.DefaultCost)
+	if err != nil {
+		panic("failed to generate dummy password hash: " + err.Error())
+	}
+	return hash
+})
+
+// CompareDummyPassword does the work of CheckPassword without a user. It always
+// fails and exists only to keep unknown usernames from being told apart by timing.
+func CompareDummyPassword(plain string) bool {
+	bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(plain))
+	return false
+}
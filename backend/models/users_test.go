@@ -39,3 +39,9 @@ func TestUsers_CheckPassword_EmptyHash(t *testing.T) {
 		t.Fatalf("CheckPassword should return false when HashedPassword is empty")
 	}
 }
+
+func TestCompareDummyPassword_AlwaysFails(t *testing.T) {
+	if CompareDummyPassword("not-a-real-password") {
+		t.Fatalf("CompareDummyPassword should never succeed")
+	}
+}
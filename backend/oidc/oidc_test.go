@@ -0,0 +1,117 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// authorize runs the browser leg of the flow against the mock and returns the callback query.
+func authorize(t *testing.T, authURL string) url.Values {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: expected redirect, got %d", resp.StatusCode)
+	}
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("parse redirect: %v", err)
+	}
+	return loc.Query()
+}
+
+func setup(t *testing.T) (*MockProvider, *Provider, Config) {
+	t.Helper()
+	mock, err := NewMockProvider("vault")
+	if err != nil {
+		t.Fatalf("NewMockProvider: %v", err)
+	}
+	t.Cleanup(mock.Close)
+
+	p, err := Discover(context.Background(), mock.Issuer(), nil)
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	return mock, p, Config{ClientID: "vault", RedirectURL: "http://app.test/callback", Scopes: []string{"openid", "email"}}
+}
+
+func TestAuthorizationCodeFlowWithPKCE(t *testing.T) {
+	mock, p, cfg := setup(t)
+	mock.SetUser(MockUser{Subject: "42", Email: "alice@example.com", PreferredUsername: "alice"})
+
+	state, _ := RandomString()
+	nonce, _ := RandomString()
+	verifier, _ := RandomString()
+	callback := authorize(t, p.AuthCodeURL(cfg, state, nonce, verifier))
+	if callback.Get("state") != state {
+		t.Fatalf("state not echoed back")
+	}
+
+	claims, err := p.Exchange(context.Background(), cfg, callback.Get("code"), verifier, nonce)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if claims.Subject != "42" || claims.Email != "alice@example.com" || claims.PreferredUsername != "alice" {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+}
+
+func TestExchange_RejectsWrongVerifierAndNonce(t *testing.T) {
+	_, p, cfg := setup(t)
+
+	nonce, _ := RandomString()
+	verifier, _ := RandomString()
+	callback := authorize(t, p.AuthCodeURL(cfg, "s", nonce, verifier))
+	if _, err := p.Exchange(context.Background(), cfg, callback.Get("code"), "wrong-verifier", nonce); err == nil {
+		t.Fatalf("expected PKCE failure")
+	}
+
+	callback = authorize(t, p.AuthCodeURL(cfg, "s", nonce, verifier))
+	if _, err := p.Exchange(context.Background(), cfg, callback.Get("code"), verifier, "other-nonce"); err == nil || !strings.Contains(err.Error(), "nonce") {
+		t.Fatalf("expected nonce mismatch, got %v", err)
+	}
+}
+
+func TestVerifyIDToken_ChecksClaims(t *testing.T) {
+	mock, p, cfg := setup(t)
+	now := time.Now()
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss": mock.Issuer(), "sub": "1", "aud": []string{"other", cfg.ClientID},
+			"exp": now.Add(time.Minute).Unix(), "nonce": "n",
+		}
+	}
+
+	token, _ := mock.SignIDToken(valid())
+	if _, err := p.VerifyIDToken(context.Background(), token, cfg.ClientID, "n", now); err != nil {
+		t.Fatalf("valid token rejected: %v", err)
+	}
+
+	for name, mutate := range map[string]func(map[string]interface{}){
+		"audience": func(c map[string]interface{}) { c["aud"] = "someone-else" },
+		"issuer":   func(c map[string]interface{}) { c["iss"] = "https://evil.example" },
+		"expired":  func(c map[string]interface{}) { c["exp"] = now.Add(-time.Hour).Unix() },
+		"subject":  func(c map[string]interface{}) { delete(c, "sub") },
+	} {
+		claims := valid()
+		mutate(claims)
+		token, _ := mock.SignIDToken(claims)
+		if _, err := p.VerifyIDToken(context.Background(), token, cfg.ClientID, "n", now); err == nil {
+			t.Errorf("%s: expected rejection", name)
+		}
+	}
+
+	token, _ = mock.SignIDToken(valid())
+	tampered := token[:len(token)-4] + "AAAA"
+	if _, err := p.VerifyIDToken(context.Background(), tampered, cfg.ClientID, "n", now); err == nil {
+		t.Errorf("tampered signature accepted")
+	}
+}
@@ -0,0 +1,323 @@
+package resp
+
+import (
+	"bufio"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeServer is an in-process server implementing the subset of Redis commands
+// the backend relies on. It exists so Redis-backed stores can be tested without
+// a real server.
+type FakeServer struct {
+	listener net.Listener
+
+	mu      sync.Mutex
+	strings map[string]string
+	sets    map[string]map[string]struct{}
+	expires map[string]time.Time
+}
+
+// NewFakeServer starts a fake server on a random local port.
+func NewFakeServer() (*FakeServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &FakeServer{
+		listener: listener,
+		strings:  make(map[string]string),
+		sets:     make(map[string]map[string]struct{}),
+		expires:  make(map[string]time.Time),
+	}
+	go s.serve()
+
+	return s, nil
+}
+
+// Addr is the host:port the server listens on.
+func (s *FakeServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *FakeServer) Close() error {
+	return s.listener.Close()
+}
+
+// FastForward expires keys as if d had passed.
+func (s *FakeServer) FastForward(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, at := range s.expires {
+		s.expires[key] = at.Add(-d)
+	}
+}
+
+func (s *FakeServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *FakeServer) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		reply, err := readReply(r)
+		if err != nil {
+			return
+		}
+		items, ok := reply.([]interface{})
+		if !ok || len(items) == 0 {
+			conn.Write([]byte("-ERR expected command array\r\n"))
+			continue
+		}
+		args := make([]string, len(items))
+		for i, item := range items {
+			args[i], _ = item.(string)
+		}
+		conn.Write(s.exec(args))
+	}
+}
+
+func (s *FakeServer) expireLocked(key string) {
+	if at, ok := s.expires[key]; ok && !time.Now().Before(at) {
+		delete(s.strings, key)
+		delete(s.sets, key)
+		delete(s.expires, key)
+	}
+}
+
+func (s *FakeServer) existsLocked(key string) bool {
+	s.expireLocked(key)
+	_, isString := s.strings[key]
+	_, isSet := s.sets[key]
+	return isString || isSet
+}
+
+func (s *FakeServer) exec(args []string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cmd := strings.ToUpper(args[0])
+	args = args[1:]
+
+	switch cmd {
+	case "PING":
+		return simple("PONG")
+	case "AUTH", "SELECT":
+		return simple("OK")
+	case "GET":
+		if len(args) != 1 {
+			return wrongArgs(cmd)
+		}
+		s.expireLocked(args[0])
+		v, ok := s.strings[args[0]]
+		if !ok {
+			return nilBulk()
+		}
+		return bulk(v)
+	case "SET":
+		if len(args) < 2 {
+			return wrongArgs(cmd)
+		}
+		key, value := args[0], args[1]
+		var ttl time.Duration
+		nx, xx := false, false
+		for i := 2; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				nx = true
+			case "XX":
+				xx = true
+			case "EX", "PX":
+				if i+1 >= len(args) {
+					return wrongArgs(cmd)
+				}
+				n, err := strconv.ParseInt(args[i+1], 10, 64)
+				if err != nil {
+					return errReply("ERR value is not an integer or out of range")
+				}
+				if strings.ToUpper(args[i]) == "EX" {
+					ttl = time.Duration(n) * time.Second
+				} else {
+					ttl = time.Duration(n) * time.Millisecond
+				}
+				i++
+			}
+		}
+		if nx && s.existsLocked(key) || xx && !s.existsLocked(key) {
+			return nilBulk()
+		}
+		delete(s.sets, key)
+		s.strings[key] = value
+		delete(s.expires, key)
+		if ttl > 0 {
+			s.expires[key] = time.Now().Add(ttl)
+		}
+		return simple("OK")
+	case "DEL":
+		var n int64
+		for _, key := range args {
+			if s.existsLocked(key) {
+				n++
+			}
+			delete(s.strings, key)
+			delete(s.sets, key)
+			delete(s.expires, key)
+		}
+		return integer(n)
+	case "EXISTS":
+		var n int64
+		for _, key := range args {
+			if s.existsLocked(key) {
+				n++
+			}
+		}
+		return integer(n)
+	case "INCR", "INCRBY":
+		if len(args) < 1 {
+			return wrongArgs(cmd)
+		}
+		by := int64(1)
+		if cmd == "INCRBY" {
+			if len(args) != 2 {
+				return wrongArgs(cmd)
+			}
+			var err error
+			if by, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+				return errReply("ERR value is not an integer or out of range")
+			}
+		}
+		s.expireLocked(args[0])
+		n, _ := strconv.ParseInt(s.strings[args[0]], 10, 64)
+		n += by
+		s.strings[args[0]] = strconv.FormatInt(n, 10)
+		return integer(n)
+	case "EXPIRE", "PEXPIRE":
+		if len(args) != 2 {
+			return wrongArgs(cmd)
+		}
+		n, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return errReply("ERR value is not an integer or out of range")
+		}
+		if !s.existsLocked(args[0]) {
+			return integer(0)
+		}
+		d := time.Duration(n) * time.Millisecond
+		if cmd == "EXPIRE" {
+			d = time.Duration(n) * time.Second
+		}
+		s.expires[args[0]] = time.Now().Add(d)
+		return integer(1)
+	case "PTTL":
+		if len(args) != 1 {
+			return wrongArgs(cmd)
+		}
+		if !s.existsLocked(args[0]) {
+			return integer(-2)
+		}
+		at, ok := s.expires[args[0]]
+		if !ok {
+			return integer(-1)
+		}
+		return integer(int64(time.Until(at) / time.Millisecond))
+	case "SADD":
+		if len(args) < 2 {
+			return wrongArgs(cmd)
+		}
+		s.expireLocked(args[0])
+		set, ok := s.sets[args[0]]
+		if !ok {
+			set = make(map[string]struct{})
+			s.sets[args[0]] = set
+		}
+		var n int64
+		for _, member := range args[1:] {
+			if _, exists := set[member]; !exists {
+				set[member] = struct{}{}
+				n++
+			}
+		}
+		return integer(n)
+	case "SREM":
+		if len(args) < 2 {
+			return wrongArgs(cmd)
+		}
+		s.expireLocked(args[0])
+		set := s.sets[args[0]]
+		var n int64
+		for _, member := range args[1:] {
+			if _, exists := set[member]; exists {
+				delete(set, member)
+				n++
+			}
+		}
+		if set != nil && len(set) == 0 {
+			delete(s.sets, args[0])
+		}
+		return integer(n)
+	case "SMEMBERS":
+		if len(args) != 1 {
+			return wrongArgs(cmd)
+		}
+		s.expireLocked(args[0])
+		members := make([]string, 0, len(s.sets[args[0]]))
+		for member := range s.sets[args[0]] {
+			members = append(members, member)
+		}
+		sort.Strings(members)
+		return array(members)
+	case "KEYS":
+		if len(args) != 1 {
+			return wrongArgs(cmd)
+		}
+		prefix := strings.TrimSuffix(args[0], "*")
+		var keys []string
+		for key := range s.strings {
+			if strings.HasPrefix(key, prefix) && s.existsLocked(key) {
+				keys = append(keys, key)
+			}
+		}
+		for key := range s.sets {
+			if strings.HasPrefix(key, prefix) && s.existsLocked(key) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		return array(keys)
+	default:
+		return errReply("ERR unknown command '" + cmd + "'")
+	}
+}
+
+func simple(s string) []byte   { return []byte("+" + s + "\r\n") }
+func errReply(s string) []byte { return []byte("-" + s + "\r\n") }
+func integer(n int64) []byte   { return []byte(":" + strconv.FormatInt(n, 10) + "\r\n") }
+func nilBulk() []byte          { return []byte("$-1\r\n") }
+
+func bulk(s string) []byte {
+	return []byte("$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n")
+}
+
+func array(items []string) []byte {
+	buf := []byte("*" + strconv.Itoa(len(items)) + "\r\n")
+	for _, item := range items {
+		buf = append(buf, bulk(item)...)
+	}
+	return buf
+}
+
+func wrongArgs(cmd string) []byte {
+	return errReply("ERR wrong number of arguments for '" + strings.ToLower(cmd) + "' command")
+}
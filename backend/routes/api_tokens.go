@@ -0,0 +1,322 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"FATE-Vault/backend/db"
+	"FATE-Vault/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Scopes a personal API token can be granted. Requests made with a session cookie
+// are not scoped; they can do whatever the user's role allows. RequirePermission
+// checks the scope a permission needs (see tokenScopeFor).
+const (
+	ScopeCharactersRead  = "characters:read"
+	ScopeCharactersWrite = "characters:write"
+	// ScopeDiceRoll lets a dice bot spend and reset stunt uses while rolling,
+	// without being able to edit the sheets otherwise.
+	ScopeDiceRoll = "dice:roll"
+	ScopeAdmin    = "admin"
+)
+
+var apiTokenScopes = []string{ScopeCharactersRead, ScopeCharactersWrite, ScopeDiceRoll, ScopeAdmin}
+
+// apiTokenPrefix marks the tokens so they are easy to recognise in logs and
+// secret scanners.
+const apiTokenPrefix = "fvt_"
+
+const maxAPITokensPerUser = 50
+
+// APIToken is a personal access token for scripts and bots. Only the hash of the
+// token is stored; the token itself is shown once when it is created.
+type APIToken struct {
+	ID         string     `json:"id" bson:"_id"`
+	UserID     string     `json:"-" bson:"userId"`
+	Name       string     `json:"name" bson:"name"`
+	TokenHash  string     `json:"-" bson:"tokenHash"`
+	Scopes     []string   `json:"scopes" bson:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt" bson:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty" bson:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty" bson:"lastUsedAt,omitempty"`
+}
+
+type CreateAPITokenRequest struct {
+	Name   string   `json:"name" binding:"required,max=100"`
+	Scopes []string `json:"scopes" binding:"required,min=1"`
+	// ExpiresIn is a duration such as 720h, or "never". Defaults to API_TOKEN_TTL.
+	ExpiresIn string `json:"expiresIn,omitempty"`
+}
+
+func apiTokenTTL() time.Duration {
+	return envDuration("API_TOKEN_TTL", 90*24*time.Hour)
+}
+
+func apiTokensCollection() *mongo.Collection {
+	return db.Client.Database("main").Collection("api_tokens")
+}
+
+func (t *APIToken) hasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func validAPITokenScope(scope string) bool {
+	for _, s := range apiTokenScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// bearerToken returns the token from an "Authorization: Bearer" header, if any.
+func bearerToken(c *gin.Context) (string, bool) {
+	header := c.GetHeader("Authorization")
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// UserFromAPIToken loads the user that owns a personal API token and records that
+// the token was used.
+func UserFromAPIToken(ctx context.Context, raw string) (*models.Users, *APIToken, error) {
+	if db.Client == nil {
+		return nil, nil, mongo.ErrClientDisconnected
+	}
+	if !strings.HasPrefix(raw, apiTokenPrefix) {
+		return nil, nil, mongo.ErrNoDocuments
+	}
+
+	var token APIToken
+	if err := apiTokensCollection().FindOne(ctx, bson.M{"tokenHash": hashSecretToken(raw)}).Decode(&token); err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	if token.ExpiresAt != nil && now.After(*token.ExpiresAt) {
+		return nil, nil, mongo.ErrNoDocuments
+	}
+
+	var user models.Users
+	if err := usersCollection().FindOne(ctx, bson.M{"_id": token.UserID}).Decode(&user); err != nil {
+		return nil, nil, err
+	}
+
+	// Last-used is informational, so a minute of precision saves a write per request.
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > time.Minute {
+		token.LastUsedAt = &now
+		if _, err := apiTokensCollection().UpdateOne(ctx, bson.M{"_id": token.ID}, bson.M{"$set": bson.M{"lastUsedAt": now}}); err != nil {
+			return nil, nil, err
+		}
+	}
+	return &user, &token, nil
+}
+
+// currentAPIToken returns the token the request authenticated with, or nil for
+// cookie sessions.
+func currentAPIToken(c *gin.Context) *APIToken {
+	v, _ := c.Get("apiToken")
+	token, _ := v.(*APIToken)
+	return token
+}
+
+// canUseAdminScope reports whether the caller's role grants any permission that
+// tokens need the admin scope for. Nobody else could use the scope.
+func canUseAdminScope(ctx context.Context, c *gin.Context) (bool, error) {
+	for _, p := range allPermissions {
+		if tokenScopeFor(p) != ScopeAdmin {
+			continue
+		}
+		ok, err := hasPermission(ctx, c, p)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// RequireSession rejects API tokens on account management routes, so a leaked
+// token cannot be used to mint more tokens, change the password or disable 2FA.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if currentAPIToken(c) != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "this endpoint requires a session login"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// ListMyTokens lists the caller's personal API tokens, newest first.
+func ListMyTokens(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	cur, err := apiTokensCollection().Find(ctx, bson.M{"userId": currentUserID(c)}, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list tokens: " + err.Error()})
+		return
+	}
+	defer cur.Close(ctx)
+
+	tokens := []APIToken{}
+	if err := cur.All(ctx, &tokens); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to decode tokens: " + err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, tokens)
+}
+
+// CreateMyToken issues a personal API token. The token is returned once.
+func CreateMyToken(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var req CreateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scopes := make([]string, 0, len(req.Scopes))
+	seen := make(map[string]bool)
+	for _, s := range req.Scopes {
+		if !validAPITokenScope(s) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown scope " + s + "; valid scopes are " + strings.Join(apiTokenScopes, ", ")})
+			return
+		}
+		if !seen[s] {
+			seen[s] = true
+			scopes = append(scopes, s)
+		}
+	}
+	if seen[ScopeAdmin] {
+		ok, err := canUseAdminScope(ctx, c)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load roles: " + err.Error()})
+			return
+		}
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "your role has no permissions that need the admin scope"})
+			return
+		}
+	}
+
+	now := time.Now()
+	var expiresAt *time.Time
+	switch req.ExpiresIn {
+	case "never":
+	case "":
+		t := now.Add(apiTokenTTL())
+		expiresAt = &t
+	default:
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expiresIn must be a positive duration such as 720h, or \"never\""})
+			return
+		}
+		t := now.Add(d)
+		expiresAt = &t
+	}
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
+	}
+
+	userID := currentUserID(c)
+	count, err := apiTokensCollection().CountDocuments(ctx, bson.M{"userId": userID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count tokens: " + err.Error()})
+		return
+	}
+	if count >= maxAPITokensPerUser {
+		c.JSON(http.StatusConflict, gin.H{"error": "too many tokens; delete one first"})
+		return
+	}
+
+	secret, err := generateSecretToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token: " + err.Error()})
+		return
+	}
+	raw := apiTokenPrefix + secret
+
+	// Tokens without an expiry are never matched by the TTL index.
+	_, err = apiTokensCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tokenHash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "userId", Value: 1}}},
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to prepare tokens: " + err.Error()})
+		return
+	}
+
+	token := APIToken{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Name:      strings.TrimSpace(req.Name),
+		TokenHash: hashSecretToken(raw),
+		Scopes:    scopes,
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+	}
+	if _, err := apiTokensCollection().InsertOne(ctx, token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create token: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"token": token, "secret": raw})
+}
+
+// DeleteMyToken revokes one of the caller's personal API tokens.
+func DeleteMyToken(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id parameter is required"})
+		return
+	}
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
+	}
+
+	result, err := apiTokensCollection().DeleteOne(ctx, bson.M{"_id": id, "userId": currentUserID(c)})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete token: " + err.Error()})
+		return
+	}
+	if result.DeletedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "token not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
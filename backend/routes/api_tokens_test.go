@@ -0,0 +1,155 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBearerToken(t *testing.T) {
+	cases := map[string]struct {
+		header string
+		token  string
+		ok     bool
+	}{
+		"bearer":       {"Bearer fvt_abc", "fvt_abc", true},
+		"lowercase":    {"bearer fvt_abc", "fvt_abc", true},
+		"basic":        {"Basic dXNlcjpwYXNz", "", false},
+		"missing":      {"", "", false},
+		"no separator": {"Bearerfvt_abc", "", false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.header != "" {
+				c.Request.Header.Set("Authorization", tc.header)
+			}
+			token, ok := bearerToken(c)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.token, token)
+		})
+	}
+}
+
+// setupTokenRouter authenticates every request with the given role, as the given
+// token or as a cookie session when token is nil.
+func setupTokenRouter(role string, token *APIToken) *gin.Engine {
+	roles.invalidate()
+
+	router := setupRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set("userId", "user-1")
+		c.Set("role", role)
+		if token != nil {
+			c.Set("apiToken", token)
+		}
+		c.Next()
+	})
+	ok := func(c *gin.Context) { c.Status(http.StatusNoContent) }
+	router.GET("/characters", RequirePermission(PermCharactersRead), ok)
+	router.POST("/characters/create", RequirePermission(PermCharactersWrite), ok)
+	router.POST("/characters/roll", RequirePermission(PermCharactersWrite, ScopeDiceRoll), ok)
+	router.GET("/account", RequireSession(), ok)
+	router.POST("/stunts/create", RequirePermission(PermStuntsWrite), ok)
+	router.GET("/admin", RequirePermission(PermStorageManage), ok)
+	router.POST("/users/me/tokens", CreateMyToken)
+	router.GET("/admin/roles", ListRoles)
+	router.POST("/admin/roles", SaveRole)
+	router.POST("/admin/roles/:id", SaveRole)
+	router.DELETE("/admin/roles/:id", DeleteRole)
+	router.POST("/users/update/:id", UpdateUser)
+	return router
+}
+
+func serve(router *gin.Engine, method, url string, body interface{}) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, createTestRequest(method, url, body))
+	return w
+}
+
+func TestRequirePermission_ChecksTokenScope(t *testing.T) {
+	readOnly := &APIToken{Scopes: []string{ScopeCharactersRead}}
+	writeOnly := &APIToken{Scopes: []string{ScopeCharactersWrite}}
+
+	assert.Equal(t, http.StatusNoContent, serve(setupTokenRouter("user", nil), http.MethodGet, "/characters", nil).Code)
+	assert.Equal(t, http.StatusNoContent, serve(setupTokenRouter("user", readOnly), http.MethodGet, "/characters", nil).Code)
+	assert.Equal(t, http.StatusForbidden, serve(setupTokenRouter("user", writeOnly), http.MethodGet, "/characters", nil).Code)
+
+	assert.Equal(t, http.StatusNoContent, serve(setupTokenRouter("user", nil), http.MethodPost, "/characters/create", nil).Code)
+	assert.Equal(t, http.StatusNoContent, serve(setupTokenRouter("user", writeOnly), http.MethodPost, "/characters/create", nil).Code)
+	assert.Equal(t, http.StatusForbidden, serve(setupTokenRouter("user", readOnly), http.MethodPost, "/characters/create", nil).Code)
+	assert.Equal(t, http.StatusForbidden, serve(setupTokenRouter("viewer", writeOnly), http.MethodPost, "/characters/create", nil).Code)
+}
+
+func TestRequirePermission_DiceRollScope(t *testing.T) {
+	diceOnly := &APIToken{Scopes: []string{ScopeDiceRoll}}
+
+	assert.Equal(t, http.StatusNoContent, serve(setupTokenRouter("user", diceOnly), http.MethodPost, "/characters/roll", nil).Code)
+	assert.Equal(t, http.StatusNoContent, serve(setupTokenRouter("user", &APIToken{Scopes: []string{ScopeCharactersWrite}}), http.MethodPost, "/characters/roll", nil).Code)
+	assert.Equal(t, http.StatusForbidden, serve(setupTokenRouter("user", &APIToken{Scopes: []string{ScopeCharactersRead}}), http.MethodPost, "/characters/roll", nil).Code)
+	assert.Equal(t, http.StatusForbidden, serve(setupTokenRouter("viewer", diceOnly), http.MethodPost, "/characters/roll", nil).Code)
+	assert.Equal(t, http.StatusForbidden, serve(setupTokenRouter("user", diceOnly), http.MethodPost, "/characters/create", nil).Code)
+	assert.Equal(t, http.StatusForbidden, serve(setupTokenRouter("user", diceOnly), http.MethodGet, "/characters", nil).Code)
+}
+
+func TestOptionalUserID_BearerToken(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/characters", nil)
+	c.Request.Header.Set("Authorization", "Bearer fvt_unknown")
+
+	// Unknown tokens leave the caller anonymous, and the answer depends on the header.
+	assert.Equal(t, "", optionalUserID(c))
+	assert.Contains(t, c.Writer.Header().Values("Vary"), "Authorization")
+}
+
+func TestRequireSession_RejectsTokens(t *testing.T) {
+	assert.Equal(t, http.StatusNoContent, serve(setupTokenRouter("user", nil), http.MethodGet, "/account", nil).Code)
+	assert.Equal(t, http.StatusForbidden, serve(setupTokenRouter("user", &APIToken{Scopes: apiTokenScopes}), http.MethodGet, "/account", nil).Code)
+}
+
+func TestAdminPermission_TokenNeedsAdminScope(t *testing.T) {
+	assert.Equal(t, http.StatusNoContent, serve(setupTokenRouter("admin", nil), http.MethodGet, "/admin", nil).Code)
+	assert.Equal(t, http.StatusNoContent, serve(setupTokenRouter("admin", &APIToken{Scopes: []string{ScopeAdmin}}), http.MethodGet, "/admin", nil).Code)
+	assert.Equal(t, http.StatusForbidden, serve(setupTokenRouter("admin", &APIToken{Scopes: []string{ScopeCharactersWrite}}), http.MethodGet, "/admin", nil).Code)
+	assert.Equal(t, http.StatusForbidden, serve(setupTokenRouter("user", &APIToken{Scopes: []string{ScopeAdmin}}), http.MethodGet, "/admin", nil).Code)
+}
+
+func TestCreateMyToken_Validation(t *testing.T) {
+	router := setupTokenRouter("user", nil)
+
+	w := serve(router, http.MethodPost, "/users/me/tokens", map[string]interface{}{"name": "bot", "scopes": []string{"characters:delete"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "unknown scope")
+
+	w = serve(router, http.MethodPost, "/users/me/tokens", map[string]interface{}{"name": "bot", "scopes": []string{ScopeAdmin}})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = serve(router, http.MethodPost, "/users/me/tokens", map[string]interface{}{"name": "bot", "scopes": []string{ScopeCharactersRead}, "expiresIn": "-1h"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(router, http.MethodPost, "/users/me/tokens", map[string]interface{}{"name": "bot"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Valid requests get as far as the database.
+	w = serve(router, http.MethodPost, "/users/me/tokens", map[string]interface{}{"name": "bot", "scopes": []string{ScopeCharactersRead, ScopeDiceRoll}, "expiresIn": "never"})
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "database connection not available")
+}
+
+func TestAuthMiddleware_RejectsInvalidBearerToken(t *testing.T) {
+	router := setupRouter()
+	router.GET("/users/me", AuthMiddleware(), GetCurrentUser)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+	req.Header.Set("Authorization", "Bearer fvt_unknown")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Header().Get("WWW-Authenticate"), "invalid_token")
+	assert.Empty(t, w.Result().Cookies())
+}
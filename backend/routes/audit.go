@@ -0,0 +1,38 @@
+package routes
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"FATE-Vault/backend/db"
+
+	"github.com/google/uuid"
+)
+
+// AuditEvent records a security-relevant event such as an account lockout.
+type AuditEvent struct {
+	ID        string                 `json:"id" bson:"_id"`
+	Type      string                 `json:"type" bson:"type"`
+	UserID    string                 `json:"userId,omitempty" bson:"userId,omitempty"`
+	Username  string                 `json:"username,omitempty" bson:"username,omitempty"`
+	IP        string                 `json:"ip,omitempty" bson:"ip,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty" bson:"details,omitempty"`
+	CreatedAt time.Time              `json:"createdAt" bson:"createdAt"`
+}
+
+// recordAuditEvent logs the event and stores it in the "audit_events" collection
+// when a database is available. Failures are logged, never returned: auditing must
+// not break the request that triggered it.
+func recordAuditEvent(ctx context.Context, event AuditEvent) {
+	event.ID = uuid.NewString()
+	event.CreatedAt = time.Now()
+	log.Printf("audit: %s user=%q ip=%s details=%v", event.Type, event.Username, event.IP, event.Details)
+
+	if db.Client == nil {
+		return
+	}
+	if _, err := db.Client.Database("main").Collection("audit_events").InsertOne(ctx, event); err != nil {
+		log.Printf("audit: failed to store %s event: %v", event.Type, err)
+	}
+}
@@ -2,6 +2,7 @@ package routes
 
 import (
 	"context"
+	"log"
 	"net/http"
 	"time"
 
@@ -19,13 +20,16 @@ func UserFromSessionID(ctx context.Context, sessionID string) (*models.Users, *S
 		return nil, nil, mongo.ErrClientDisconnected
 	}
 	coll := db.Client.Database("main").Collection("users")
-	session := sessionManager.ReadValid(sessionID)
+	session, err := sessionManager.ReadValid(ctx, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
 	if session == nil {
 		return nil, nil, mongo.ErrNoDocuments
 	}
 	filter := bson.M{"_id": session.UserID}
 	var user models.Users
-	err := coll.FindOne(ctx, filter).Decode(&user)
+	err = coll.FindOne(ctx, filter).Decode(&user)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -38,6 +42,11 @@ func AuthMiddleware() gin.HandlerFunc {
 		c.Writer.Header().Add("Vary", "Cookie")
 		c.Writer.Header().Add("Cache-Control", `no-cache="Set-Cookie"`)
 
+		if raw, ok := bearerToken(c); ok {
+			authenticateAPIToken(c, raw)
+			return
+		}
+
 		sessionID := sessionIDFromRequest(c)
 		if sessionID == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
@@ -60,13 +69,41 @@ func AuthMiddleware() gin.HandlerFunc {
 		c.Set("username", user.Username)
 		c.Set("role", user.Role)
 		c.Set("user", *user)
-		sessionManager.Touch(session)
+		c.Set("sessionId", session.ID)
+		if err := sessionManager.Touch(ctx, session); err != nil {
+			log.Printf("session touch error: %v", err)
+		}
 		setSessionCookie(c, session.ID)
 
 		c.Next()
 	}
 }
 
+// authenticateAPIToken handles requests that carry a personal API token instead
+// of a session cookie. No session is touched and no cookie is set.
+func authenticateAPIToken(c *gin.Context, raw string) {
+	c.Writer.Header().Add("Vary", "Authorization")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, token, err := UserFromAPIToken(ctx, raw)
+	if err != nil {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+		c.Abort()
+		return
+	}
+
+	c.Set("userId", user.ID)
+	c.Set("username", user.Username)
+	c.Set("role", user.Role)
+	c.Set("user", *user)
+	c.Set("apiToken", token)
+
+	c.Next()
+}
+
 func GetUserFromContext(c *gin.Context) (*models.Users, bool) {
 	user, exists := c.Get("user")
 	if !exists {
@@ -78,31 +115,3 @@ func GetUserFromContext(c *gin.Context) (*models.Users, bool) {
 	}
 	return &userModel, true
 }
-
-func RequireAdmin() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		role, exists := c.Get("role")
-		if !exists || role != "admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "admin access required"})
-			c.Abort()
-			return
-		}
-		c.Next()
-	}
-}
-
-func RequireAdminOrOwner(paramName string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		role, _ := c.Get("role")
-		userId, _ := c.Get("userId")
-		resourceId := c.Param(paramName)
-
-		if role == "admin" || userId == resourceId {
-			c.Next()
-			return
-		}
-
-		c.JSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
-		c.Abort()
-	}
-}
@@ -2,7 +2,11 @@ package routes
 
 import (
 	"context"
+	"errors"
+	"log"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"FATE-Vault/backend/db"
@@ -11,29 +15,462 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func CreateCategory(c *gin.Context) {
+// maxCategoryDepth bounds how deeply categories can nest.
+const maxCategoryDepth = 10
+
+type CategoryRequest struct {
+	Name     string `json:"name" binding:"required,max=100"`
+	ParentID string `json:"parentId,omitempty"`
+}
+
+type MoveCategoryRequest struct {
+	// ParentID is the new parent; empty moves the category to the top level.
+	ParentID string `json:"parentId"`
+	// Position among the new siblings; defaults to the end.
+	Position *int `json:"position,omitempty"`
+}
+
+type ReorderCategoriesRequest struct {
+	ParentID string   `json:"parentId"`
+	Order    []string `json:"order" binding:"required"`
+}
+
+type CategoryCharactersRequest struct {
+	CharacterIDs []string `json:"characterIds" binding:"required,min=1"`
+}
+
+// CategoryNode is a category in the assembled tree, with how many characters it
+// holds directly and including its subcategories.
+type CategoryNode struct {
+	ID             string         `json:"_id"`
+	Name           string         `json:"name"`
+	ParentID       string         `json:"parentId,omitempty"`
+	Position       int            `json:"position"`
+	CharacterIDs   []string       `json:"characterIds"`
+	CharacterCount int            `json:"characterCount"`
+	TotalCount     int            `json:"totalCount"`
+	Subcategories  []CategoryNode `json:"subcategories"`
+}
+
+var errCategoryNotFound = errors.New("category not found")
+
+var errCategoriesBusy = errors.New("your categories are being changed by another request; try again")
+
+// categoryLockTTL is how long a category lock is held at most. It outlives the
+// request timeout, so a lock is never taken over while its holder still writes.
+const categoryLockTTL = 15 * time.Second
+
+func categoriesCollection() *mongo.Collection {
+	return db.Client.Database("main").Collection("categories")
+}
+
+func categoryLocksCollection() *mongo.Collection {
+	return db.Client.Database("main").Collection("categoryLocks")
+}
+
+// lockCategories takes the owner's category lock for as long as the returned
+// function is not called. Every handler that checks a snapshot of the tree
+// (names, parents, cycles, depth, sibling order) before writing holds it, so no
+// other request changes the tree in between. A lock whose holder died expires
+// after categoryLockTTL.
+func lockCategories(ctx context.Context, ownerID string) (func(), error) {
+	token := uuid.NewString()
+	now := time.Now()
+	filter := bson.M{
+		"_id": ownerID,
+		"$or": bson.A{
+			bson.M{"expiresAt": bson.M{"$exists": false}},
+			bson.M{"expiresAt": bson.M{"$lte": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"token": token, "expiresAt": now.Add(categoryLockTTL)}}
+	_, err := categoryLocksCollection().UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// The lock document exists and is held, so the upsert tried to insert it again.
+		return nil, errCategoriesBusy
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		unlock := bson.M{"$unset": bson.M{"token": "", "expiresAt": ""}}
+		if _, err := categoryLocksCollection().UpdateOne(ctx, bson.M{"_id": ownerID, "token": token}, unlock); err != nil {
+			log.Printf("failed to release category lock of %s: %v", ownerID, err)
+		}
+	}, nil
+}
+
+// respondLockError writes 409 when the categories are locked by another request.
+func respondLockError(c *gin.Context, err error) {
+	if errors.Is(err, errCategoriesBusy) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to lock categories: " + err.Error()})
+}
+
+// parentValue is how a parent ID is stored: top-level categories have none.
+func parentValue(parentID string) interface{} {
+	if parentID == "" {
+		return nil
+	}
+	return parentID
+}
+
+// categoryForest is a set of categories indexed for tree operations.
+type categoryForest struct {
+	byID     map[string]*models.CharacterCategory
+	children map[string][]*models.CharacterCategory
+}
+
+func newCategoryForest(categories []models.CharacterCategory) *categoryForest {
+	f := &categoryForest{
+		byID:     make(map[string]*models.CharacterCategory, len(categories)),
+		children: make(map[string][]*models.CharacterCategory),
+	}
+	for i := range categories {
+		f.byID[categories[i].ID] = &categories[i]
+	}
+	for _, cat := range f.byID {
+		parent := cat.ParentID
+		if _, ok := f.byID[parent]; !ok {
+			// Orphans of a deleted parent show up at the top level.
+			parent = ""
+		}
+		f.children[parent] = append(f.children[parent], cat)
+	}
+	for _, siblings := range f.children {
+		sort.Slice(siblings, func(i, j int) bool {
+			if siblings[i].Position != siblings[j].Position {
+				return siblings[i].Position < siblings[j].Position
+			}
+			return siblings[i].Name < siblings[j].Name
+		})
+	}
+	return f
+}
+
+func loadCategoryForest(ctx context.Context, filter bson.M) (*categoryForest, error) {
+	cur, err := categoriesCollection().Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var categories []models.CharacterCategory
+	if err := cur.All(ctx, &categories); err != nil {
+		return nil, err
+	}
+	return newCategoryForest(categories), nil
+}
+
+// depth is 1 for a top-level category, 2 for its children and so on.
+func (f *categoryForest) depth(id string) int {
+	d := 0
+	for cat, ok := f.byID[id]; ok && d <= len(f.byID); cat, ok = f.byID[cat.ParentID] {
+		d++
+	}
+	return d
+}
+
+// height is 1 for a leaf, 2 for a category whose children are leaves and so on.
+func (f *categoryForest) height(id string) int {
+	var below func(id string, level int) int
+	below = func(id string, level int) int {
+		h := 0
+		if level > len(f.byID) {
+			return h
+		}
+		for _, child := range f.children[id] {
+			if ch := below(child.ID, level+1); ch > h {
+				h = ch
+			}
+		}
+		return h + 1
+	}
+	return below(id, 1)
+}
+
+// isWithin reports whether id is ancestor or one of its descendants.
+func (f *categoryForest) isWithin(id, ancestor string) bool {
+	for steps := 0; id != "" && steps <= len(f.byID); steps++ {
+		if id == ancestor {
+			return true
+		}
+		cat, ok := f.byID[id]
+		if !ok {
+			return false
+		}
+		id = cat.ParentID
+	}
+	return false
+}
+
+// walk calls visit for id and then for everything under it. Like depth and
+// isWithin it stops after as many levels as there are categories, so parent
+// links that form a cycle cannot make it recurse forever.
+func (f *categoryForest) walk(id string, visit func(id string)) {
+	var down func(id string, level int)
+	down = func(id string, level int) {
+		if level > len(f.byID) {
+			return
+		}
+		visit(id)
+		for _, child := range f.children[id] {
+			down(child.ID, level+1)
+		}
+	}
+	down(id, 1)
+}
+
+func (f *categoryForest) subtreeIDs(id string) []string {
+	seen := make(map[string]bool)
+	ids := []string{}
+	f.walk(id, func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	})
+	return ids
+}
+
+// subtreeCharacterIDs collects the distinct characters filed anywhere under id.
+func (f *categoryForest) subtreeCharacterIDs(id string, into map[string]bool) {
+	f.walk(id, func(id string) {
+		if cat, ok := f.byID[id]; ok {
+			for _, characterID := range cat.CharacterIDs {
+				into[characterID] = true
+			}
+		}
+	})
+}
+
+func (f *categoryForest) nameTaken(parentID, name, exceptID string) bool {
+	for _, sibling := range f.children[parentID] {
+		if sibling.ID != exceptID && strings.EqualFold(sibling.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// node assembles cat and its subcategories, level being the depth of cat. Like
+// walk it stops after as many levels as there are categories.
+func (f *categoryForest) node(cat *models.CharacterCategory, level int) CategoryNode {
+	characters := make(map[string]bool)
+	f.subtreeCharacterIDs(cat.ID, characters)
+	n := CategoryNode{
+		ID:             cat.ID,
+		Name:           cat.Name,
+		ParentID:       cat.ParentID,
+		Position:       cat.Position,
+		CharacterIDs:   cat.CharacterIDs,
+		CharacterCount: len(cat.CharacterIDs),
+		TotalCount:     len(characters),
+		Subcategories:  []CategoryNode{},
+	}
+	if n.CharacterIDs == nil {
+		n.CharacterIDs = []string{}
+	}
+	if level >= len(f.byID) {
+		return n
+	}
+	for _, child := range f.children[cat.ID] {
+		n.Subcategories = append(n.Subcategories, f.node(child, level+1))
+	}
+	return n
+}
+
+func (f *categoryForest) tree() []CategoryNode {
+	roots := []CategoryNode{}
+	for _, cat := range f.children[""] {
+		roots = append(roots, f.node(cat, 1))
+	}
+	return roots
+}
+
+// planMove validates moving id under parentID and returns the new parent's
+// children, in order, with id inserted at position.
+func (f *categoryForest) planMove(id, parentID string, position *int) ([]string, error) {
+	cat, ok := f.byID[id]
+	if !ok {
+		return nil, errCategoryNotFound
+	}
+	if parentID != "" {
+		if _, ok := f.byID[parentID]; !ok {
+			return nil, errors.New("parent category not found")
+		}
+		if f.isWithin(parentID, id) {
+			return nil, errors.New("a category cannot be moved into itself or its subcategories")
+		}
+		if f.depth(parentID)+f.height(id) > maxCategoryDepth {
+			return nil, errors.New("categories cannot be nested this deeply")
+		}
+	}
+	if f.nameTaken(parentID, cat.Name, id) {
+		return nil, errors.New("a sibling category already has this name")
+	}
+
+	siblings := []string{}
+	for _, sibling := range f.children[parentID] {
+		if sibling.ID != id {
+			siblings = append(siblings, sibling.ID)
+		}
+	}
+	at := len(siblings)
+	if position != nil {
+		if *position < 0 {
+			return nil, errors.New("position must not be negative")
+		}
+		if *position < at {
+			at = *position
+		}
+	}
+	ordered := append([]string{}, siblings[:at]...)
+	ordered = append(ordered, id)
+	return append(ordered, siblings[at:]...), nil
+}
+
+// sameChildren reports whether order lists every one of siblings exactly once.
+func sameChildren(siblings []*models.CharacterCategory, order []string) bool {
+	if len(siblings) != len(order) {
+		return false
+	}
+	want := make(map[string]bool, len(siblings))
+	for _, s := range siblings {
+		want[s.ID] = true
+	}
+	for _, id := range order {
+		if !want[id] {
+			return false
+		}
+		delete(want, id)
+	}
+	return len(want) == 0
+}
+
+// positionUpdates renumbers siblings in the given order.
+func positionUpdates(ownerID string, ordered []string, now time.Time) []mongo.WriteModel {
+	writes := make([]mongo.WriteModel, 0, len(ordered))
+	for i, id := range ordered {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": id, "ownerId": ownerID}).
+			SetUpdate(bson.M{"$set": bson.M{"position": i, "updatedAt": now}}))
+	}
+	return writes
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// ListCategories returns a page of the caller's categories, without nesting.
+func ListCategories(c *gin.Context) {
+	respondWithList(c, "categories", bson.M{"ownerId": currentUserID(c)}, "name", nil)
+}
+
+// GetCategoryTree returns the caller's categories as a tree, siblings in order.
+func GetCategoryTree(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	var category models.CharacterCategory
-	if err := c.ShouldBindJSON(&category); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
 		return
 	}
 
-	// Always assign a new UUID for the category ID
-	category.ID = uuid.NewString()
+	forest, err := loadCategoryForest(ctx, bson.M{"ownerId": currentUserID(c)})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load categories: " + err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, forest.tree())
+}
+
+// bindCategory validates a create or rename request.
+func bindCategory(c *gin.Context) (*CategoryRequest, bool) {
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return nil, false
+	}
 
 	if db.Client == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return nil, false
+	}
+	return &req, true
+}
+
+func CreateCategory(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, ok := bindCategory(c)
+	if !ok {
 		return
 	}
 
-	coll := db.Client.Database("main").Collection("categories")
-	_, err := coll.InsertOne(ctx, category)
+	ownerID := currentUserID(c)
+	unlock, err := lockCategories(ctx, ownerID)
 	if err != nil {
+		respondLockError(c, err)
+		return
+	}
+	defer unlock()
+
+	forest, err := loadCategoryForest(ctx, bson.M{"ownerId": ownerID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load categories: " + err.Error()})
+		return
+	}
+	if req.ParentID != "" {
+		if _, ok := forest.byID[req.ParentID]; !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "parent category not found"})
+			return
+		}
+		if forest.depth(req.ParentID) >= maxCategoryDepth {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "categories cannot be nested this deeply"})
+			return
+		}
+	}
+	if forest.nameTaken(req.ParentID, req.Name, "") {
+		c.JSON(http.StatusConflict, gin.H{"error": "a sibling category already has this name"})
+		return
+	}
+
+	// Always assign a new UUID for the category ID
+	now := time.Now()
+	category := models.CharacterCategory{
+		ID:        uuid.NewString(),
+		Name:      req.Name,
+		OwnerID:   ownerID,
+		ParentID:  req.ParentID,
+		Position:  len(forest.children[req.ParentID]),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if _, err := categoriesCollection().InsertOne(ctx, category); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create category: " + err.Error()})
 		return
 	}
@@ -41,6 +478,8 @@ func CreateCategory(c *gin.Context) {
 	c.JSON(http.StatusCreated, category)
 }
 
+// UpdateCategory renames a category. MoveCategory changes where it sits and the
+// character endpoints change what it holds.
 func UpdateCategory(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -51,35 +490,43 @@ func UpdateCategory(c *gin.Context) {
 		return
 	}
 
-	var category models.CharacterCategory
-	if err := c.ShouldBindJSON(&category); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	req, ok := bindCategory(c)
+	if !ok {
 		return
 	}
 
-	if db.Client == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+	ownerID := currentUserID(c)
+	unlock, err := lockCategories(ctx, ownerID)
+	if err != nil {
+		respondLockError(c, err)
 		return
 	}
+	defer unlock()
 
-	coll := db.Client.Database("main").Collection("categories")
-	filter := bson.M{"_id": id}
-	update := bson.M{"$set": category}
-	result, err := coll.UpdateOne(ctx, filter, update)
+	forest, err := loadCategoryForest(ctx, bson.M{"ownerId": ownerID})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update category: " + err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load categories: " + err.Error()})
 		return
 	}
-
-	if result.MatchedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
+	category, ok := forest.byID[id]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": errCategoryNotFound.Error()})
+		return
+	}
+	if forest.nameTaken(category.ParentID, req.Name, id) {
+		c.JSON(http.StatusConflict, gin.H{"error": "a sibling category already has this name"})
 		return
 	}
 
-	c.JSON(http.StatusOK, category)
+	update := bson.M{"$set": bson.M{"name": req.Name, "updatedAt": time.Now()}}
+	updateOwnedCategory(ctx, c, id, update)
 }
 
-func DeleteCategory(c *gin.Context) {
+// MoveCategory moves a category, with everything under it, to a new parent and
+// position. A category cannot be moved into its own subtree. The owner's
+// categories stay locked from the checks until the move is written, so two
+// concurrent moves cannot build a cycle between them.
+func MoveCategory(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -89,60 +536,324 @@ func DeleteCategory(c *gin.Context) {
 		return
 	}
 
+	var req MoveCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	if db.Client == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
 		return
 	}
 
-	coll := db.Client.Database("main").Collection("categories")
-	filter := bson.M{"_id": id}
+	ownerID := currentUserID(c)
+	unlock, err := lockCategories(ctx, ownerID)
+	if err != nil {
+		respondLockError(c, err)
+		return
+	}
+	defer unlock()
+
+	forest, err := loadCategoryForest(ctx, bson.M{"ownerId": ownerID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load categories: " + err.Error()})
+		return
+	}
 
-	result, err := coll.DeleteOne(ctx, filter)
+	ordered, err := forest.planMove(id, req.ParentID, req.Position)
+	if errors.Is(err, errCategoryNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete category: " + err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if result.DeletedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
+	now := time.Now()
+	writes := []mongo.WriteModel{
+		mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": id, "ownerId": ownerID}).
+			SetUpdate(bson.M{"$set": bson.M{"parentId": parentValue(req.ParentID), "updatedAt": now}}),
+	}
+	writes = append(writes, positionUpdates(ownerID, ordered, now)...)
+	if oldParent := forest.byID[id].ParentID; oldParent != req.ParentID {
+		// Close the gap the category left behind.
+		remaining := []string{}
+		for _, sibling := range forest.children[oldParent] {
+			if sibling.ID != id {
+				remaining = append(remaining, sibling.ID)
+			}
+		}
+		writes = append(writes, positionUpdates(ownerID, remaining, now)...)
+	}
+	if _, err := categoriesCollection().BulkWrite(ctx, writes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to move category: " + err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "category deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "category moved successfully"})
 }
 
-func ListCategories(c *gin.Context) {
+// ReorderCategories sets the order of the children of one parent. The order must
+// list every child exactly once.
+func ReorderCategories(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	var req ReorderCategoriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	if db.Client == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
 		return
 	}
 
-	coll := db.Client.Database("main").Collection("categories")
-	cur, err := coll.Find(ctx, bson.D{})
+	ownerID := currentUserID(c)
+	unlock, err := lockCategories(ctx, ownerID)
 	if err != nil {
-		c.String(http.StatusInternalServerError, "find error: %v", err)
+		respondLockError(c, err)
 		return
 	}
-	defer cur.Close(ctx)
+	defer unlock()
 
-	var results []models.CharacterCategory
-	for cur.Next(ctx) {
-		var category models.CharacterCategory
-		if err := cur.Decode(&category); err != nil {
-			c.String(http.StatusInternalServerError, "decode error: %v", err)
+	forest, err := loadCategoryForest(ctx, bson.M{"ownerId": ownerID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load categories: " + err.Error()})
+		return
+	}
+	if req.ParentID != "" {
+		if _, ok := forest.byID[req.ParentID]; !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "parent category not found"})
 			return
 		}
+	}
+	if !sameChildren(forest.children[req.ParentID], req.Order) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order must list every subcategory of the parent exactly once"})
+		return
+	}
+	if len(req.Order) == 0 {
+		c.JSON(http.StatusOK, gin.H{"message": "categories reordered successfully"})
+		return
+	}
+
+	if _, err := categoriesCollection().BulkWrite(ctx, positionUpdates(ownerID, req.Order, time.Now())); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reorder categories: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "categories reordered successfully"})
+}
+
+// AddCategoryCharacters files characters the caller can see into a category.
+// Characters already in it are left as they are.
+func AddCategoryCharacters(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var req CategoryCharactersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ids := uniqueStrings(req.CharacterIDs)
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
+	}
+
+	visible := bson.M{"$and": bson.A{visibilityFilter(c), bson.M{"_id": bson.M{"$in": ids}}}}
+	n, err := db.Client.Database("main").Collection("characters").CountDocuments(ctx, visible)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check characters: " + err.Error()})
+		return
+	}
+	if int(n) != len(ids) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "some characters do not exist"})
+		return
+	}
+
+	update := bson.M{
+		"$addToSet": bson.M{"characterIds": bson.M{"$each": ids}},
+		"$set":      bson.M{"updatedAt": time.Now()},
+	}
+	updateOwnedCategory(ctx, c, c.Param("id"), update)
+}
+
+// RemoveCategoryCharacters takes characters out of a category.
+func RemoveCategoryCharacters(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var req CategoryCharactersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
+	}
+
+	update := bson.M{
+		"$pull": bson.M{"characterIds": bson.M{"$in": req.CharacterIDs}},
+		"$set":  bson.M{"updatedAt": time.Now()},
+	}
+	updateOwnedCategory(ctx, c, c.Param("id"), update)
+}
+
+// updateOwnedCategory applies update to one of the caller's categories and
+// responds with the result.
+func updateOwnedCategory(ctx context.Context, c *gin.Context, id string, update bson.M) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var category models.CharacterCategory
+	err := categoriesCollection().FindOneAndUpdate(ctx, bson.M{"_id": id, "ownerId": currentUserID(c)}, update, opts).Decode(&category)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": errCategoryNotFound.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update category: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, category)
+}
+
+// DeleteCategory deletes a category together with its subcategories. The
+// characters filed in them are not affected.
+func DeleteCategory(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-		results = append(results, category)
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id parameter is required"})
+		return
 	}
-	if err := cur.Err(); err != nil {
-		c.String(http.StatusInternalServerError, "cursor error: %v", err)
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
 		return
 	}
 
-	c.IndentedJSON(http.StatusOK, results)
+	ownerID := currentUserID(c)
+	unlock, err := lockCategories(ctx, ownerID)
+	if err != nil {
+		respondLockError(c, err)
+		return
+	}
+	defer unlock()
+
+	forest, err := loadCategoryForest(ctx, bson.M{"ownerId": ownerID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load categories: " + err.Error()})
+		return
+	}
+	if _, ok := forest.byID[id]; !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": errCategoryNotFound.Error()})
+		return
+	}
+
+	filter := bson.M{"_id": bson.M{"$in": forest.subtreeIDs(id)}, "ownerId": ownerID}
+	result, err := categoriesCollection().DeleteMany(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete category: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "category deleted successfully", "deleted": result.DeletedCount})
+}
+
+// removeCharacterFromCategories keeps categories consistent when a character is deleted.
+func removeCharacterFromCategories(ctx context.Context, characterID string) error {
+	_, err := categoriesCollection().UpdateMany(ctx,
+		bson.M{"characterIds": characterID},
+		bson.M{"$pull": bson.M{"characterIds": characterID}},
+	)
+	return err
 }
 
+// MigrateCategories converts categories stored as embedded trees into one
+// document per category, and hands categories from before they were per user to
+// the oldest admin. It is safe to run on every start.
+func MigrateCategories() {
+	if db.Client == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := migrateCategories(ctx); err != nil {
+		log.Printf("category migration error: %v", err)
+	}
+}
+
+// flattenCategories turns the embedded subcategories of root into documents
+// linked by parentId.
+func flattenCategories(root models.CharacterCategory) []interface{} {
+	var docs []interface{}
+	var flatten func(children []models.CharacterCategory, parentID string)
+	flatten = func(children []models.CharacterCategory, parentID string) {
+		for i, child := range children {
+			if child.ID == "" {
+				child.ID = uuid.NewString()
+			}
+			grandchildren := child.Subcategories
+			child.Subcategories = nil
+			child.OwnerID = root.OwnerID
+			child.ParentID = parentID
+			child.Position = i
+			docs = append(docs, child)
+			flatten(grandchildren, child.ID)
+		}
+	}
+	flatten(root.Subcategories, root.ID)
+	return docs
+}
+
+func migrateCategories(ctx context.Context) error {
+	coll := categoriesCollection()
+
+	cur, err := coll.Find(ctx, bson.M{"subcategories.0": bson.M{"$exists": true}})
+	if err != nil {
+		return err
+	}
+	var nested []models.CharacterCategory
+	if err := cur.All(ctx, &nested); err != nil {
+		return err
+	}
+	for _, root := range nested {
+		docs := flattenCategories(root)
+		// A previous run may have inserted some of these before being interrupted.
+		if _, err := coll.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false)); err != nil && !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+		if _, err := coll.UpdateOne(ctx, bson.M{"_id": root.ID}, bson.M{"$unset": bson.M{"subcategories": ""}}); err != nil {
+			return err
+		}
+		log.Printf("category migration: flattened %d subcategories of %s", len(docs), root.ID)
+	}
+
+	var admin models.Users
+	err = usersCollection().FindOne(ctx, bson.M{"role": RoleAdmin}, options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: 1}})).Decode(&admin)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	result, err := coll.UpdateMany(ctx, bson.M{"ownerId": bson.M{"$in": bson.A{nil, ""}}}, bson.M{"$set": bson.M{"ownerId": admin.ID}})
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount > 0 {
+		log.Printf("category migration: assigned %d categories to admin %s", result.ModifiedCount, admin.Username)
+	}
+	return nil
+}
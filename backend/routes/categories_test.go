@@ -0,0 +1,205 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"FATE-Vault/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testForest is
+//
+//	npc (0)        c1, c2
+//	  villains (0) c2, c3
+//	    bosses (0) c4
+//	  allies (1)
+//	players (1)
+func testForest() *categoryForest {
+	return newCategoryForest([]models.CharacterCategory{
+		{ID: "players", Name: "Игроки", Position: 1},
+		{ID: "npc", Name: "NPC", Position: 0, CharacterIDs: []string{"c1", "c2"}},
+		{ID: "allies", Name: "Союзники", ParentID: "npc", Position: 1},
+		{ID: "villains", Name: "Злодеи", ParentID: "npc", Position: 0, CharacterIDs: []string{"c2", "c3"}},
+		{ID: "bosses", Name: "Боссы", ParentID: "villains", CharacterIDs: []string{"c4"}},
+	})
+}
+
+func TestCategoryForest_Tree(t *testing.T) {
+	tree := testForest().tree()
+
+	require.Len(t, tree, 2)
+	assert.Equal(t, "npc", tree[0].ID)
+	assert.Equal(t, "players", tree[1].ID)
+
+	npc := tree[0]
+	assert.Equal(t, 2, npc.CharacterCount)
+	assert.Equal(t, 4, npc.TotalCount)
+	require.Len(t, npc.Subcategories, 2)
+	assert.Equal(t, "villains", npc.Subcategories[0].ID)
+	assert.Equal(t, 3, npc.Subcategories[0].TotalCount)
+	assert.Equal(t, []string{}, tree[1].CharacterIDs)
+}
+
+func TestCategoryForest_OrphansAreTopLevel(t *testing.T) {
+	f := newCategoryForest([]models.CharacterCategory{{ID: "a", Name: "A", ParentID: "gone"}})
+
+	require.Len(t, f.tree(), 1)
+	assert.Equal(t, "a", f.tree()[0].ID)
+}
+
+func TestCategoryForest_Depth(t *testing.T) {
+	f := testForest()
+
+	assert.Equal(t, 1, f.depth("npc"))
+	assert.Equal(t, 3, f.depth("bosses"))
+	assert.Equal(t, 3, f.height("npc"))
+	assert.Equal(t, 1, f.height("players"))
+	assert.ElementsMatch(t, []string{"npc", "villains", "bosses", "allies"}, f.subtreeIDs("npc"))
+}
+
+func TestCategoryForest_CycleDoesNotHang(t *testing.T) {
+	// A cycle cannot be created through the API any more, but walks must not
+	// hang on one stored by an older version.
+	f := newCategoryForest([]models.CharacterCategory{
+		{ID: "a", Name: "A", ParentID: "b", CharacterIDs: []string{"c1"}},
+		{ID: "b", Name: "B", ParentID: "a", CharacterIDs: []string{"c2"}},
+	})
+
+	assert.LessOrEqual(t, f.depth("a"), 3)
+	assert.LessOrEqual(t, f.height("a"), 3)
+	assert.ElementsMatch(t, []string{"a", "b"}, f.subtreeIDs("a"))
+	characters := map[string]bool{}
+	f.subtreeCharacterIDs("a", characters)
+	assert.Len(t, characters, 2)
+	n := f.node(f.byID["a"], 1)
+	require.Len(t, n.Subcategories, 1)
+	assert.Empty(t, n.Subcategories[0].Subcategories)
+	assert.True(t, f.isWithin("a", "b"))
+}
+
+func TestCategoryForest_PlanMove(t *testing.T) {
+	f := testForest()
+
+	order, err := f.planMove("players", "npc", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"villains", "allies", "players"}, order)
+
+	first := 0
+	order, err = f.planMove("allies", "npc", &first)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"allies", "villains"}, order)
+
+	order, err = f.planMove("bosses", "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"npc", "players", "bosses"}, order)
+}
+
+func TestCategoryForest_PlanMoveRejects(t *testing.T) {
+	f := testForest()
+	negative := -1
+
+	cases := map[string]struct {
+		id, parent string
+		position   *int
+	}{
+		"into itself":       {"npc", "npc", nil},
+		"into a descendant": {"npc", "bosses", nil},
+		"unknown parent":    {"allies", "nope", nil},
+		"negative position": {"allies", "", &negative},
+		"unknown category":  {"nope", "", nil},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := f.planMove(tc.id, tc.parent, tc.position)
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := f.planMove("nope", "", nil)
+	assert.ErrorIs(t, err, errCategoryNotFound)
+
+	f = newCategoryForest([]models.CharacterCategory{
+		{ID: "a", Name: "Злодеи"},
+		{ID: "b", Name: "Игроки"},
+		{ID: "c", Name: "злодеи", ParentID: "b"},
+	})
+	_, err = f.planMove("c", "", nil)
+	assert.ErrorContains(t, err, "already has this name")
+}
+
+func TestCategoryForest_PlanMoveDepthLimit(t *testing.T) {
+	var chain []models.CharacterCategory
+	parent := ""
+	for i := 0; i < maxCategoryDepth; i++ {
+		id := string(rune('a' + i))
+		chain = append(chain, models.CharacterCategory{ID: id, Name: id, ParentID: parent})
+		parent = id
+	}
+	chain = append(chain, models.CharacterCategory{ID: "leaf", Name: "leaf"})
+	f := newCategoryForest(chain)
+
+	_, err := f.planMove("leaf", parent, nil)
+	assert.ErrorContains(t, err, "deeply")
+
+	_, err = f.planMove("leaf", "b", nil)
+	assert.NoError(t, err)
+}
+
+func TestSameChildren(t *testing.T) {
+	f := testForest()
+
+	assert.True(t, sameChildren(f.children["npc"], []string{"allies", "villains"}))
+	assert.False(t, sameChildren(f.children["npc"], []string{"allies"}))
+	assert.False(t, sameChildren(f.children["npc"], []string{"allies", "allies"}))
+	assert.False(t, sameChildren(f.children["npc"], []string{"allies", "bosses"}))
+}
+
+func TestFlattenCategories(t *testing.T) {
+	root := models.CharacterCategory{
+		ID:   "root",
+		Name: "Root",
+		Subcategories: []models.CharacterCategory{
+			{ID: "a", Name: "A", Subcategories: []models.CharacterCategory{{Name: "A1"}}},
+			{ID: "b", Name: "B"},
+		},
+	}
+
+	docs := flattenCategories(root)
+
+	require.Len(t, docs, 3)
+	a1 := docs[1].(models.CharacterCategory)
+	assert.Equal(t, "a", a1.ParentID)
+	assert.NotEmpty(t, a1.ID)
+	b := docs[2].(models.CharacterCategory)
+	assert.Equal(t, "root", b.ParentID)
+	assert.Equal(t, 1, b.Position)
+	assert.Nil(t, b.Subcategories)
+}
+
+func TestCategoryHandlers_Validation(t *testing.T) {
+	router := setupRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set("userId", "user-1")
+		c.Next()
+	})
+	router.POST("/categories/create", CreateCategory)
+	router.POST("/categories/reorder", ReorderCategories)
+	router.POST("/categories/characters/add/:id", AddCategoryCharacters)
+
+	w := serve(router, http.MethodPost, "/categories/create", map[string]interface{}{"name": "  "})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(router, http.MethodPost, "/categories/reorder", map[string]interface{}{"parentId": "npc"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(router, http.MethodPost, "/categories/characters/add/npc", map[string]interface{}{"characterIds": []string{}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(router, http.MethodPost, "/categories/create", map[string]interface{}{"name": "Злодеи"})
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "database connection not available")
+}
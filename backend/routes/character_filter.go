@@ -0,0 +1,337 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"FATE-Vault/backend/db"
+	"FATE-Vault/backend/models"
+	"FATE-Vault/backend/vocab"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	maxFilterValue  = 200
+	maxFilterLeaves = 30
+	maxFilterDepth  = 5
+
+	// The Fate ladder runs from Terrible (-4) to Legendary (+8).
+	minSkillRating = -4
+	maxSkillRating = 8
+)
+
+// characterFilter turns one user-supplied value into a Mongo clause. Values only
+// ever end up as literals: strings are compared for equality or embedded in an
+// escaped regular expression, never used as keys or operators.
+type characterFilter func(ctx context.Context, value string) (bson.M, error)
+
+// characterFilters are the filters /characters/find understands, both as query
+// parameters and as leaves of the filter expression.
+var characterFilters = map[string]characterFilter{
+	"edition":         filterEdition,
+	"name":            containsFilter("name"),
+	"aspect":          containsFilter("aspects.value"),
+	"stunt":           containsFilter("stunts.name"),
+	"libraryStunt":    equalsFilter("stunts.stuntId"),
+	"skill":           filterSkill,
+	"creator":         equalsFilter("creatorId"),
+	"category":        filterCategory,
+	"published":       filterPublished,
+	"createdAfter":    timeFilter("createdAt", "$gte"),
+	"createdBefore":   timeFilter("createdAt", "$lt"),
+	"updatedAfter":    timeFilter("updatedAt", "$gte"),
+	"updatedBefore":   timeFilter("updatedAt", "$lt"),
+	"freeConsequence": filterFreeConsequence,
+	"tag":             filterTag,
+}
+
+// literalPattern matches value anywhere in the field, case-insensitively, with
+// every regex metacharacter escaped.
+func literalPattern(value string) bson.M {
+	return bson.M{"$regex": regexp.QuoteMeta(value), "$options": "i"}
+}
+
+func containsFilter(field string) characterFilter {
+	return func(_ context.Context, value string) (bson.M, error) {
+		return bson.M{field: literalPattern(value)}, nil
+	}
+}
+
+func equalsFilter(field string) characterFilter {
+	return func(_ context.Context, value string) (bson.M, error) {
+		return bson.M{field: value}, nil
+	}
+}
+
+// filterEdition accepts one edition or a comma-separated list.
+func filterEdition(_ context.Context, value string) (bson.M, error) {
+	editions := []string{}
+	for _, e := range strings.Split(value, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			editions = append(editions, e)
+		}
+	}
+	if len(editions) == 0 {
+		return nil, errors.New("edition must not be empty")
+	}
+	return bson.M{"edition": bson.M{"$in": editions}}, nil
+}
+
+func filterPublished(_ context.Context, value string) (bson.M, error) {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, errors.New("published must be true or false")
+	}
+	return bson.M{"isPublished": b}, nil
+}
+
+// timeFilter compares a timestamp field with an RFC 3339 time or a plain date.
+func timeFilter(field, op string) characterFilter {
+	return func(_ context.Context, value string) (bson.M, error) {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t, err = time.Parse("2006-01-02", value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a date (2006-01-02) or RFC 3339 time", field)
+		}
+		return bson.M{field: bson.M{op: t}}, nil
+	}
+}
+
+// skillLevels lists the ways a rating at or above min can be written in a skill
+// group's level, such as "+3", "3", "0" or "-1".
+func skillLevels(min int) []string {
+	levels := []string{}
+	for r := min; r <= maxSkillRating; r++ {
+		switch {
+		case r > 0:
+			levels = append(levels, "+"+strconv.Itoa(r), strconv.Itoa(r))
+		case r == 0:
+			levels = append(levels, "0", "+0")
+		default:
+			levels = append(levels, strconv.Itoa(r))
+		}
+	}
+	return levels
+}
+
+// filterSkill matches characters with the skill, optionally at or above a
+// rating: "Воля" or "Воля:+3". Skills may be named in any locale.
+func filterSkill(_ context.Context, value string) (bson.M, error) {
+	name, rating, hasRating := strings.Cut(value, ":")
+	name = canonicalSkill(name)
+	if name == "" {
+		return nil, errors.New("skill must name a skill, optionally followed by :rating")
+	}
+
+	match := bson.M{"skills": bson.M{"$regex": "^" + regexp.QuoteMeta(name) + "$", "$options": "i"}}
+	if hasRating {
+		min, err := strconv.Atoi(strings.TrimSpace(rating))
+		if err != nil || min < minSkillRating || min > maxSkillRating {
+			return nil, fmt.Errorf("skill rating must be a number from %d to +%d", minSkillRating, maxSkillRating)
+		}
+		match["level"] = bson.M{"$in": skillLevels(min)}
+	}
+	return bson.M{"skills": bson.M{"$elemMatch": match}}, nil
+}
+
+// filterFreeConsequence matches characters with an unused consequence slot of
+// the given type (mild, moderate, severe, ...), named in any locale, or of any
+// type.
+func filterFreeConsequence(_ context.Context, value string) (bson.M, error) {
+	match := bson.M{"status": bson.M{"$in": bson.A{"none", "", nil}}}
+	if value != "any" && value != "true" {
+		match["type"] = canonicalTerm(vocab.ConsequenceType, value)
+	}
+	return bson.M{"consequences": bson.M{"$elemMatch": match}}, nil
+}
+
+// filterCategory matches characters filed in the category or any of its
+// subcategories.
+func filterCategory(ctx context.Context, value string) (bson.M, error) {
+	if db.Client == nil {
+		return nil, errors.New("database connection not available")
+	}
+	var category models.CharacterCategory
+	err := categoriesCollection().FindOne(ctx, bson.M{"_id": value}).Decode(&category)
+	if err == mongo.ErrNoDocuments {
+		return bson.M{"_id": bson.M{"$in": []string{}}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	owner := bson.M{"ownerId": category.OwnerID}
+	if category.OwnerID == "" {
+		// Categories the migration could not give an owner yet.
+		owner = bson.M{"ownerId": bson.M{"$in": bson.A{nil, ""}}}
+	}
+	forest, err := loadCategoryForest(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	characters := make(map[string]bool)
+	forest.subtreeCharacterIDs(value, characters)
+	ids := make([]string, 0, len(characters))
+	for id := range characters {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return bson.M{"_id": bson.M{"$in": ids}}, nil
+}
+
+// buildFilterLeaf validates the name and value of one filter and builds its clause.
+func buildFilterLeaf(ctx context.Context, name, value string) (bson.M, error) {
+	build, ok := characterFilters[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown filter %q", name)
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, fmt.Errorf("filter %q needs a value", name)
+	}
+	if utf8.RuneCountInString(value) > maxFilterValue {
+		return nil, fmt.Errorf("filter %q value is too long", name)
+	}
+	return build(ctx, value)
+}
+
+// filterExpression builds clauses from a JSON expression such as
+//
+//	{"or": [{"skill": "Воля:+3"}, {"and": [{"aspect": "вуду"}, {"published": "true"}]}]}
+//
+// Every object is either a single and/or combinator or a single filter.
+type filterExpression struct {
+	ctx    context.Context
+	leaves int
+}
+
+func (fe *filterExpression) build(raw json.RawMessage, depth int) (bson.M, error) {
+	if depth > maxFilterDepth {
+		return nil, errors.New("filter is nested too deeply")
+	}
+	var node map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &node); err != nil || len(node) != 1 {
+		return nil, errors.New("each filter node must be an object with exactly one key")
+	}
+
+	for key, value := range node {
+		if key == "and" || key == "or" {
+			var children []json.RawMessage
+			if err := json.Unmarshal(value, &children); err != nil || len(children) == 0 {
+				return nil, fmt.Errorf("%q must be a non-empty array", key)
+			}
+			clauses := bson.A{}
+			for _, child := range children {
+				clause, err := fe.build(child, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				clauses = append(clauses, clause)
+			}
+			return bson.M{"$" + key: clauses}, nil
+		}
+
+		fe.leaves++
+		if fe.leaves > maxFilterLeaves {
+			return nil, fmt.Errorf("filter has more than %d conditions", maxFilterLeaves)
+		}
+		// Bare booleans are accepted too, e.g. {"published": true}.
+		var v interface{}
+		if err := json.Unmarshal(value, &v); err != nil {
+			return nil, err
+		}
+		switch v := v.(type) {
+		case string:
+			return buildFilterLeaf(fe.ctx, key, v)
+		case bool:
+			return buildFilterLeaf(fe.ctx, key, strconv.FormatBool(v))
+		default:
+			return nil, fmt.Errorf("filter %q value must be a string", key)
+		}
+	}
+	return nil, errors.New("empty filter")
+}
+
+// buildCharacterFilter combines the filter query parameters, and the optional
+// ?filter= expression, into one clause. Parameters are ANDed unless ?match=any.
+// It returns nil when the request has no filters.
+func buildCharacterFilter(ctx context.Context, c *gin.Context) (bson.M, error) {
+	return characterFilterFromParams(ctx, c.Request.URL.Query())
+}
+
+// characterFilterParams are the query parameters that make up a character filter.
+// Anything else in a query string (paging, sorting) is not part of the filter.
+func characterFilterParams(params url.Values) url.Values {
+	out := url.Values{}
+	for name, values := range params {
+		if _, ok := characterFilters[name]; ok || name == "characterIds" || name == "match" || name == "filter" {
+			out[name] = values
+		}
+	}
+	return out
+}
+
+func characterFilterFromParams(ctx context.Context, params url.Values) (bson.M, error) {
+	clauses := bson.A{}
+	names := make([]string, 0, len(characterFilters))
+	for name := range characterFilters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range params[name] {
+			clause, err := buildFilterLeaf(ctx, name, value)
+			if err != nil {
+				return nil, err
+			}
+			clauses = append(clauses, clause)
+		}
+	}
+	if ids := params["characterIds"]; len(ids) > 0 {
+		clauses = append(clauses, bson.M{"_id": bson.M{"$in": ids}})
+	}
+
+	combined := bson.M(nil)
+	match := params.Get("match")
+	if match == "" {
+		match = "all"
+	}
+	switch {
+	case len(clauses) == 0:
+	case match == "all":
+		combined = bson.M{"$and": clauses}
+	case match == "any":
+		combined = bson.M{"$or": clauses}
+	default:
+		return nil, errors.New("match must be all or any")
+	}
+
+	if raw := params.Get("filter"); raw != "" {
+		fe := &filterExpression{ctx: ctx}
+		expr, err := fe.build(json.RawMessage(raw), 1)
+		if err != nil {
+			return nil, err
+		}
+		if combined == nil {
+			combined = expr
+		} else {
+			combined = bson.M{"$and": bson.A{combined, expr}}
+		}
+	}
+
+	return combined, nil
+}
@@ -0,0 +1,150 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func filterContext(params url.Values) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/characters/find?"+params.Encode(), nil)
+	return c
+}
+
+func TestBuildCharacterFilter_EscapesUserInput(t *testing.T) {
+	filter, err := buildCharacterFilter(context.Background(), filterContext(url.Values{"name": {`.*|(a+)+$`}}))
+
+	require.NoError(t, err)
+	assert.Equal(t, bson.M{"$and": bson.A{
+		bson.M{"name": bson.M{"$regex": `\.\*\|\(a\+\)\+\$`, "$options": "i"}},
+	}}, filter)
+}
+
+func TestBuildCharacterFilter_NoFilters(t *testing.T) {
+	filter, err := buildCharacterFilter(context.Background(), filterContext(url.Values{}))
+
+	require.NoError(t, err)
+	assert.Nil(t, filter)
+}
+
+func TestBuildCharacterFilter_MatchAny(t *testing.T) {
+	params := url.Values{
+		"aspect":       {"вуду"},
+		"published":    {"true"},
+		"match":        {"any"},
+		"createdAfter": {"2024-01-02"},
+	}
+	filter, err := buildCharacterFilter(context.Background(), filterContext(params))
+
+	require.NoError(t, err)
+	assert.Equal(t, bson.M{"$or": bson.A{
+		bson.M{"aspects.value": bson.M{"$regex": "вуду", "$options": "i"}},
+		bson.M{"createdAt": bson.M{"$gte": time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)}},
+		bson.M{"isPublished": true},
+	}}, filter)
+}
+
+func TestFilterSkill(t *testing.T) {
+	clause, err := filterSkill(context.Background(), "Воля:+7")
+	require.NoError(t, err)
+	assert.Equal(t, bson.M{"skills": bson.M{"$elemMatch": bson.M{
+		"skills": bson.M{"$regex": "^will$", "$options": "i"},
+		"level":  bson.M{"$in": []string{"+7", "7", "+8", "8"}},
+	}}}, clause)
+
+	clause, err = filterSkill(context.Background(), "Обман")
+	require.NoError(t, err)
+	assert.NotContains(t, clause["skills"].(bson.M)["$elemMatch"], "level")
+
+	for _, bad := range []string{":+3", "Воля:high", "Воля:+9"} {
+		_, err := filterSkill(context.Background(), bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestSkillLevels(t *testing.T) {
+	assert.Equal(t, []string{"-1", "0", "+0", "+1", "1"}, skillLevels(-1)[:5])
+	assert.Len(t, skillLevels(maxSkillRating), 2)
+}
+
+func TestFilterFreeConsequence(t *testing.T) {
+	clause, err := filterFreeConsequence(context.Background(), "severe")
+	require.NoError(t, err)
+	assert.Equal(t, bson.M{"consequences": bson.M{"$elemMatch": bson.M{
+		"status": bson.M{"$in": bson.A{"none", "", nil}},
+		"type":   "severe",
+	}}}, clause)
+
+	clause, err = filterFreeConsequence(context.Background(), "Лёгкое")
+	require.NoError(t, err)
+	assert.Equal(t, "mild", clause["consequences"].(bson.M)["$elemMatch"].(bson.M)["type"])
+
+	clause, err = filterFreeConsequence(context.Background(), "any")
+	require.NoError(t, err)
+	assert.NotContains(t, clause["consequences"].(bson.M)["$elemMatch"], "type")
+}
+
+func TestBuildCharacterFilter_Expression(t *testing.T) {
+	expr := `{"or": [{"skill": "Воля:+3"}, {"and": [{"stunt": "Увечье"}, {"published": true}]}]}`
+	filter, err := buildCharacterFilter(context.Background(), filterContext(url.Values{"filter": {expr}, "edition": {"core"}}))
+
+	require.NoError(t, err)
+	and := filter["$and"].(bson.A)
+	require.Len(t, and, 2)
+	assert.Equal(t, bson.M{"$and": bson.A{bson.M{"edition": bson.M{"$in": []string{"core"}}}}}, and[0])
+	or := and[1].(bson.M)["$or"].(bson.A)
+	require.Len(t, or, 2)
+	assert.Equal(t, bson.M{"$and": bson.A{
+		bson.M{"stunts.name": bson.M{"$regex": "Увечье", "$options": "i"}},
+		bson.M{"isPublished": true},
+	}}, or[1])
+}
+
+func TestBuildCharacterFilter_Rejects(t *testing.T) {
+	deep := `{"name": "x"}`
+	for i := 0; i < maxFilterDepth; i++ {
+		deep = `{"and": [` + deep + `]}`
+	}
+	cases := map[string]url.Values{
+		"operator as filter":  {"filter": {`{"$where": "sleep(1000)"}`}},
+		"operator as value":   {"filter": {`{"name": {"$ne": null}}`}},
+		"two keys in a node":  {"filter": {`{"name": "a", "stunt": "b"}`}},
+		"empty or":            {"filter": {`{"or": []}`}},
+		"too deep":            {"filter": {deep}},
+		"not json":            {"filter": {`name=a`}},
+		"bad published":       {"published": {"maybe"}},
+		"bad date":            {"updatedBefore": {"yesterday"}},
+		"bad match":           {"name": {"a"}, "match": {"some"}},
+		"category without db": {"category": {"cat-1"}},
+	}
+	for name, params := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := buildCharacterFilter(context.Background(), filterContext(params))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestBuildCharacterFilter_LimitsConditions(t *testing.T) {
+	expr := `{"or": [`
+	for i := 0; i <= maxFilterLeaves; i++ {
+		if i > 0 {
+			expr += ","
+		}
+		expr += `{"name": "x"}`
+	}
+	expr += `]}`
+
+	_, err := buildCharacterFilter(context.Background(), filterContext(url.Values{"filter": {expr}}))
+
+	assert.ErrorContains(t, err, "more than")
+}
@@ -11,6 +11,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func visibilityFilter(c *gin.Context) bson.M {
@@ -19,69 +21,104 @@ func visibilityFilter(c *gin.Context) bson.M {
 		"creatorId":   bson.M{"$exists": false},
 	}
 
+	if userIdStr := optionalUserID(c); userIdStr != "" {
+		filter["$or"] = []bson.M{
+			{"creatorId": bson.M{"$exists": false}},
+			{"creatorId": userIdStr},
+		}
+	}
+
+	return filter
+}
+
+// optionalUserID identifies the caller on routes that do not require login: from
+// AuthMiddleware when it ran, otherwise from an API token with the
+// characters:read scope or a valid session cookie.
+func optionalUserID(c *gin.Context) string {
 	userId, exists := c.Get("userId")
 	if !exists || userId == nil {
+		if raw, ok := bearerToken(c); ok {
+			return optionalTokenUserID(c, raw)
+		}
 		if sessionID := sessionIDFromRequest(c); sessionID != "" {
 			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 			defer cancel()
 
 			user, _, err := UserFromSessionID(ctx, sessionID)
 			if err == nil && user != nil && user.ID != "" {
-				userId = user.ID
-				exists = true
-			}
-		}
-	}
-
-	if exists && userId != nil {
-		userIdStr, ok := userId.(string)
-		if ok && userIdStr != "" {
-			filter["$or"] = []bson.M{
-				{"creatorId": bson.M{"$exists": false}},
-				{"creatorId": userIdStr},
+				return user.ID
 			}
 		}
+		return ""
 	}
 
-	return filter
+	userIdStr, _ := userId.(string)
+	return userIdStr
 }
 
-func CharactersList(c *gin.Context) {
+// optionalTokenUserID authenticates an API token for optionalUserID. Tokens that
+// are invalid or cannot read characters leave the caller anonymous.
+func optionalTokenUserID(c *gin.Context, raw string) string {
+	c.Writer.Header().Add("Vary", "Authorization")
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	if db.Client == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
-		return
+	user, token, err := UserFromAPIToken(ctx, raw)
+	if err != nil || !token.hasScope(ScopeCharactersRead) {
+		return ""
 	}
+	c.Set("userId", user.ID)
+	c.Set("username", user.Username)
+	c.Set("role", user.Role)
+	c.Set("user", *user)
+	c.Set("apiToken", token)
+	return user.ID
+}
 
-	// Build filter for published characters visible to current user
-	filter := visibilityFilter(c)
-
-	coll := db.Client.Database("main").Collection("characters")
-	cur, err := coll.Find(ctx, filter)
+// allowPublishing checks that the caller may publish or unpublish characters,
+// responding with an error when they may not.
+func allowPublishing(ctx context.Context, c *gin.Context) bool {
+	ok, err := hasPermission(ctx, c, PermCharactersPublish)
 	if err != nil {
-		c.String(http.StatusInternalServerError, "find error: %v", err)
-		return
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load roles: " + err.Error()})
+		return false
 	}
-	defer cur.Close(ctx)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "missing permission " + PermCharactersPublish})
+		return false
+	}
+	return true
+}
 
-	var results []map[string]interface{}
-	for cur.Next(ctx) {
-		var doc bson.M
-		if err := cur.Decode(&doc); err != nil {
-			c.String(http.StatusInternalServerError, "decode error: %v", err)
+// CharactersList returns a page of the characters visible to the caller,
+// optionally narrowed to the results of a saved search (?savedSearch=<id>).
+func CharactersList(c *gin.Context) {
+	filter := visibilityFilter(c)
+
+	if c.Query("savedSearch") != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if db.Client == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
 			return
 		}
-
-		results = append(results, doc)
-	}
-	if err := cur.Err(); err != nil {
-		c.String(http.StatusInternalServerError, "cursor error: %v", err)
-		return
+		saved, err := savedSearchFilter(ctx, c)
+		if err == errSavedSearchNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load saved search: " + err.Error()})
+			return
+		}
+		if saved != nil {
+			filter = bson.M{"$and": bson.A{filter, saved}}
+		}
 	}
 
-	c.IndentedJSON(http.StatusOK, results)
+	respondWithTaggedList(c, "characters", filter, "name", localizeSheetDocument(requestLocale(c)))
 }
 
 func CreateCharacter(c *gin.Context) {
@@ -93,9 +130,20 @@ func CreateCharacter(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	tags, err := normalizeTags(character.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	character.Tags = tags
+	canonicalizeSheet(&character)
 
 	// Always assign a new UUID for the character ID
 	character.ID = uuid.NewString()
+	// Timestamps are the server's; lists sort by them.
+	now := time.Now()
+	character.CreatedAt = now
+	character.UpdatedAt = now
 
 	// Attach creatorId from the authenticated user (if available)
 	if userId, exists := c.Get("userId"); exists {
@@ -109,13 +157,27 @@ func CreateCharacter(c *gin.Context) {
 		return
 	}
 
+	if character.IsPublished && !allowPublishing(ctx, c) {
+		return
+	}
+
+	if err := resolveCharacterStunts(ctx, character.Stunts); err != nil {
+		if _, ok := err.(invalidStuntError); ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load library stunts: " + err.Error()})
+		return
+	}
+
 	coll := db.Client.Database("main").Collection("characters")
-	_, err := coll.InsertOne(ctx, character)
+	_, err = coll.InsertOne(ctx, character)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create character: " + err.Error()})
 		return
 	}
 
+	localizeSheet(&character, requestLocale(c))
 	c.JSON(http.StatusCreated, character)
 }
 
@@ -134,6 +196,17 @@ func UpdateCharacter(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	tags, err := normalizeTags(character.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	character.Tags = tags
+	canonicalizeSheet(&character)
+	// Keep the stored creator and createdAt; zero values are left out of the update.
+	character.CreatorID = ""
+	character.CreatedAt = time.Time{}
+	character.UpdatedAt = time.Now()
 
 	if db.Client == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
@@ -141,7 +214,39 @@ func UpdateCharacter(c *gin.Context) {
 	}
 
 	coll := db.Client.Database("main").Collection("characters")
-	filter := bson.M{"_id": id}
+
+	// Publishing or unpublishing needs its own permission; other edits keep the
+	// published flag as it is.
+	var current struct {
+		IsPublished bool `bson:"isPublished"`
+	}
+	filter, err := ownedCharacterFilter(ctx, c, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load roles: " + err.Error()})
+		return
+	}
+	err = coll.FindOne(ctx, filter, options.FindOne().SetProjection(bson.M{"isPublished": 1})).Decode(&current)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "character not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to find character: " + err.Error()})
+		return
+	}
+	if current.IsPublished != character.IsPublished && !allowPublishing(ctx, c) {
+		return
+	}
+
+	if err := resolveCharacterStunts(ctx, character.Stunts); err != nil {
+		if _, ok := err.(invalidStuntError); ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load library stunts: " + err.Error()})
+		return
+	}
+
 	update := bson.M{"$set": character}
 	result, err := coll.UpdateOne(ctx, filter, update)
 	if err != nil {
@@ -154,6 +259,7 @@ func UpdateCharacter(c *gin.Context) {
 		return
 	}
 
+	localizeSheet(&character, requestLocale(c))
 	c.JSON(http.StatusOK, character)
 }
 
@@ -173,7 +279,11 @@ func DeleteCharacter(c *gin.Context) {
 	}
 
 	coll := db.Client.Database("main").Collection("characters")
-	filter := bson.M{"_id": id}
+	filter, err := ownedCharacterFilter(ctx, c, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load roles: " + err.Error()})
+		return
+	}
 
 	result, err := coll.DeleteOne(ctx, filter)
 	if err != nil {
@@ -186,6 +296,11 @@ func DeleteCharacter(c *gin.Context) {
 		return
 	}
 
+	if err := removeCharacterFromCategories(ctx, id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update categories: " + err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "character deleted successfully"})
 }
 
@@ -201,15 +316,14 @@ func FindCharacters(c *gin.Context) {
 	// Start with visibility filter
 	filter := visibilityFilter(c)
 
-	// Add query parameter filters
-	if edition := c.Query("edition"); edition != "" {
-		filter["edition"] = edition
-	}
-	if name := c.Query("name"); name != "" {
-		filter["name"] = bson.M{"$regex": name, "$options": "i"}
+	// Add query parameter filters; see character_filter.go for the language
+	requested, err := buildCharacterFilter(ctx, c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid filter: " + err.Error()})
+		return
 	}
-	if characterIds := c.QueryArray("characterIds"); len(characterIds) > 0 {
-		filter["_id"] = bson.M{"$in": characterIds}
+	if requested != nil {
+		filter = bson.M{"$and": bson.A{filter, requested}}
 	}
 
 	coll := db.Client.Database("main").Collection("characters")
@@ -220,6 +334,7 @@ func FindCharacters(c *gin.Context) {
 	}
 	defer cur.Close(ctx)
 
+	locale := requestLocale(c)
 	var results []models.Character
 	for cur.Next(ctx) {
 		var character models.Character
@@ -227,6 +342,7 @@ func FindCharacters(c *gin.Context) {
 			c.String(http.StatusInternalServerError, "decode error: %v", err)
 			return
 		}
+		localizeSheet(&character, locale)
 
 		results = append(results, character)
 	}
@@ -2,6 +2,7 @@ package routes
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.mongodb.org/mongo-driver/bson"
 )
 
@@ -442,3 +444,30 @@ func TestCreateMockCharacterDocuments(t *testing.T) {
 	assert.Equal(t, "test-id-2", docs[1]["_id"])
 	assert.Equal(t, "Character 2", docs[1]["name"])
 }
+
+// Update and delete match the stored character through ownedCharacterFilter, so
+// a character created by someone else is not found and answers 404.
+func TestOwnedCharacterFilter(t *testing.T) {
+	roles.invalidate()
+	owned := bson.M{"_id": "char-1", "$or": bson.A{
+		bson.M{"creatorId": bson.M{"$exists": false}},
+		bson.M{"creatorId": "user-1"},
+	}}
+	cases := map[string]bson.M{
+		RolePlayer:    owned,
+		RoleGM:        owned,
+		RoleModerator: {"_id": "char-1"},
+		RoleAdmin:     {"_id": "char-1"},
+	}
+	for role, want := range cases {
+		t.Run(role, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Set("userId", "user-1")
+			c.Set("role", role)
+
+			filter, err := ownedCharacterFilter(context.Background(), c, "char-1")
+			require.NoError(t, err)
+			assert.Equal(t, want, filter)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupCSRFRouter() *gin.Engine {
+	router := setupRouter()
+	router.Use(CSRFProtect())
+	ok := func(c *gin.Context) { c.Status(http.StatusNoContent) }
+	router.GET("/characters", ok)
+	router.POST("/characters/create", ok)
+	router.DELETE("/characters/delete/:id", ok)
+	return router
+}
+
+func csrfRequest(method, url, sessionID, token string) *http.Request {
+	req := httptest.NewRequest(method, url, nil)
+	if sessionID != "" {
+		req.AddCookie(&http.Cookie{Name: sessionCookieName(), Value: sessionID})
+	}
+	if token != "" {
+		req.Header.Set(csrfHeader, token)
+	}
+	return req
+}
+
+func TestCSRFProtect(t *testing.T) {
+	router := setupCSRFRouter()
+	cases := map[string]struct {
+		req  *http.Request
+		want int
+	}{
+		"safe method with cookie":   {csrfRequest(http.MethodGet, "/characters", "sess-1", ""), http.StatusNoContent},
+		"write without cookie":      {csrfRequest(http.MethodPost, "/characters/create", "", ""), http.StatusNoContent},
+		"write missing token":       {csrfRequest(http.MethodPost, "/characters/create", "sess-1", ""), http.StatusForbidden},
+		"write with token":          {csrfRequest(http.MethodPost, "/characters/create", "sess-1", csrfToken("sess-1")), http.StatusNoContent},
+		"delete with token":         {csrfRequest(http.MethodDelete, "/characters/delete/x", "sess-1", csrfToken("sess-1")), http.StatusNoContent},
+		"token of another session":  {csrfRequest(http.MethodPost, "/characters/create", "sess-1", csrfToken("sess-2")), http.StatusForbidden},
+		"delete with garbage token": {csrfRequest(http.MethodDelete, "/characters/delete/x", "sess-1", "garbage"), http.StatusForbidden},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, tc.req)
+			assert.Equal(t, tc.want, w.Code)
+		})
+	}
+}
+
+func TestCSRFProtect_ExemptsBearerRequests(t *testing.T) {
+	router := setupCSRFRouter()
+	req := csrfRequest(http.MethodPost, "/characters/create", "sess-1", "")
+	req.Header.Set("Authorization", "Bearer fvt_token")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestGetCSRFToken(t *testing.T) {
+	router := setupRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set("sessionId", "sess-1")
+		c.Next()
+	})
+	router.GET("/users/csrf", GetCSRFToken)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/csrf", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, csrfToken("sess-1"), body["csrfToken"])
+	assert.NotContains(t, w.Body.String(), "sess-1")
+}
@@ -13,6 +13,20 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 )
 
+// ownedGameFilter matches the game if the caller owns it. Moderators can change
+// every game, including those created before games had owners.
+func ownedGameFilter(ctx context.Context, c *gin.Context, id string) (bson.M, error) {
+	filter := bson.M{"_id": id}
+	canModerate, err := hasPermission(ctx, c, PermUsersModerate)
+	if err != nil {
+		return nil, err
+	}
+	if !canModerate {
+		filter["ownerId"] = currentUserID(c)
+	}
+	return filter, nil
+}
+
 func CreateGame(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -22,9 +36,19 @@ func CreateGame(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	tags, err := normalizeTags(game.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	game.Tags = tags
 
 	// Always assign a new UUID for the game ID
 	game.ID = uuid.NewString()
+	game.OwnerID = currentUserID(c)
+	now := time.Now()
+	game.CreatedAt = now
+	game.UpdatedAt = now
 
 	if db.Client == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
@@ -32,7 +56,7 @@ func CreateGame(c *gin.Context) {
 	}
 
 	coll := db.Client.Database("main").Collection("games")
-	_, err := coll.InsertOne(ctx, game)
+	_, err = coll.InsertOne(ctx, game)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create game: " + err.Error()})
 		return
@@ -56,14 +80,30 @@ func UpdateGame(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	tags, err := normalizeTags(game.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	game.Tags = tags
 
 	if db.Client == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
 		return
 	}
 
+	filter, err := ownedGameFilter(ctx, c, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load roles: " + err.Error()})
+		return
+	}
+	// The owner and createdAt cannot be changed through an update; zero values
+	// are left out of it.
+	game.OwnerID = ""
+	game.CreatedAt = time.Time{}
+	game.UpdatedAt = time.Now()
+
 	coll := db.Client.Database("main").Collection("games")
-	filter := bson.M{"_id": id}
 	update := bson.M{"$set": game}
 	result, err := coll.UpdateOne(ctx, filter, update)
 	if err != nil {
@@ -94,9 +134,13 @@ func DeleteGame(c *gin.Context) {
 		return
 	}
 
-	coll := db.Client.Database("main").Collection("games")
-	filter := bson.M{"_id": id}
+	filter, err := ownedGameFilter(ctx, c, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load roles: " + err.Error()})
+		return
+	}
 
+	coll := db.Client.Database("main").Collection("games")
 	result, err := coll.DeleteOne(ctx, filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete game: " + err.Error()})
@@ -112,36 +156,5 @@ func DeleteGame(c *gin.Context) {
 }
 
 func ListGames(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	if db.Client == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
-		return
-	}
-
-	coll := db.Client.Database("main").Collection("games")
-	cur, err := coll.Find(ctx, bson.D{})
-	if err != nil {
-		c.String(http.StatusInternalServerError, "find error: %v", err)
-		return
-	}
-	defer cur.Close(ctx)
-
-	var results []models.Game
-	for cur.Next(ctx) {
-		var game models.Game
-		if err := cur.Decode(&game); err != nil {
-			c.String(http.StatusInternalServerError, "decode error: %v", err)
-			return
-		}
-
-		results = append(results, game)
-	}
-	if err := cur.Err(); err != nil {
-		c.String(http.StatusInternalServerError, "cursor error: %v", err)
-		return
-	}
-
-	c.IndentedJSON(http.StatusOK, results)
+	respondWithTaggedList(c, "games", bson.M{}, "name", nil)
 }
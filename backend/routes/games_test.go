@@ -0,0 +1,33 @@
+package routes
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestOwnedGameFilter(t *testing.T) {
+	roles.invalidate()
+	cases := map[string]bson.M{
+		RolePlayer:    {"_id": "game-1", "ownerId": "user-1"},
+		RoleGM:        {"_id": "game-1", "ownerId": "user-1"},
+		RoleModerator: {"_id": "game-1"},
+		RoleAdmin:     {"_id": "game-1"},
+	}
+	for role, want := range cases {
+		t.Run(role, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Set("userId", "user-1")
+			c.Set("role", role)
+
+			filter, err := ownedGameFilter(context.Background(), c, "game-1")
+			require.NoError(t, err)
+			assert.Equal(t, want, filter)
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package routes
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"FATE-Vault/backend/db"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+// listSortFields are the fields list endpoints can sort by.
+var listSortFields = map[string]bool{"name": true, "createdAt": true, "updatedAt": true}
+
+// projectionField accepts plain and dotted field names, but nothing that could
+// smuggle an operator into the projection.
+var projectionField = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// ListQuery is the pagination, sort and projection a client asked for:
+//
+//	?limit=20&page=3           page-based
+//	?limit=20&cursor=<opaque>  keyset, using nextCursor from the previous page
+//	?sort=-updatedAt           name, createdAt or updatedAt; "-" for descending
+//	?fields=name,edition       only return these fields (and _id)
+type ListQuery struct {
+	Limit  int
+	Page   int
+	Cursor *listCursor
+	Sort   string
+	Desc   bool
+	Fields []string
+}
+
+// ListPage is the envelope every list endpoint responds with.
+type ListPage struct {
+	Items      []bson.M `json:"items"`
+	Total      int64    `json:"total"`
+	Limit      int      `json:"limit"`
+	Page       int      `json:"page,omitempty"`
+	Next       string   `json:"next,omitempty"`
+	NextCursor string   `json:"nextCursor,omitempty"`
+}
+
+// listCursor marks where the previous page ended: the sort value and _id of its
+// last item. It is only valid for the sort it was issued under.
+type listCursor struct {
+	Sort     string     `json:"k"`
+	Desc     bool       `json:"d,omitempty"`
+	String   *string    `json:"s,omitempty"`
+	Time     *time.Time `json:"t,omitempty"`
+	ID       string     `json:"id"`
+	ObjectID bool       `json:"oid,omitempty"`
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+func (lc *listCursor) encode() string {
+	b, _ := json.Marshal(lc)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeListCursor(s string) (*listCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var lc listCursor
+	if err := json.Unmarshal(b, &lc); err != nil || lc.ID == "" || !listSortFields[lc.Sort] {
+		return nil, errInvalidCursor
+	}
+	return &lc, nil
+}
+
+// ParseListQuery reads the list parameters from the request. defaultSort applies
+// when the client does not ask for one.
+func ParseListQuery(c *gin.Context, defaultSort string) (*ListQuery, error) {
+	q := &ListQuery{Limit: defaultListLimit}
+
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxListLimit {
+			return nil, errors.New("limit must be between 1 and " + strconv.Itoa(maxListLimit))
+		}
+		q.Limit = n
+	}
+
+	sort := c.DefaultQuery("sort", defaultSort)
+	if strings.HasPrefix(sort, "-") {
+		q.Desc = true
+		sort = sort[1:]
+	}
+	if !listSortFields[sort] {
+		return nil, errors.New("sort must be one of name, createdAt, updatedAt, optionally prefixed with -")
+	}
+	q.Sort = sort
+
+	page, cursor := c.Query("page"), c.Query("cursor")
+	if page != "" && cursor != "" {
+		return nil, errors.New("use either page or cursor, not both")
+	}
+	if cursor != "" {
+		lc, err := decodeListCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		if lc.Sort != q.Sort || lc.Desc != q.Desc {
+			return nil, errors.New("cursor was issued for a different sort")
+		}
+		q.Cursor = lc
+	} else {
+		q.Page = 1
+		if page != "" {
+			n, err := strconv.Atoi(page)
+			if err != nil || n < 1 {
+				return nil, errors.New("page must be a positive number")
+			}
+			q.Page = n
+		}
+	}
+
+	if fields := c.Query("fields"); fields != "" {
+		for _, f := range strings.Split(fields, ",") {
+			f = strings.TrimSpace(f)
+			if f == "" {
+				continue
+			}
+			if !projectionField.MatchString(f) {
+				return nil, errors.New("invalid field name " + strconv.Quote(f))
+			}
+			q.Fields = append(q.Fields, f)
+		}
+	}
+
+	return q, nil
+}
+
+func (q *ListQuery) direction() int {
+	if q.Desc {
+		return -1
+	}
+	return 1
+}
+
+// afterCursor matches the documents that sort after the cursor position, with _id
+// breaking ties. Documents without the sort field sort before all others.
+func (q *ListQuery) afterCursor() bson.M {
+	lc := q.Cursor
+	var id interface{} = lc.ID
+	if lc.ObjectID {
+		if oid, err := primitive.ObjectIDFromHex(lc.ID); err == nil {
+			id = oid
+		}
+	}
+	cmp := "$gt"
+	if q.Desc {
+		cmp = "$lt"
+	}
+
+	var value interface{}
+	switch {
+	case lc.String != nil:
+		value = *lc.String
+	case lc.Time != nil:
+		value = *lc.Time
+	}
+
+	if value == nil {
+		if q.Desc {
+			return bson.M{q.Sort: nil, "_id": bson.M{cmp: id}}
+		}
+		return bson.M{"$or": bson.A{
+			bson.M{q.Sort: nil, "_id": bson.M{cmp: id}},
+			bson.M{q.Sort: bson.M{"$ne": nil}},
+		}}
+	}
+
+	or := bson.A{
+		bson.M{q.Sort: bson.M{cmp: value}},
+		bson.M{q.Sort: value, "_id": bson.M{cmp: id}},
+	}
+	if q.Desc {
+		or = append(or, bson.M{q.Sort: nil})
+	}
+	return bson.M{"$or": or}
+}
+
+// cursorAfter builds the cursor pointing just past doc.
+func (q *ListQuery) cursorAfter(doc bson.M) *listCursor {
+	lc := &listCursor{Sort: q.Sort, Desc: q.Desc}
+	switch id := doc["_id"].(type) {
+	case string:
+		lc.ID = id
+	case primitive.ObjectID:
+		lc.ID, lc.ObjectID = id.Hex(), true
+	default:
+		return nil
+	}
+	switch v := doc[q.Sort].(type) {
+	case string:
+		lc.String = &v
+	case primitive.DateTime:
+		t := v.Time()
+		lc.Time = &t
+	case time.Time:
+		lc.Time = &v
+	}
+	return lc
+}
+
+// Run fetches one page of the collection's documents matching filter.
+func (q *ListQuery) Run(ctx context.Context, collection string, filter bson.M) (*ListPage, error) {
+	coll := db.Client.Database("main").Collection(collection)
+
+	total, err := coll.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	find := filter
+	if q.Cursor != nil {
+		find = bson.M{"$and": bson.A{filter, q.afterCursor()}}
+	}
+
+	// One extra document tells us whether there is a next page.
+	opts := options.Find().
+		SetSort(bson.D{{Key: q.Sort, Value: q.direction()}, {Key: "_id", Value: q.direction()}}).
+		SetLimit(int64(q.Limit + 1))
+	if q.Page > 1 {
+		opts.SetSkip(int64((q.Page - 1) * q.Limit))
+	}
+	if len(q.Fields) > 0 {
+		// The sort field is always returned so the next cursor can be built.
+		projection := bson.D{{Key: "_id", Value: 1}, {Key: q.Sort, Value: 1}}
+		for _, f := range q.Fields {
+			if f != "_id" && f != q.Sort {
+				projection = append(projection, bson.E{Key: f, Value: 1})
+			}
+		}
+		opts.SetProjection(projection)
+	}
+
+	cur, err := coll.Find(ctx, find, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	items := []bson.M{}
+	if err := cur.All(ctx, &items); err != nil {
+		return nil, err
+	}
+
+	page := &ListPage{Total: total, Limit: q.Limit, Page: q.Page}
+	if len(items) > q.Limit {
+		items = items[:q.Limit]
+		if lc := q.cursorAfter(items[len(items)-1]); lc != nil {
+			page.NextCursor = lc.encode()
+		}
+	}
+	page.Items = items
+	return page, nil
+}
+
+// nextLink is the request's URL with the page or cursor advanced.
+func (p *ListPage) nextLink(c *gin.Context) string {
+	if p.NextCursor == "" {
+		return ""
+	}
+	params := c.Request.URL.Query()
+	if p.Page > 0 {
+		params.Set("page", strconv.Itoa(p.Page+1))
+		params.Del("cursor")
+	} else {
+		params.Set("cursor", p.NextCursor)
+		params.Del("page")
+	}
+	return c.Request.URL.Path + "?" + params.Encode()
+}
+
+// respondWithList runs the client's list query against the collection and writes
+// the page. It is what every list endpoint uses. mapItem, if not nil, rewrites
+// each item before it is sent, e.g. to localize it.
+func respondWithList(c *gin.Context, collection string, filter bson.M, defaultSort string, mapItem func(bson.M)) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	q, err := ParseListQuery(c, defaultSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
+	}
+
+	page, err := q.Run(ctx, collection, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list " + collection + ": " + err.Error()})
+		return
+	}
+	page.Next = page.nextLink(c)
+	if mapItem != nil {
+		for _, item := range page.Items {
+			mapItem(item)
+		}
+	}
+
+	c.IndentedJSON(http.StatusOK, page)
+}
@@ -0,0 +1,134 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func listContext(rawQuery string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/characters?"+rawQuery, nil)
+	return c
+}
+
+func TestParseListQuery_Defaults(t *testing.T) {
+	q, err := ParseListQuery(listContext(""), "name")
+
+	require.NoError(t, err)
+	assert.Equal(t, defaultListLimit, q.Limit)
+	assert.Equal(t, 1, q.Page)
+	assert.Equal(t, "name", q.Sort)
+	assert.False(t, q.Desc)
+	assert.Nil(t, q.Cursor)
+	assert.Empty(t, q.Fields)
+}
+
+func TestParseListQuery_Options(t *testing.T) {
+	q, err := ParseListQuery(listContext("limit=20&page=3&sort=-updatedAt&fields=name,%20edition,refresh.max"), "name")
+
+	require.NoError(t, err)
+	assert.Equal(t, 20, q.Limit)
+	assert.Equal(t, 3, q.Page)
+	assert.Equal(t, "updatedAt", q.Sort)
+	assert.True(t, q.Desc)
+	assert.Equal(t, []string{"name", "edition", "refresh.max"}, q.Fields)
+}
+
+func TestParseListQuery_Rejects(t *testing.T) {
+	otherSort := (&listCursor{Sort: "createdAt", ID: "x"}).encode()
+	cases := map[string]string{
+		"zero limit":        "limit=0",
+		"huge limit":        "limit=5000",
+		"unknown sort":      "sort=hashedPassword",
+		"bad page":          "page=-1",
+		"page and cursor":   "page=2&cursor=" + otherSort,
+		"garbage cursor":    "cursor=not-a-cursor",
+		"cursor other sort": "cursor=" + otherSort,
+		"operator field":    "fields=$where",
+		"empty segment":     "fields=name..x",
+	}
+	for name, query := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseListQuery(listContext(query), "name")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestListCursor_RoundTrip(t *testing.T) {
+	q := &ListQuery{Sort: "createdAt", Desc: true}
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	lc := q.cursorAfter(bson.M{"_id": "char-9", "createdAt": primitive.NewDateTimeFromTime(created)})
+	require.NotNil(t, lc)
+
+	parsed, err := ParseListQuery(listContext("sort=-createdAt&cursor="+lc.encode()), "name")
+	require.NoError(t, err)
+	require.NotNil(t, parsed.Cursor)
+	assert.Equal(t, "char-9", parsed.Cursor.ID)
+	require.NotNil(t, parsed.Cursor.Time)
+	assert.True(t, created.Equal(*parsed.Cursor.Time))
+	assert.Equal(t, 0, parsed.Page)
+}
+
+func TestAfterCursor(t *testing.T) {
+	name := "Bob"
+	asc := &ListQuery{Sort: "name", Cursor: &listCursor{Sort: "name", String: &name, ID: "id-1"}}
+	assert.Equal(t, bson.M{"$or": bson.A{
+		bson.M{"name": bson.M{"$gt": "Bob"}},
+		bson.M{"name": "Bob", "_id": bson.M{"$gt": "id-1"}},
+	}}, asc.afterCursor())
+
+	// Descending order puts documents without the field last.
+	desc := &ListQuery{Sort: "name", Desc: true, Cursor: &listCursor{Sort: "name", Desc: true, String: &name, ID: "id-1"}}
+	assert.Equal(t, bson.M{"$or": bson.A{
+		bson.M{"name": bson.M{"$lt": "Bob"}},
+		bson.M{"name": "Bob", "_id": bson.M{"$lt": "id-1"}},
+		bson.M{"name": nil},
+	}}, desc.afterCursor())
+
+	oid := primitive.NewObjectID()
+	missing := &ListQuery{Sort: "name", Cursor: &listCursor{Sort: "name", ID: oid.Hex(), ObjectID: true}}
+	assert.Equal(t, bson.M{"$or": bson.A{
+		bson.M{"name": nil, "_id": bson.M{"$gt": oid}},
+		bson.M{"name": bson.M{"$ne": nil}},
+	}}, missing.afterCursor())
+}
+
+func TestListPage_NextLink(t *testing.T) {
+	c := listContext("limit=2&page=1&fields=name")
+	page := &ListPage{Page: 1, NextCursor: "abc"}
+	assert.Equal(t, "/characters?fields=name&limit=2&page=2", page.nextLink(c))
+
+	c = listContext("limit=2&cursor=old")
+	page = &ListPage{NextCursor: "abc"}
+	assert.Equal(t, "/characters?cursor=abc&limit=2", page.nextLink(c))
+
+	assert.Empty(t, (&ListPage{Page: 1}).nextLink(c))
+}
+
+func TestListEndpoints_ValidateQueryBeforeDatabase(t *testing.T) {
+	router := setupRouter()
+	router.GET("/characters", CharactersList)
+	router.GET("/games", ListGames)
+	router.GET("/stunts", ListStunts)
+	router.GET("/categories", ListCategories)
+	router.GET("/templates", GetTemplates)
+
+	for _, path := range []string{"/characters", "/games", "/stunts", "/categories", "/templates"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path+"?limit=abc", nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusInternalServerError, w.Code, path)
+	}
+}
@@ -0,0 +1,364 @@
+package routes
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"FATE-Vault/backend/db"
+	"FATE-Vault/backend/models"
+	"FATE-Vault/backend/vocab"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Game terms on character sheets are stored as vocab identifiers and sent in the
+// reader's locale. Clients may send them back in any locale; canonicalizeSheet
+// turns them into identifiers again, so a sheet written in Russian reads in
+// English and the other way round. Anything that is not a known term, such as a
+// custom skill, is kept as written.
+
+type VocabularyTerm struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type VocabularyResponse struct {
+	Locale  string                          `json:"locale"`
+	Locales []string                        `json:"locales"`
+	Terms   map[vocab.Kind][]VocabularyTerm `json:"terms"`
+}
+
+type UpdateLocaleRequest struct {
+	// Locale is a supported locale, or empty to follow the browser again.
+	Locale string `json:"locale"`
+}
+
+// requestLocale is the locale to answer in: ?locale=, else the user's
+// preference, else the Accept-Language header, else vocab.DefaultLocale.
+func requestLocale(c *gin.Context) string {
+	if locale := c.GetString("locale"); locale != "" {
+		return locale
+	}
+	locale := vocab.Match(c.Query("locale"))
+	if locale == "" {
+		locale = userLocale(c)
+	}
+	if locale == "" {
+		locale = vocab.Negotiate(c.GetHeader("Accept-Language"))
+	}
+	if locale == "" {
+		locale = vocab.DefaultLocale
+	}
+	c.Set("locale", locale)
+	return locale
+}
+
+// userLocale is the preferred locale of the logged in user, if any. Routes
+// without AuthMiddleware look the user up from the session cookie.
+func userLocale(c *gin.Context) string {
+	if user, ok := GetUserFromContext(c); ok {
+		return vocab.Match(user.Locale)
+	}
+	sessionID := sessionIDFromRequest(c)
+	if sessionID == "" || db.Client == nil {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, _, err := UserFromSessionID(ctx, sessionID)
+	if err != nil || user == nil {
+		return ""
+	}
+	return vocab.Match(user.Locale)
+}
+
+// canonicalTerm is the identifier of s if it names a term of kind.
+func canonicalTerm(kind vocab.Kind, s string) string {
+	s = strings.TrimSpace(s)
+	if id, ok := vocab.Default().ID(kind, s); ok {
+		return id
+	}
+	return s
+}
+
+// canonicalSkill is canonicalTerm for skills; accelerated sheets list
+// approaches in their place.
+func canonicalSkill(s string) string {
+	s = strings.TrimSpace(s)
+	if id, ok := vocab.Default().ID(vocab.Skill, s); ok {
+		return id
+	}
+	if id, ok := vocab.Default().ID(vocab.Approach, s); ok {
+		return id
+	}
+	return s
+}
+
+func skillName(id, locale string) string {
+	if name := vocab.Default().Name(vocab.Skill, id, locale); name != id {
+		return name
+	}
+	return vocab.Default().Name(vocab.Approach, id, locale)
+}
+
+// rewriteSheet applies term and skill to every game term of a sheet and reports
+// whether anything changed.
+func rewriteSheet(ch *models.Character, term func(vocab.Kind, string) string, skill func(string) string) bool {
+	changed := false
+	set := func(p *string, v string) {
+		if *p != v {
+			*p = v
+			changed = true
+		}
+	}
+	for i := range ch.Aspects {
+		set(&ch.Aspects[i].Type, term(vocab.AspectType, ch.Aspects[i].Type))
+	}
+	for i := range ch.Skills {
+		for j := range ch.Skills[i].Skills {
+			set(&ch.Skills[i].Skills[j], skill(ch.Skills[i].Skills[j]))
+		}
+	}
+	for i := range ch.Stress {
+		set(&ch.Stress[i].Type, term(vocab.StressType, ch.Stress[i].Type))
+	}
+	for i := range ch.Consequences {
+		set(&ch.Consequences[i].Type, term(vocab.ConsequenceType, ch.Consequences[i].Type))
+	}
+	for i := range ch.Stunts {
+		if ch.Stunts[i].Skill != "" {
+			set(&ch.Stunts[i].Skill, skill(ch.Stunts[i].Skill))
+		}
+	}
+	return changed
+}
+
+// canonicalizeSheet stores the game terms of a sheet as identifiers.
+func canonicalizeSheet(ch *models.Character) bool {
+	return rewriteSheet(ch, canonicalTerm, canonicalSkill)
+}
+
+// localizeSheet names the game terms of a sheet in locale.
+func localizeSheet(ch *models.Character, locale string) {
+	rewriteSheet(ch,
+		func(kind vocab.Kind, id string) string { return vocab.Default().Name(kind, id, locale) },
+		func(id string) string { return skillName(id, locale) })
+}
+
+// applyTemplateText replaces a template's title and description with their
+// translation into locale, if it has one.
+func applyTemplateText(t *models.Template, locale string) {
+	text, ok := t.Translations[locale]
+	if !ok {
+		return
+	}
+	if text.Title != "" {
+		t.Title = text.Title
+	}
+	if text.Description != "" {
+		t.Description = text.Description
+	}
+}
+
+func localizeTemplate(t *models.Template, locale string) {
+	applyTemplateText(t, locale)
+	localizeSheet(&t.Character, locale)
+}
+
+// localizeField renames doc[key] with name if it is a string.
+func localizeField(doc bson.M, key string, name func(string) string) {
+	if s, ok := doc[key].(string); ok && s != "" {
+		doc[key] = name(s)
+	}
+}
+
+// eachDocument calls fn for every subdocument of the array doc[key].
+func eachDocument(doc bson.M, key string, fn func(bson.M)) {
+	items, _ := doc[key].(bson.A)
+	for _, item := range items {
+		if sub, ok := item.(bson.M); ok {
+			fn(sub)
+		}
+	}
+}
+
+// localizeSheetDocument is localizeSheet for a sheet as a list returns it.
+// Fields left out by ?fields= are skipped.
+func localizeSheetDocument(locale string) func(bson.M) {
+	term := func(kind vocab.Kind) func(string) string {
+		return func(id string) string { return vocab.Default().Name(kind, id, locale) }
+	}
+	skill := func(id string) string { return skillName(id, locale) }
+	return func(doc bson.M) {
+		eachDocument(doc, "aspects", func(a bson.M) { localizeField(a, "type", term(vocab.AspectType)) })
+		eachDocument(doc, "skills", func(g bson.M) {
+			skills, _ := g["skills"].(bson.A)
+			for i, s := range skills {
+				if id, ok := s.(string); ok {
+					skills[i] = skill(id)
+				}
+			}
+		})
+		eachDocument(doc, "stress", func(s bson.M) { localizeField(s, "type", term(vocab.StressType)) })
+		eachDocument(doc, "consequences", func(c bson.M) { localizeField(c, "type", term(vocab.ConsequenceType)) })
+		eachDocument(doc, "stunts", func(s bson.M) { localizeField(s, "skill", skill) })
+	}
+}
+
+// localizeTemplateDocument is localizeTemplate for a template as a list returns
+// it.
+func localizeTemplateDocument(locale string) func(bson.M) {
+	sheet := localizeSheetDocument(locale)
+	return func(doc bson.M) {
+		sheet(doc)
+		translations, _ := doc["translations"].(bson.M)
+		text, _ := translations[locale].(bson.M)
+		for _, key := range []string{"title", "description"} {
+			if s, ok := text[key].(string); ok && s != "" {
+				doc[key] = s
+			}
+		}
+	}
+}
+
+// localizeStuntDocument names the skill of a library stunt in locale.
+func localizeStuntDocument(locale string) func(bson.M) {
+	return func(doc bson.M) {
+		localizeField(doc, "skill", func(id string) string { return skillName(id, locale) })
+	}
+}
+
+// GetVocabulary lists the game terms with their names in the request locale.
+func GetVocabulary(c *gin.Context) {
+	locale := requestLocale(c)
+	v := vocab.Default()
+
+	terms := make(map[vocab.Kind][]VocabularyTerm, len(vocab.Kinds))
+	for _, kind := range vocab.Kinds {
+		list := []VocabularyTerm{}
+		for _, t := range v.Terms(kind) {
+			list = append(list, VocabularyTerm{ID: t.ID, Name: v.Name(kind, t.ID, locale)})
+		}
+		terms[kind] = list
+	}
+
+	c.JSON(http.StatusOK, VocabularyResponse{Locale: locale, Locales: vocab.Locales, Terms: terms})
+}
+
+// UpdateMyLocale sets the locale the caller reads sheets in, overriding their
+// browser's Accept-Language.
+func UpdateMyLocale(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var req UpdateLocaleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	locale := vocab.Match(req.Locale)
+	if req.Locale != "" && locale == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "locale must be one of " + strings.Join(vocab.Locales, ", ")})
+		return
+	}
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
+	}
+
+	update := bson.M{"$set": bson.M{"locale": locale, "updatedAt": time.Now()}}
+	if locale == "" {
+		update = bson.M{"$unset": bson.M{"locale": ""}, "$set": bson.M{"updatedAt": time.Now()}}
+	}
+	result, err := usersCollection().UpdateOne(ctx, bson.M{"_id": currentUserID(c)}, update)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update locale: " + err.Error()})
+		return
+	}
+	if result.MatchedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"locale": locale})
+}
+
+// MigrateVocabulary rewrites the game terms of sheets, templates and library
+// stunts saved before they were stored as identifiers. Run it at startup; it
+// only writes documents that still need it.
+func MigrateVocabulary() {
+	if db.Client == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := migrateVocabulary(ctx); err != nil {
+		log.Printf("vocabulary migration error: %v", err)
+	}
+}
+
+func migrateVocabulary(ctx context.Context) error {
+	for _, name := range []string{"characters", "templates"} {
+		coll := db.Client.Database("main").Collection(name)
+		cur, err := coll.Find(ctx, bson.M{})
+		if err != nil {
+			return err
+		}
+		migrated := 0
+		for cur.Next(ctx) {
+			var sheet models.Character
+			if err := cur.Decode(&sheet); err != nil {
+				cur.Close(ctx)
+				return err
+			}
+			if !canonicalizeSheet(&sheet) {
+				continue
+			}
+			set := bson.M{
+				"aspects":      sheet.Aspects,
+				"skills":       sheet.Skills,
+				"stress":       sheet.Stress,
+				"consequences": sheet.Consequences,
+				"stunts":       sheet.Stunts,
+			}
+			// Templates loaded by hand may have ObjectID ids, so match the stored one.
+			if _, err := coll.UpdateOne(ctx, bson.M{"_id": cur.Current.Lookup("_id")}, bson.M{"$set": set}); err != nil {
+				cur.Close(ctx)
+				return err
+			}
+			migrated++
+		}
+		err = cur.Err()
+		cur.Close(ctx)
+		if err != nil {
+			return err
+		}
+		if migrated > 0 {
+			log.Printf("vocabulary migration: rewrote %d %s", migrated, name)
+		}
+	}
+
+	cur, err := stuntsCollection().Find(ctx, bson.M{"skill": bson.M{"$nin": bson.A{nil, ""}}})
+	if err != nil {
+		return err
+	}
+	var stunts []models.Stunt
+	if err := cur.All(ctx, &stunts); err != nil {
+		return err
+	}
+	for _, s := range stunts {
+		if skill := canonicalSkill(s.Skill); skill != s.Skill {
+			if _, err := stuntsCollection().UpdateOne(ctx, bson.M{"_id": s.ID}, bson.M{"$set": bson.M{"skill": skill}}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"FATE-Vault/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func russianSheet() models.Character {
+	return models.Character{
+		Edition: models.Core,
+		Aspects: []models.Aspect{{Type: "Концепция", Value: "Бывший наёмник"}, {Type: "Своё", Value: "x"}},
+		Skills:  []models.SkillGroup{{Level: "+2", Skills: []string{"Драка", " Атлетика", "Пилотирование"}}},
+		Stress:  []models.Stress{{Type: "Physical"}},
+		Consequences: []models.Consequence{
+			{Type: "Лёгкое", Size: 2},
+		},
+		Stunts: []models.CharacterStunt{{Name: "Увечье", StuntMechanics: models.StuntMechanics{Skill: "Драка"}}},
+	}
+}
+
+func TestCanonicalizeSheet(t *testing.T) {
+	sheet := russianSheet()
+
+	assert.True(t, canonicalizeSheet(&sheet))
+	assert.Equal(t, "highConcept", sheet.Aspects[0].Type)
+	assert.Equal(t, "Своё", sheet.Aspects[1].Type)
+	assert.Equal(t, []string{"fight", "athletics", "Пилотирование"}, sheet.Skills[0].Skills)
+	assert.Equal(t, "physical", sheet.Stress[0].Type)
+	assert.Equal(t, "mild", sheet.Consequences[0].Type)
+	assert.Equal(t, "fight", sheet.Stunts[0].Skill)
+
+	assert.False(t, canonicalizeSheet(&sheet))
+}
+
+func TestLocalizeSheet_RussianSheetInEnglish(t *testing.T) {
+	sheet := russianSheet()
+	canonicalizeSheet(&sheet)
+
+	localizeSheet(&sheet, "en")
+
+	assert.Equal(t, "High Concept", sheet.Aspects[0].Type)
+	assert.Equal(t, []string{"Fight", "Athletics", "Пилотирование"}, sheet.Skills[0].Skills)
+	assert.Equal(t, "Mild", sheet.Consequences[0].Type)
+	assert.Equal(t, "Fight", sheet.Stunts[0].Skill)
+
+	// Sending the English sheet back stores the same identifiers.
+	canonicalizeSheet(&sheet)
+	assert.Equal(t, []string{"fight", "athletics", "Пилотирование"}, sheet.Skills[0].Skills)
+}
+
+func TestLocalizeSheetDocument(t *testing.T) {
+	doc := bson.M{
+		"aspects": bson.A{bson.M{"type": "trouble"}},
+		"skills":  bson.A{bson.M{"level": "+1", "skills": bson.A{"sneaky", "custom"}}},
+		"stunts":  bson.A{bson.M{"skill": "fight"}},
+	}
+
+	localizeSheetDocument("ru")(doc)
+
+	assert.Equal(t, "Проблема", doc["aspects"].(bson.A)[0].(bson.M)["type"])
+	assert.Equal(t, bson.A{"Хитрый", "custom"}, doc["skills"].(bson.A)[0].(bson.M)["skills"])
+	assert.Equal(t, "Драка", doc["stunts"].(bson.A)[0].(bson.M)["skill"])
+}
+
+func TestLocalizeTemplateDocument(t *testing.T) {
+	doc := bson.M{
+		"title":        "Fate Accelerated",
+		"translations": bson.M{"ru": bson.M{"title": "Fate Ускоренный"}},
+	}
+
+	localizeTemplateDocument("en")(doc)
+	assert.Equal(t, "Fate Accelerated", doc["title"])
+
+	localizeTemplateDocument("ru")(doc)
+	assert.Equal(t, "Fate Ускоренный", doc["title"])
+}
+
+func TestValidateTemplate_Translations(t *testing.T) {
+	tmpl := models.Template{
+		Title:        "Core",
+		Character:    models.Character{Edition: models.Core},
+		Translations: map[string]models.TemplateText{"ru": {Title: " Основа "}},
+	}
+	require.NoError(t, validateTemplate(&tmpl))
+	assert.Equal(t, "Основа", tmpl.Translations["ru"].Title)
+
+	tmpl.Translations = map[string]models.TemplateText{"de": {Title: "Grundregeln"}}
+	assert.Error(t, validateTemplate(&tmpl))
+}
+
+func TestRequestLocale(t *testing.T) {
+	cases := map[string]struct {
+		url, acceptLanguage string
+		user                *models.Users
+		want                string
+	}{
+		"default":             {"/", "", nil, "en"},
+		"accept-language":     {"/", "ru-RU,ru;q=0.9,en;q=0.8", nil, "ru"},
+		"unsupported":         {"/", "de-DE", nil, "en"},
+		"query wins":          {"/?locale=en", "ru", &models.Users{Locale: "ru"}, "en"},
+		"user preference":     {"/", "en", &models.Users{Locale: "ru"}, "ru"},
+		"no user preference":  {"/", "ru", &models.Users{}, "ru"},
+		"unsupported ?locale": {"/?locale=de", "ru", nil, "ru"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, tc.url, nil)
+			if tc.acceptLanguage != "" {
+				c.Request.Header.Set("Accept-Language", tc.acceptLanguage)
+			}
+			if tc.user != nil {
+				c.Set("user", *tc.user)
+			}
+			assert.Equal(t, tc.want, requestLocale(c))
+		})
+	}
+}
+
+func TestGetVocabulary(t *testing.T) {
+	router := setupRouter()
+	router.GET("/vocabulary", GetVocabulary)
+
+	req := httptest.NewRequest(http.MethodGet, "/vocabulary", nil)
+	req.Header.Set("Accept-Language", "ru")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp VocabularyResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "ru", resp.Locale)
+	assert.Contains(t, resp.Terms["skill"], VocabularyTerm{ID: "fight", Name: "Драка"})
+	assert.Contains(t, resp.Terms["approach"], VocabularyTerm{ID: "sneaky", Name: "Хитрый"})
+}
+
+func TestUpdateMyLocale_Validation(t *testing.T) {
+	router := setupRouter()
+	router.POST("/users/me/locale", UpdateMyLocale)
+
+	w := serve(router, http.MethodPost, "/users/me/locale", gin.H{"locale": "de"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(router, http.MethodPost, "/users/me/locale", gin.H{"locale": "ru-RU"})
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
@@ -0,0 +1,336 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ThrottleStore counts failed logins per key. Stores shared between instances
+// (Mongo, Redis) make the limits hold across the whole deployment.
+type ThrottleStore interface {
+	// incr records a failure and returns the failure count. Counts are forgotten
+	// once window passes without a new failure.
+	incr(ctx context.Context, key string, window time.Duration) (int64, error)
+	// decr takes back one counted failure.
+	decr(ctx context.Context, key string) error
+	// hold blocks key for d unless it is already blocked, in one step, and
+	// reports whether it did.
+	hold(ctx context.Context, key string, d time.Duration) (bool, error)
+	// block blocks key for exactly d from now, replacing any earlier block; d <= 0
+	// lifts it.
+	block(ctx context.Context, key string, d time.Duration) error
+	blockedFor(ctx context.Context, key string) (time.Duration, error)
+	reset(ctx context.Context, key string) error
+}
+
+type throttleEntry struct {
+	failures     int64
+	expiresAt    time.Time
+	blockedUntil time.Time
+}
+
+type InMemoryThrottleStore struct {
+	mu      sync.Mutex
+	entries map[string]*throttleEntry
+}
+
+func NewInMemoryThrottleStore() *InMemoryThrottleStore {
+	return &InMemoryThrottleStore{entries: make(map[string]*throttleEntry)}
+}
+
+// maxThrottleEntries triggers a sweep of forgotten entries so keys from many
+// different IPs cannot grow the map without bound.
+const maxThrottleEntries = 10000
+
+func (s *InMemoryThrottleStore) entryLocked(key string, now time.Time) *throttleEntry {
+	e, ok := s.entries[key]
+	if ok && now.After(e.expiresAt) && now.After(e.blockedUntil) {
+		delete(s.entries, key)
+		return nil
+	}
+	return e
+}
+
+func (s *InMemoryThrottleStore) incr(ctx context.Context, key string, window time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if len(s.entries) >= maxThrottleEntries {
+		for k := range s.entries {
+			s.entryLocked(k, now)
+		}
+	}
+
+	e := s.entryLocked(key, now)
+	if e == nil || now.After(e.expiresAt) {
+		if e == nil {
+			e = &throttleEntry{}
+			s.entries[key] = e
+		}
+		e.failures = 0
+	}
+	e.failures++
+	e.expiresAt = now.Add(window)
+	return e.failures, nil
+}
+
+func (s *InMemoryThrottleStore) decr(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e := s.entryLocked(key, time.Now()); e != nil && e.failures > 0 {
+		e.failures--
+	}
+	return nil
+}
+
+func (s *InMemoryThrottleStore) hold(ctx context.Context, key string, d time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	e := s.entryLocked(key, now)
+	if e == nil {
+		e = &throttleEntry{}
+		s.entries[key] = e
+	}
+	if now.Before(e.blockedUntil) {
+		return false, nil
+	}
+	e.blockedUntil = now.Add(d)
+	return true, nil
+}
+
+func (s *InMemoryThrottleStore) block(ctx context.Context, key string, d time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.entryLocked(key, time.Now())
+	if e == nil {
+		e = &throttleEntry{}
+		s.entries[key] = e
+	}
+	e.blockedUntil = time.Now().Add(d)
+	return nil
+}
+
+func (s *InMemoryThrottleStore) blockedFor(ctx context.Context, key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.entryLocked(key, time.Now())
+	if e == nil {
+		return 0, nil
+	}
+	if remaining := time.Until(e.blockedUntil); remaining > 0 {
+		return remaining, nil
+	}
+	return 0, nil
+}
+
+func (s *InMemoryThrottleStore) reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// LoginThrottle slows down password guessing. Failures per username are answered
+// with exponentially growing delays and, past LockoutThreshold, a temporary
+// lockout. Failures per client IP only lead to a lockout, at a higher threshold,
+// so users behind a shared address are not slowed down by each other.
+type LoginThrottle struct {
+	store ThrottleStore
+
+	FreeAttempts       int
+	BaseDelay          time.Duration
+	MaxDelay           time.Duration
+	LockoutThreshold   int
+	IPLockoutThreshold int
+	LockoutDuration    time.Duration
+	Window             time.Duration
+}
+
+func NewLoginThrottle(store ThrottleStore) *LoginThrottle {
+	return &LoginThrottle{
+		store:              store,
+		FreeAttempts:       3,
+		BaseDelay:          time.Second,
+		MaxDelay:           5 * time.Minute,
+		LockoutThreshold:   10,
+		IPLockoutThreshold: 50,
+		LockoutDuration:    15 * time.Minute,
+		Window:             time.Hour,
+	}
+}
+
+var loginThrottle *LoginThrottle
+
+// SetLoginThrottle installs the throttle used by AuthUser and CompleteLogin.
+func SetLoginThrottle(t *LoginThrottle) {
+	loginThrottle = t
+}
+
+func envInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return fallback
+}
+
+// NewLoginThrottleFromEnv builds a throttle whose store is selected by
+// LOGIN_THROTTLE_STORE: memory (default), mongo or redis.
+func NewLoginThrottleFromEnv() (*LoginThrottle, error) {
+	var store ThrottleStore
+	switch driver := strings.ToLower(os.Getenv("LOGIN_THROTTLE_STORE")); driver {
+	case "", "memory":
+		store = NewInMemoryThrottleStore()
+	case "mongo", "mongodb":
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		mongoStore, err := NewMongoThrottleStore(ctx)
+		if err != nil {
+			return nil, err
+		}
+		store = mongoStore
+	case "redis":
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		client, err := redisClientFromEnv(ctx)
+		if err != nil {
+			return nil, err
+		}
+		store = NewRedisThrottleStore(client, redisKeyPrefix())
+	default:
+		return nil, fmt.Errorf("unknown LOGIN_THROTTLE_STORE %q (expected memory, mongo or redis)", driver)
+	}
+
+	t := NewLoginThrottle(store)
+	t.FreeAttempts = envInt("LOGIN_FREE_ATTEMPTS", t.FreeAttempts)
+	t.BaseDelay = envDuration("LOGIN_BACKOFF_BASE", t.BaseDelay)
+	t.MaxDelay = envDuration("LOGIN_BACKOFF_MAX", t.MaxDelay)
+	t.LockoutThreshold = envInt("LOGIN_LOCKOUT_THRESHOLD", t.LockoutThreshold)
+	t.IPLockoutThreshold = envInt("LOGIN_IP_LOCKOUT_THRESHOLD", t.IPLockoutThreshold)
+	t.LockoutDuration = envDuration("LOGIN_LOCKOUT_DURATION", t.LockoutDuration)
+	t.Window = envDuration("LOGIN_FAILURE_WINDOW", t.Window)
+	return t, nil
+}
+
+func throttleUserKey(username string) string {
+	return "user:" + strings.ToLower(strings.TrimSpace(username))
+}
+
+func throttleIPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// backoff is the delay imposed after the given number of consecutive failures.
+func (t *LoginThrottle) backoff(failures int64) time.Duration {
+	over := failures - int64(t.FreeAttempts)
+	if over <= 0 {
+		return 0
+	}
+	delay := t.BaseDelay
+	for i := int64(1); i < over; i++ {
+		delay *= 2
+		if delay >= t.MaxDelay {
+			return t.MaxDelay
+		}
+	}
+	return min(delay, t.MaxDelay)
+}
+
+// attemptHold keeps other attempts on a username waiting while one is being
+// verified. It outlasts any login request and is replaced once the attempt ends.
+const attemptHold = 30 * time.Second
+
+// Begin reserves a login attempt before the credentials are checked and returns
+// how long the caller must wait if it is refused. Only one attempt per username
+// is verified at a time, and the attempt counts against the IP straight away, so
+// parallel guesses cannot all get past the limits before a failure is recorded.
+// Every allowed attempt must end with RecordFailure, RecordSuccess or Release.
+func (t *LoginThrottle) Begin(ctx context.Context, username, ip string) (time.Duration, error) {
+	ipKey := throttleIPKey(ip)
+	if wait, err := t.store.blockedFor(ctx, ipKey); err != nil || wait > 0 {
+		return wait, err
+	}
+
+	userKey := throttleUserKey(username)
+	held, err := t.store.hold(ctx, userKey, attemptHold)
+	if err != nil {
+		return 0, err
+	}
+	if !held {
+		// The block may have run out since hold looked; the caller retries at once.
+		wait, err := t.store.blockedFor(ctx, userKey)
+		return max(wait, time.Millisecond), err
+	}
+
+	failures, err := t.store.incr(ctx, ipKey, t.Window)
+	if err != nil {
+		return 0, err
+	}
+	if failures > int64(t.IPLockoutThreshold) {
+		if err := t.store.block(ctx, ipKey, t.LockoutDuration); err != nil {
+			return 0, err
+		}
+		recordAuditEvent(ctx, AuditEvent{
+			Type:    "login.lockout",
+			IP:      ip,
+			Details: map[string]interface{}{"scope": "ip", "failures": failures - 1, "duration": t.LockoutDuration.String()},
+		})
+		if err := t.store.block(ctx, userKey, 0); err != nil {
+			return 0, err
+		}
+		return t.LockoutDuration, nil
+	}
+	return 0, nil
+}
+
+// RecordFailure ends an attempt with wrong credentials and applies backoff or
+// lockout to the username. The IP already counted it in Begin.
+func (t *LoginThrottle) RecordFailure(ctx context.Context, username, ip string) error {
+	userKey := throttleUserKey(username)
+	failures, err := t.store.incr(ctx, userKey, t.Window)
+	if err != nil {
+		return err
+	}
+	delay := t.backoff(failures)
+	if failures >= int64(t.LockoutThreshold) {
+		delay = t.LockoutDuration
+		recordAuditEvent(ctx, AuditEvent{
+			Type:     "login.lockout",
+			Username: username,
+			IP:       ip,
+			Details:  map[string]interface{}{"scope": "username", "failures": failures, "duration": t.LockoutDuration.String()},
+		})
+	}
+	return t.store.block(ctx, userKey, delay)
+}
+
+// Release ends an attempt that was neither right nor wrong, such as a correct
+// password still waiting for its second factor. The username keeps its failures.
+func (t *LoginThrottle) Release(ctx context.Context, username, ip string) error {
+	if err := t.store.decr(ctx, throttleIPKey(ip)); err != nil {
+		return err
+	}
+	return t.store.block(ctx, throttleUserKey(username), 0)
+}
+
+// RecordSuccess ends an attempt with the right credentials and clears the
+// username's failures. Other failures from the IP are left alone so logging into
+// one's own account does not reset guessing against others.
+func (t *LoginThrottle) RecordSuccess(ctx context.Context, username, ip string) error {
+	if err := t.store.decr(ctx, throttleIPKey(ip)); err != nil {
+		return err
+	}
+	return t.store.reset(ctx, throttleUserKey(username))
+}
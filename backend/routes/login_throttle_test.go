@@ -0,0 +1,167 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"FATE-Vault/backend/resp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginThrottle_Backoff(t *testing.T) {
+	throttle := NewLoginThrottle(NewInMemoryThrottleStore())
+	throttle.FreeAttempts = 2
+	throttle.BaseDelay = time.Second
+	throttle.MaxDelay = 5 * time.Second
+
+	assert.Equal(t, time.Duration(0), throttle.backoff(2))
+	assert.Equal(t, time.Second, throttle.backoff(3))
+	assert.Equal(t, 2*time.Second, throttle.backoff(4))
+	assert.Equal(t, 4*time.Second, throttle.backoff(5))
+	assert.Equal(t, 5*time.Second, throttle.backoff(6))
+	assert.Equal(t, 5*time.Second, throttle.backoff(60))
+}
+
+func testLoginThrottle(t *testing.T, store ThrottleStore) {
+	ctx := context.Background()
+	throttle := NewLoginThrottle(store)
+	throttle.FreeAttempts = 2
+	throttle.BaseDelay = 50 * time.Millisecond
+	throttle.LockoutThreshold = 4
+	throttle.IPLockoutThreshold = 100
+
+	fail := func(username, ip string) {
+		wait, err := throttle.Begin(ctx, username, ip)
+		require.NoError(t, err)
+		require.Zero(t, wait)
+		require.NoError(t, throttle.RecordFailure(ctx, username, ip))
+	}
+
+	fail("Alice", "10.0.0.1")
+	fail("alice", "10.0.0.1")
+	wait, err := throttle.Begin(ctx, "alice", "10.0.0.2")
+	require.NoError(t, err)
+	assert.Zero(t, wait, "free attempts are not delayed")
+	require.NoError(t, throttle.RecordFailure(ctx, "alice", "10.0.0.2"))
+
+	wait, err = throttle.Begin(ctx, "ALICE", "10.0.0.2")
+	require.NoError(t, err)
+	assert.Greater(t, wait, time.Duration(0), "usernames are throttled case-insensitively")
+	assert.LessOrEqual(t, wait, throttle.BaseDelay)
+
+	time.Sleep(throttle.BaseDelay + 10*time.Millisecond)
+	fail("alice", "10.0.0.1")
+	wait, err = throttle.Begin(ctx, "alice", "10.0.0.2")
+	require.NoError(t, err)
+	assert.InDelta(t, throttle.LockoutDuration, wait, float64(time.Second))
+
+	wait, err = throttle.Begin(ctx, "bob", "10.0.0.1")
+	require.NoError(t, err)
+	assert.Zero(t, wait, "other users on the same IP are unaffected below the IP threshold")
+	require.NoError(t, throttle.Release(ctx, "bob", "10.0.0.1"))
+
+	require.NoError(t, throttle.RecordSuccess(ctx, "alice", "10.0.0.1"))
+	wait, err = throttle.Begin(ctx, "alice", "10.0.0.1")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+	require.NoError(t, throttle.Release(ctx, "alice", "10.0.0.1"))
+
+	// Parallel guesses wait for the attempt being verified.
+	results := make(chan time.Duration, 10)
+	for i := 0; i < cap(results); i++ {
+		go func() {
+			wait, err := throttle.Begin(ctx, "carol", "10.0.0.3")
+			assert.NoError(t, err)
+			results <- wait
+		}()
+	}
+	allowed := 0
+	for i := 0; i < cap(results); i++ {
+		if <-results == 0 {
+			allowed++
+		}
+	}
+	assert.Equal(t, 1, allowed, "only one parallel attempt per username is verified")
+}
+
+func TestLoginThrottle_InMemory(t *testing.T) {
+	testLoginThrottle(t, NewInMemoryThrottleStore())
+}
+
+func TestLoginThrottle_Redis(t *testing.T) {
+	server, err := resp.NewFakeServer()
+	require.NoError(t, err)
+	t.Cleanup(func() { server.Close() })
+	client := resp.NewClient(server.Addr(), "", 0)
+	t.Cleanup(func() { client.Close() })
+
+	testLoginThrottle(t, NewRedisThrottleStore(client, "test:"))
+}
+
+func TestLoginThrottle_LocksOutIP(t *testing.T) {
+	ctx := context.Background()
+	throttle := NewLoginThrottle(NewInMemoryThrottleStore())
+	throttle.IPLockoutThreshold = 3
+
+	for _, username := range []string{"a", "b", "c"} {
+		wait, err := throttle.Begin(ctx, username, "10.0.0.9")
+		require.NoError(t, err)
+		require.Zero(t, wait)
+		require.NoError(t, throttle.RecordFailure(ctx, username, "10.0.0.9"))
+	}
+
+	wait, err := throttle.Begin(ctx, "d", "10.0.0.9")
+	require.NoError(t, err)
+	assert.Greater(t, wait, time.Duration(0))
+}
+
+func TestLoginThrottle_SuccessDoesNotCountAgainstIP(t *testing.T) {
+	ctx := context.Background()
+	throttle := NewLoginThrottle(NewInMemoryThrottleStore())
+	throttle.IPLockoutThreshold = 2
+
+	for _, username := range []string{"a", "b", "c", "d"} {
+		wait, err := throttle.Begin(ctx, username, "10.0.0.9")
+		require.NoError(t, err)
+		require.Zero(t, wait, username)
+		require.NoError(t, throttle.RecordSuccess(ctx, username, "10.0.0.9"))
+	}
+}
+
+func TestBeginLoginAttempt(t *testing.T) {
+	previous := loginThrottle
+	t.Cleanup(func() { SetLoginThrottle(previous) })
+	SetLoginThrottle(NewLoginThrottle(NewInMemoryThrottleStore()))
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/users/auth/2fa", nil)
+	assert.True(t, beginLoginAttempt(context.Background(), c, "alice"))
+
+	// The first attempt has not ended yet.
+	w := httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/users/auth/2fa", nil)
+	assert.False(t, beginLoginAttempt(context.Background(), c, "alice"))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+}
+
+func TestInMemoryThrottleStore_ForgetsAfterWindow(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryThrottleStore()
+
+	n, err := store.incr(ctx, "k", time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	time.Sleep(5 * time.Millisecond)
+	n, err = store.incr(ctx, "k", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
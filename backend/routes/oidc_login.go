@@ -0,0 +1,424 @@
+package routes
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"FATE-Vault/backend/db"
+	"FATE-Vault/backend/models"
+	"FATE-Vault/backend/oidc"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// OIDCClient logs users in through an external OpenID provider. The provider is
+// discovered on first use so the backend can start while the provider is down.
+type OIDCClient struct {
+	Issuer       string
+	Config       oidc.Config
+	PostLoginURL string
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+var oidcClient *OIDCClient
+
+// SetOIDCClient installs the OIDC client; nil disables OIDC login.
+func SetOIDCClient(c *OIDCClient) {
+	oidcClient = c
+}
+
+// NewOIDCClientFromEnv returns nil when OIDC_ISSUER is not set.
+func NewOIDCClientFromEnv() (*OIDCClient, error) {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return nil, nil
+	}
+	clientID := os.Getenv("OIDC_CLIENT_ID")
+	if clientID == "" {
+		return nil, errors.New("OIDC_CLIENT_ID is required when OIDC_ISSUER is set")
+	}
+
+	redirectURL := os.Getenv("OIDC_REDIRECT_URL")
+	if redirectURL == "" {
+		redirectURL = "http://localhost:8080/auth/oidc/callback"
+	}
+	scopes := strings.Fields(os.Getenv("OIDC_SCOPES"))
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "profile", "email"}
+	}
+	postLogin := os.Getenv("OIDC_POST_LOGIN_URL")
+	if postLogin == "" {
+		postLogin = os.Getenv("WEB_ORIGIN")
+	}
+	if postLogin == "" {
+		postLogin = "http://localhost:3000"
+	}
+
+	return &OIDCClient{
+		Issuer: issuer,
+		Config: oidc.Config{
+			ClientID:     clientID,
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  redirectURL,
+			Scopes:       scopes,
+		},
+		PostLoginURL: postLogin,
+	}, nil
+}
+
+func (o *OIDCClient) getProvider(ctx context.Context) (*oidc.Provider, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.provider != nil {
+		return o.provider, nil
+	}
+	p, err := oidc.Discover(ctx, o.Issuer, nil)
+	if err != nil {
+		return nil, err
+	}
+	o.provider = p
+	return p, nil
+}
+
+// pendingOIDCLogin is kept in a short-lived HttpOnly cookie between the redirect
+// to the provider and the callback, binding the callback to this browser.
+type pendingOIDCLogin struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Invite   string `json:"invite,omitempty"`
+	Link     bool   `json:"link,omitempty"`
+}
+
+const (
+	oidcCookieName = "oidc_login"
+	oidcCookiePath = "/auth/oidc"
+	oidcCookieTTL  = 10 * time.Minute
+)
+
+func setOIDCCookie(c *gin.Context, value string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oidcCookieName,
+		Value:    value,
+		Path:     oidcCookiePath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   sessionCookieSecure(),
+		// Lax is required: the callback arrives as a cross-site top-level navigation.
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func readOIDCCookie(c *gin.Context) (*pendingOIDCLogin, bool) {
+	raw, err := c.Cookie(oidcCookieName)
+	if err != nil || raw == "" {
+		return nil, false
+	}
+	b, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, false
+	}
+	var pending pendingOIDCLogin
+	if err := json.Unmarshal(b, &pending); err != nil || pending.State == "" {
+		return nil, false
+	}
+	return &pending, true
+}
+
+// redirectAfterOIDC sends the browser back to the web app with the given query parameters.
+func redirectAfterOIDC(c *gin.Context, params url.Values) {
+	target, err := url.Parse(oidcClient.PostLoginURL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid OIDC_POST_LOGIN_URL"})
+		return
+	}
+	q := target.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	target.RawQuery = q.Encode()
+	c.Redirect(http.StatusFound, target.String())
+}
+
+func redirectOIDCError(c *gin.Context, message string) {
+	redirectAfterOIDC(c, url.Values{"loginError": {message}})
+}
+
+// StartOIDCLogin redirects to the provider. ?invite= carries an invite code for
+// invite-only registration; ?link=true links the identity to the logged-in account.
+func StartOIDCLogin(c *gin.Context) {
+	if oidcClient == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "OIDC login is not configured"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	provider, err := oidcClient.getProvider(ctx)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "identity provider unavailable: " + err.Error()})
+		return
+	}
+
+	pending := pendingOIDCLogin{
+		Invite: c.Query("invite"),
+		Link:   c.Query("link") == "true",
+	}
+	for _, v := range []*string{&pending.State, &pending.Nonce, &pending.Verifier} {
+		if *v, err = oidc.RandomString(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start login: " + err.Error()})
+			return
+		}
+	}
+
+	raw, err := json.Marshal(pending)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start login: " + err.Error()})
+		return
+	}
+	setOIDCCookie(c, base64.RawURLEncoding.EncodeToString(raw), int(oidcCookieTTL/time.Second))
+	c.Redirect(http.StatusFound, provider.AuthCodeURL(oidcClient.Config, pending.State, pending.Nonce, pending.Verifier))
+}
+
+// FinishOIDCLogin handles the provider's callback: it verifies the response, finds
+// or creates the linked user and issues the session cookie.
+func FinishOIDCLogin(c *gin.Context) {
+	if oidcClient == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "OIDC login is not configured"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pending, ok := readOIDCCookie(c)
+	setOIDCCookie(c, "", -1)
+	if !ok {
+		redirectOIDCError(c, "login expired, please try again")
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(c.Query("state")), []byte(pending.State)) != 1 {
+		redirectOIDCError(c, "login state mismatch, please try again")
+		return
+	}
+	if e := c.Query("error"); e != "" {
+		redirectOIDCError(c, "identity provider returned "+e)
+		return
+	}
+
+	provider, err := oidcClient.getProvider(ctx)
+	if err != nil {
+		redirectOIDCError(c, "identity provider unavailable")
+		return
+	}
+	claims, err := provider.Exchange(ctx, oidcClient.Config, c.Query("code"), pending.Verifier, pending.Nonce)
+	if err != nil {
+		log.Printf("oidc exchange failed: %v", err)
+		redirectOIDCError(c, "could not verify the identity provider response")
+		return
+	}
+
+	if db.Client == nil {
+		redirectOIDCError(c, "database connection not available")
+		return
+	}
+
+	identity := models.ExternalIdentity{
+		Issuer:   claims.Issuer,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+		LinkedAt: time.Now(),
+	}
+
+	if pending.Link {
+		linkOIDCIdentity(ctx, c, identity)
+		return
+	}
+
+	user, err := findUserByIdentity(ctx, identity)
+	if err == mongo.ErrNoDocuments {
+		user, err = createOIDCUser(ctx, identity, claims, pending.Invite)
+	}
+	if err != nil {
+		if isRegistrationDenied(err) {
+			redirectOIDCError(c, err.Error())
+			return
+		}
+		log.Printf("oidc login failed: %v", err)
+		redirectOIDCError(c, "login failed")
+		return
+	}
+
+	if user.TOTPEnabled {
+		token, _, err := createLoginChallenge(ctx, user.ID)
+		if err != nil {
+			redirectOIDCError(c, "login failed")
+			return
+		}
+		// The challenge goes in a cookie rather than the URL, which would leak it
+		// into browser history and Referer headers. /users/auth/2fa reads it.
+		setChallengeCookie(c, token, int(loginChallengeTTL()/time.Second))
+		redirectAfterOIDC(c, url.Values{"twoFactorRequired": {"true"}})
+		return
+	}
+
+	if err := startSession(c, user); err != nil {
+		redirectOIDCError(c, "failed to create session")
+		return
+	}
+	redirectAfterOIDC(c, url.Values{})
+}
+
+// EnsureIdentityIndex makes (issuer, subject) unique among linked identities, so
+// two first logins of the same identity cannot create two accounts. Run it at
+// startup, before the OIDC routes serve requests.
+func EnsureIdentityIndex() error {
+	if db.Client == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := usersCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "identities.issuer", Value: 1}, {Key: "identities.subject", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"identities": bson.M{"$exists": true}}),
+	})
+	return err
+}
+
+func identityFilter(identity models.ExternalIdentity) bson.M {
+	return bson.M{"identities": bson.M{"$elemMatch": bson.M{"issuer": identity.Issuer, "subject": identity.Subject}}}
+}
+
+func findUserByIdentity(ctx context.Context, identity models.ExternalIdentity) (*models.Users, error) {
+	var user models.Users
+	if err := usersCollection().FindOne(ctx, identityFilter(identity)).Decode(&user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// linkOIDCIdentity attaches the identity to the account of the current session.
+func linkOIDCIdentity(ctx context.Context, c *gin.Context, identity models.ExternalIdentity) {
+	sessionID := sessionIDFromRequest(c)
+	if sessionID == "" {
+		redirectOIDCError(c, "log in before linking an identity")
+		return
+	}
+	user, _, err := UserFromSessionID(ctx, sessionID)
+	if err != nil {
+		redirectOIDCError(c, "log in before linking an identity")
+		return
+	}
+
+	existing, err := findUserByIdentity(ctx, identity)
+	if err == nil && existing.ID != user.ID {
+		redirectOIDCError(c, "this identity is already linked to another account")
+		return
+	}
+	if err != nil && err != mongo.ErrNoDocuments {
+		redirectOIDCError(c, "failed to link identity")
+		return
+	}
+
+	if err == mongo.ErrNoDocuments {
+		_, err = usersCollection().UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$push": bson.M{"identities": identity}})
+		if err != nil {
+			redirectOIDCError(c, "failed to link identity")
+			return
+		}
+	}
+	redirectAfterOIDC(c, url.Values{"linked": {"true"}})
+}
+
+var usernameUnsafe = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// oidcUsernameCandidate picks a readable username from the provider's claims.
+func oidcUsernameCandidate(claims *oidc.Claims) string {
+	candidate := claims.PreferredUsername
+	if candidate == "" && claims.Email != "" {
+		candidate = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	if candidate == "" {
+		candidate = claims.Name
+	}
+	candidate = strings.Trim(usernameUnsafe.ReplaceAllString(candidate, "-"), "-.")
+	if len(candidate) > 32 {
+		candidate = candidate[:32]
+	}
+	if candidate == "" {
+		candidate = "user"
+	}
+	return candidate
+}
+
+func uniqueUsername(ctx context.Context, base string) (string, error) {
+	for i := 1; i <= 20; i++ {
+		candidate := base
+		if i > 1 {
+			candidate = fmt.Sprintf("%s-%d", base, i)
+		}
+		n, err := usersCollection().CountDocuments(ctx, bson.M{"username": candidate})
+		if err != nil {
+			return "", err
+		}
+		if n == 0 {
+			return candidate, nil
+		}
+	}
+	return base + "-" + uuid.NewString()[:8], nil
+}
+
+// createOIDCUser registers an account for a first-time OIDC login. It has no
+// password, so it logs in through the provider unless an admin reset sets one.
+func createOIDCUser(ctx context.Context, identity models.ExternalIdentity, claims *oidc.Claims, inviteCode string) (*models.Users, error) {
+	// EnsureIdentityIndex keeps two first logins of the same identity from
+	// creating two accounts.
+	username, err := uniqueUsername(ctx, oidcUsernameCandidate(claims))
+	if err != nil {
+		return nil, err
+	}
+
+	admission, err := admitRegistration(ctx, inviteCode)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user := models.Users{
+		ID:         uuid.NewString(),
+		Username:   username,
+		Role:       admission.role(),
+		Identities: []models.ExternalIdentity{identity},
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if _, err := usersCollection().InsertOne(ctx, user); err != nil {
+		admission.release(ctx)
+		if mongo.IsDuplicateKeyError(err) {
+			return findUserByIdentity(ctx, identity)
+		}
+		return nil, err
+	}
+	return &user, nil
+}
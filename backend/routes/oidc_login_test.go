@@ -0,0 +1,150 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"FATE-Vault/backend/oidc"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupOIDCRouter(t *testing.T) (*gin.Engine, *oidc.MockProvider) {
+	t.Helper()
+	mock, err := oidc.NewMockProvider("vault")
+	require.NoError(t, err)
+	t.Cleanup(mock.Close)
+
+	previous := oidcClient
+	SetOIDCClient(&OIDCClient{
+		Issuer:       mock.Issuer(),
+		Config:       oidc.Config{ClientID: "vault", RedirectURL: "http://backend.test/auth/oidc/callback", Scopes: []string{"openid"}},
+		PostLoginURL: "http://web.test/after-login",
+	})
+	t.Cleanup(func() { SetOIDCClient(previous) })
+
+	router := setupRouter()
+	router.GET("/auth/oidc/login", StartOIDCLogin)
+	router.GET("/auth/oidc/callback", FinishOIDCLogin)
+	return router, mock
+}
+
+func TestStartOIDCLogin_RedirectsWithPKCE(t *testing.T) {
+	router, mock := setupOIDCRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/oidc/login", nil))
+
+	require.Equal(t, http.StatusFound, w.Code)
+	loc, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, mock.Issuer()+"/authorize", loc.Scheme+"://"+loc.Host+loc.Path)
+	assert.Equal(t, "S256", loc.Query().Get("code_challenge_method"))
+	assert.NotEmpty(t, loc.Query().Get("code_challenge"))
+	assert.NotEmpty(t, loc.Query().Get("nonce"))
+
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, oidcCookieName, cookies[0].Name)
+	assert.True(t, cookies[0].HttpOnly)
+	pending, ok := readOIDCCookie(cookieContext(cookies[0]))
+	require.True(t, ok)
+	assert.Equal(t, loc.Query().Get("state"), pending.State)
+	assert.Equal(t, oidc.CodeChallenge(pending.Verifier), loc.Query().Get("code_challenge"))
+}
+
+func cookieContext(cookie *http.Cookie) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	c.Request.AddCookie(cookie)
+	return c
+}
+
+// runOIDCFlow starts a login, lets the mock provider approve it and returns the callback request.
+func runOIDCFlow(t *testing.T, router *gin.Engine) *http.Request {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/oidc/login", nil))
+	require.Equal(t, http.StatusFound, w.Code)
+
+	noFollow := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := noFollow.Get(w.Header().Get("Location"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?"+callback.RawQuery, nil)
+	for _, cookie := range w.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	return req
+}
+
+func loginErrorFrom(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	require.Equal(t, http.StatusFound, w.Code)
+	loc, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "web.test", loc.Host)
+	return loc.Query().Get("loginError")
+}
+
+func TestFinishOIDCLogin_VerifiesProviderResponse(t *testing.T) {
+	router, _ := setupOIDCRouter(t)
+	req := runOIDCFlow(t, router)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// The code exchange and ID token checks pass; without a database the flow stops
+	// at the user lookup.
+	assert.Equal(t, "database connection not available", loginErrorFrom(t, w))
+}
+
+func TestFinishOIDCLogin_RejectsStateMismatch(t *testing.T) {
+	router, _ := setupOIDCRouter(t)
+	req := runOIDCFlow(t, router)
+	q := req.URL.Query()
+	q.Set("state", "forged")
+	req.URL.RawQuery = q.Encode()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Contains(t, loginErrorFrom(t, w), "state mismatch")
+}
+
+func TestFinishOIDCLogin_RequiresLoginCookie(t *testing.T) {
+	router, _ := setupOIDCRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?code=x&state=y", nil))
+
+	assert.Contains(t, loginErrorFrom(t, w), "expired")
+}
+
+func TestOIDCUsernameCandidate(t *testing.T) {
+	assert.Equal(t, "alice", oidcUsernameCandidate(&oidc.Claims{PreferredUsername: "alice", Email: "bob@example.com"}))
+	assert.Equal(t, "bob.smith", oidcUsernameCandidate(&oidc.Claims{Email: "bob.smith@example.com"}))
+	assert.Equal(t, "Carol-Danvers", oidcUsernameCandidate(&oidc.Claims{Name: "Carol Danvers"}))
+	assert.Equal(t, "user", oidcUsernameCandidate(&oidc.Claims{Name: "!!!"}))
+}
+
+func TestStartOIDCLogin_NotConfigured(t *testing.T) {
+	previous := oidcClient
+	SetOIDCClient(nil)
+	t.Cleanup(func() { SetOIDCClient(previous) })
+
+	router := setupRouter()
+	router.GET("/auth/oidc/login", StartOIDCLogin)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/oidc/login", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,114 @@
+package routes
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+//go:embed common_passwords.txt
+var commonPasswords string
+
+// PasswordPolicy decides which new passwords are acceptable.
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+	breached  map[string]struct{}
+}
+
+// bcrypt ignores everything past 72 bytes, so longer passwords give a false sense of strength.
+const bcryptMaxPasswordBytes = 72
+
+var passwordPolicy = defaultPasswordPolicy()
+
+func defaultPasswordPolicy() *PasswordPolicy {
+	return &PasswordPolicy{
+		MinLength: 8,
+		MaxLength: bcryptMaxPasswordBytes,
+		breached:  parsePasswordList(commonPasswords),
+	}
+}
+
+// SetPasswordPolicy installs the policy used by registration, password change and reset.
+func SetPasswordPolicy(p *PasswordPolicy) {
+	passwordPolicy = p
+}
+
+// LoadPasswordPolicyFromEnv reads PASSWORD_MIN_LENGTH, PASSWORD_MAX_LENGTH and
+// PASSWORD_BREACHED_LIST (a file with one password per line, added to the built-in list).
+func LoadPasswordPolicyFromEnv() (*PasswordPolicy, error) {
+	p := defaultPasswordPolicy()
+
+	if v := os.Getenv("PASSWORD_MIN_LENGTH"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid PASSWORD_MIN_LENGTH %q", v)
+		}
+		p.MinLength = n
+	}
+	if v := os.Getenv("PASSWORD_MAX_LENGTH"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < p.MinLength || n > bcryptMaxPasswordBytes {
+			return nil, fmt.Errorf("invalid PASSWORD_MAX_LENGTH %q (must be between PASSWORD_MIN_LENGTH and %d)", v, bcryptMaxPasswordBytes)
+		}
+		p.MaxLength = n
+	}
+
+	if path := os.Getenv("PASSWORD_BREACHED_LIST"); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("open PASSWORD_BREACHED_LIST: %w", err)
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if line := normalizeBreachedPassword(scanner.Text()); line != "" {
+				p.breached[line] = struct{}{}
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("read PASSWORD_BREACHED_LIST: %w", err)
+		}
+	}
+
+	return p, nil
+}
+
+func normalizeBreachedPassword(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+func parsePasswordList(list string) map[string]struct{} {
+	result := make(map[string]struct{})
+	for _, line := range strings.Split(list, "\n") {
+		if line = normalizeBreachedPassword(line); line != "" && !strings.HasPrefix(line, "#") {
+			result[line] = struct{}{}
+		}
+	}
+	return result
+}
+
+// Validate returns a user-facing error when password is not acceptable for username.
+// MinLength counts characters; MaxLength counts bytes because that is what bcrypt keeps.
+func (p *PasswordPolicy) Validate(password, username string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return fmt.Errorf("password must be at least %d characters", p.MinLength)
+	}
+	if len(password) > p.MaxLength {
+		return fmt.Errorf("password must be at most %d bytes", p.MaxLength)
+	}
+	normalized := normalizeBreachedPassword(password)
+	if username != "" && normalized == strings.ToLower(username) {
+		return errors.New("password must not match the username")
+	}
+	if _, ok := p.breached[normalized]; ok {
+		return errors.New("password is too common; it appears in a list of breached passwords")
+	}
+	return nil
+}
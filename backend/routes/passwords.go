@@ -0,0 +1,259 @@
+package routes
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"FATE-Vault/backend/db"
+	"FATE-Vault/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+	NewPassword     string `json:"newPassword" binding:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required"`
+}
+
+// passwordReset is a pending admin-issued reset. Only the SHA-256 of the token is
+// stored, so a database leak does not hand out working reset links.
+type passwordReset struct {
+	TokenHash string    `bson:"_id"`
+	UserID    string    `bson:"userId"`
+	IssuedBy  string    `bson:"issuedBy"`
+	CreatedAt time.Time `bson:"createdAt"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+func passwordResetTTL() time.Duration {
+	return envDuration("PASSWORD_RESET_TTL", time.Hour)
+}
+
+func generateSecretToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashSecretToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// storeNewPassword hashes and saves the password for the user.
+func storeNewPassword(ctx context.Context, user *models.Users, password string) error {
+	if err := user.SetPassword(password); err != nil {
+		return err
+	}
+	coll := db.Client.Database("main").Collection("users")
+	_, err := coll.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{
+		"hashedPassword": user.HashedPassword,
+		"updatedAt":      time.Now(),
+	}})
+	return err
+}
+
+// ChangePassword lets the caller set a new password after proving the current one.
+// Every other session of the user is revoked.
+func ChangePassword(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
+	}
+
+	userID := currentUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	coll := db.Client.Database("main").Collection("users")
+	var user models.Users
+	if err := coll.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to find user: " + err.Error()})
+		return
+	}
+
+	if !user.CheckPassword(req.CurrentPassword) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "current password is incorrect"})
+		return
+	}
+	if err := passwordPolicy.Validate(req.NewPassword, user.Username); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := storeNewPassword(ctx, &user, req.NewPassword); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update password: " + err.Error()})
+		return
+	}
+	if _, err := sessionManager.DestroyAllForUser(ctx, userID, currentSessionID(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions: " + err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// IssuePasswordReset lets an admin create a one-time reset token for a user.
+// The token is returned once and replaces any earlier unused token.
+func IssuePasswordReset(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id parameter is required"})
+		return
+	}
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
+	}
+
+	users := db.Client.Database("main").Collection("users")
+	var user models.Users
+	if err := users.FindOne(ctx, bson.M{"_id": id}).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to find user: " + err.Error()})
+		return
+	}
+
+	token, err := generateSecretToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token: " + err.Error()})
+		return
+	}
+
+	now := time.Now()
+	reset := passwordReset{
+		TokenHash: hashSecretToken(token),
+		UserID:    user.ID,
+		IssuedBy:  currentUserID(c),
+		CreatedAt: now,
+		ExpiresAt: now.Add(passwordResetTTL()),
+	}
+
+	resets := db.Client.Database("main").Collection("password_resets")
+	// Expired tokens are also rejected on use; the TTL index just keeps the collection small.
+	_, err = resets.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to prepare reset tokens: " + err.Error()})
+		return
+	}
+	if _, err := resets.DeleteMany(ctx, bson.M{"userId": user.ID}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to replace reset token: " + err.Error()})
+		return
+	}
+	if _, err := resets.InsertOne(ctx, reset); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store reset token: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"userId":    user.ID,
+		"token":     token,
+		"expiresAt": reset.ExpiresAt,
+	})
+}
+
+// ResetPassword consumes a reset token and sets a new password. All of the user's
+// sessions and API tokens are revoked, so they must log in again.
+func ResetPassword(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
+	}
+
+	resets := db.Client.Database("main").Collection("password_resets")
+	var reset passwordReset
+	err := resets.FindOne(ctx, bson.M{"_id": hashSecretToken(req.Token)}).Decode(&reset)
+	if err == mongo.ErrNoDocuments || (err == nil && time.Now().After(reset.ExpiresAt)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired reset token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to find reset token: " + err.Error()})
+		return
+	}
+
+	users := db.Client.Database("main").Collection("users")
+	var user models.Users
+	if err := users.FindOne(ctx, bson.M{"_id": reset.UserID}).Decode(&user); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired reset token"})
+		return
+	}
+
+	// Validate before consuming the token so a rejected password can be retried.
+	if err := passwordPolicy.Validate(req.NewPassword, user.Username); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Deleting the token is what makes it single-use, even under concurrent requests.
+	result, err := resets.DeleteOne(ctx, bson.M{"_id": reset.TokenHash})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to consume reset token: " + err.Error()})
+		return
+	}
+	if result.DeletedCount == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired reset token"})
+		return
+	}
+
+	if err := storeNewPassword(ctx, &user, req.NewPassword); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update password: " + err.Error()})
+		return
+	}
+	if _, err := sessionManager.DestroyAllForUser(ctx, user.ID, ""); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions: " + err.Error()})
+		return
+	}
+	if _, err := apiTokensCollection().DeleteMany(ctx, bson.M{"userId": user.ID}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke API tokens: " + err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
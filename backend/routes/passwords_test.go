@@ -0,0 +1,75 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	p := defaultPasswordPolicy()
+
+	assert.Error(t, p.Validate("short", "alice"))
+	assert.Error(t, p.Validate("Password123", "alice"), "breached passwords are rejected case-insensitively")
+	assert.Error(t, p.Validate("AliceInChains", "aliceinchains"))
+	assert.Error(t, p.Validate(string(make([]byte, 73)), "alice"))
+	assert.Error(t, p.Validate("пароль", "alice"), "length is counted in characters, not bytes")
+	assert.NoError(t, p.Validate("кот-учёный", "alice"))
+	assert.NoError(t, p.Validate("correct horse battery staple", "alice"))
+}
+
+func TestLoadPasswordPolicyFromEnv(t *testing.T) {
+	list := filepath.Join(t.TempDir(), "breached.txt")
+	require.NoError(t, os.WriteFile(list, []byte("Tr0ub4dor&3\n\n"), 0o644))
+	t.Setenv("PASSWORD_MIN_LENGTH", "12")
+	t.Setenv("PASSWORD_BREACHED_LIST", list)
+
+	p, err := LoadPasswordPolicyFromEnv()
+	require.NoError(t, err)
+
+	assert.Equal(t, 12, p.MinLength)
+	assert.Error(t, p.Validate("elevenchars", "alice"))
+	assert.Error(t, p.Validate("tr0ub4dor&3 ", "alice"), "entries from the file are checked")
+	assert.Error(t, p.Validate("password1234", "alice"), "built-in list stays active")
+}
+
+func TestLoadPasswordPolicyFromEnv_RejectsInvalidLimits(t *testing.T) {
+	t.Setenv("PASSWORD_MAX_LENGTH", "100")
+
+	_, err := LoadPasswordPolicyFromEnv()
+	assert.Error(t, err)
+}
+
+func TestHashResetToken_IsStable(t *testing.T) {
+	token, err := generateSecretToken()
+	require.NoError(t, err)
+
+	assert.Equal(t, hashSecretToken(token), hashSecretToken(token))
+	assert.NotEqual(t, token, hashSecretToken(token))
+	assert.Len(t, hashSecretToken(token), 64)
+}
+
+func TestResetPassword_RequiresTokenAndPassword(t *testing.T) {
+	router := setupRouter()
+	router.POST("/users/password/reset", ResetPassword)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, createTestRequest(http.MethodPost, "/users/password/reset", map[string]string{"token": "abc"}))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestChangePassword_RequiresBothPasswords(t *testing.T) {
+	router := setupRouter()
+	router.POST("/users/me/password", ChangePassword)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, createTestRequest(http.MethodPost, "/users/me/password", map[string]string{"newPassword": "a new passphrase"}))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
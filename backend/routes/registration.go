@@ -0,0 +1,336 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"FATE-Vault/backend/db"
+	"FATE-Vault/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	RegistrationOpen   = "open"
+	RegistrationInvite = "invite"
+	RegistrationClosed = "closed"
+)
+
+// registrationMode reads REGISTRATION_MODE: open (default), invite or closed.
+func registrationMode() string {
+	switch strings.ToLower(os.Getenv("REGISTRATION_MODE")) {
+	case "invite", "invite-only":
+		return RegistrationInvite
+	case "closed":
+		return RegistrationClosed
+	default:
+		return RegistrationOpen
+	}
+}
+
+var errInvalidInvite = errors.New("invalid or expired invite code")
+
+// Invite lets someone register while REGISTRATION_MODE=invite. Only the hash of
+// the code is stored; the code itself is shown once when the invite is created.
+type Invite struct {
+	ID        string    `json:"id" bson:"_id"`
+	CodeHash  string    `json:"-" bson:"codeHash"`
+	CreatedBy string    `json:"createdBy" bson:"createdBy"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt" bson:"expiresAt"`
+	MaxUses   int       `json:"maxUses" bson:"maxUses"`
+	Uses      int       `json:"uses" bson:"uses"`
+}
+
+type CreateInviteRequest struct {
+	MaxUses   int    `json:"maxUses,omitempty"`
+	ExpiresIn string `json:"expiresIn,omitempty"`
+}
+
+func inviteTTL() time.Duration {
+	return envDuration("INVITE_TTL", 7*24*time.Hour)
+}
+
+func invitesCollection() *mongo.Collection {
+	return db.Client.Database("main").Collection("invites")
+}
+
+// consumeInvite atomically uses up one use of the invite with the given code.
+func consumeInvite(ctx context.Context, code string) (*Invite, error) {
+	filter := bson.M{
+		"codeHash":  hashSecretToken(code),
+		"expiresAt": bson.M{"$gt": time.Now()},
+		"$expr":     bson.M{"$lt": bson.A{"$uses", "$maxUses"}},
+	}
+	var invite Invite
+	err := invitesCollection().FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{"uses": 1}}).Decode(&invite)
+	if err == mongo.ErrNoDocuments {
+		return nil, errInvalidInvite
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+// releaseInvite gives back a use taken by consumeInvite when registration fails afterwards.
+func releaseInvite(ctx context.Context, invite *Invite) error {
+	_, err := invitesCollection().UpdateOne(ctx, bson.M{"_id": invite.ID, "uses": bson.M{"$gt": 0}}, bson.M{"$inc": bson.M{"uses": -1}})
+	return err
+}
+
+// claimBootstrapAdmin reports whether this registration is the very first one and
+// should therefore become the admin. A marker document makes the claim atomic, so
+// concurrent first registrations cannot both win.
+func claimBootstrapAdmin(ctx context.Context) (bool, error) {
+	count, err := db.Client.Database("main").Collection("users").EstimatedDocumentCount(ctx)
+	if err != nil || count > 0 {
+		return false, err
+	}
+
+	settings := db.Client.Database("main").Collection("settings")
+	_, err = settings.InsertOne(ctx, bson.M{"_id": "bootstrapAdmin", "claimedAt": time.Now()})
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+var (
+	errRegistrationClosed = errors.New("registration is closed")
+	errInviteRequired     = errors.New("an invite code is required to register")
+)
+
+// admission is the outcome of admitRegistration: whether a new account may be
+// created, and what it claimed to get there.
+type admission struct {
+	bootstrap bool
+	invite    *Invite
+}
+
+// admitRegistration applies the registration mode to a new account. The first
+// account becomes the admin regardless of mode; everyone else registers as a plain
+// user and can be promoted by an admin through UpdateUser.
+func admitRegistration(ctx context.Context, inviteCode string) (*admission, error) {
+	bootstrap, err := claimBootstrapAdmin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check existing users: %w", err)
+	}
+	if bootstrap {
+		return &admission{bootstrap: true}, nil
+	}
+
+	switch registrationMode() {
+	case RegistrationClosed:
+		return nil, errRegistrationClosed
+	case RegistrationInvite:
+		if inviteCode == "" {
+			return nil, errInviteRequired
+		}
+		invite, err := consumeInvite(ctx, inviteCode)
+		if err == errInvalidInvite {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to check invite: %w", err)
+		}
+		return &admission{invite: invite}, nil
+	}
+	return &admission{}, nil
+}
+
+func (a *admission) role() string {
+	if a.bootstrap {
+		return RoleAdmin
+	}
+	return RoleUser
+}
+
+// release gives back what the admission claimed when the account could not be created.
+func (a *admission) release(ctx context.Context) {
+	if a.invite != nil {
+		if err := releaseInvite(ctx, a.invite); err != nil {
+			log.Printf("failed to release invite %s: %v", a.invite.ID, err)
+		}
+	}
+	if a.bootstrap {
+		if _, err := db.Client.Database("main").Collection("settings").DeleteOne(ctx, bson.M{"_id": "bootstrapAdmin"}); err != nil {
+			log.Printf("failed to release bootstrap admin claim: %v", err)
+		}
+	}
+}
+
+func isRegistrationDenied(err error) bool {
+	return err == errRegistrationClosed || err == errInviteRequired || err == errInvalidInvite
+}
+
+func respondRegistrationError(c *gin.Context, err error) {
+	if isRegistrationDenied(err) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// CreateAdminUser creates an admin account or promotes an existing user with that
+// username, setting the given password. It backs the create-admin command.
+func CreateAdminUser(ctx context.Context, username, password string) (*models.Users, error) {
+	if db.Client == nil {
+		return nil, errors.New("database connection not available")
+	}
+	if username == "" {
+		return nil, errors.New("username is required")
+	}
+	if err := passwordPolicy.Validate(password, username); err != nil {
+		return nil, err
+	}
+
+	coll := db.Client.Database("main").Collection("users")
+	var user models.Users
+	err := coll.FindOne(ctx, bson.M{"username": username}).Decode(&user)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("failed to check username: %w", err)
+	}
+	if err == mongo.ErrNoDocuments {
+		user = models.Users{ID: uuid.NewString(), Username: username, CreatedAt: time.Now()}
+	}
+
+	user.Role = RoleAdmin
+	user.UpdatedAt = time.Now()
+	if err := user.SetPassword(password); err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	_, err = coll.ReplaceOne(ctx, bson.M{"_id": user.ID}, user, options.Replace().SetUpsert(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save user: %w", err)
+	}
+
+	user.HashedPassword = ""
+	return &user, nil
+}
+
+// CreateInvite lets an admin issue an invite code for invite-only registration.
+func CreateInvite(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var req CreateInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.MaxUses == 0 {
+		req.MaxUses = 1
+	}
+	if req.MaxUses < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "maxUses must be positive"})
+		return
+	}
+	ttl := inviteTTL()
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expiresIn must be a positive duration such as 72h"})
+			return
+		}
+		ttl = d
+	}
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
+	}
+
+	code, err := generateSecretToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate invite code: " + err.Error()})
+		return
+	}
+
+	now := time.Now()
+	invite := Invite{
+		ID:        uuid.NewString(),
+		CodeHash:  hashSecretToken(code),
+		CreatedBy: currentUserID(c),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+		MaxUses:   req.MaxUses,
+	}
+	if _, err := invitesCollection().InsertOne(ctx, invite); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create invite: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"invite": invite, "code": code})
+}
+
+// ListInvites returns invites that are still usable.
+func ListInvites(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
+	}
+
+	filter := bson.M{
+		"expiresAt": bson.M{"$gt": time.Now()},
+		"$expr":     bson.M{"$lt": bson.A{"$uses", "$maxUses"}},
+	}
+	cur, err := invitesCollection().Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list invites: " + err.Error()})
+		return
+	}
+	defer cur.Close(ctx)
+
+	invites := []Invite{}
+	if err := cur.All(ctx, &invites); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to decode invites: " + err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, invites)
+}
+
+// DeleteInvite revokes an invite before it is used up.
+func DeleteInvite(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id parameter is required"})
+		return
+	}
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
+	}
+
+	result, err := invitesCollection().DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete invite: " + err.Error()})
+		return
+	}
+	if result.DeletedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "invite not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
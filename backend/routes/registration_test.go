@@ -0,0 +1,44 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistrationMode(t *testing.T) {
+	cases := map[string]string{
+		"":            RegistrationOpen,
+		"open":        RegistrationOpen,
+		"INVITE":      RegistrationInvite,
+		"invite-only": RegistrationInvite,
+		"closed":      RegistrationClosed,
+		"bogus":       RegistrationOpen,
+	}
+	for in, want := range cases {
+		t.Setenv("REGISTRATION_MODE", in)
+		assert.Equal(t, want, registrationMode(), "REGISTRATION_MODE=%q", in)
+	}
+}
+
+func TestCreateInvite_ValidatesRequest(t *testing.T) {
+	router := setupRouter()
+	router.POST("/admin/invites", CreateInvite)
+
+	for _, body := range []map[string]interface{}{
+		{"maxUses": -1},
+		{"expiresIn": "soon"},
+		{"expiresIn": "-1h"},
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, createTestRequest(http.MethodPost, "/admin/invites", body))
+		assert.Equal(t, http.StatusBadRequest, w.Code, "body %v", body)
+	}
+}
+
+func TestCreateAdminUser_RequiresDatabase(t *testing.T) {
+	_, err := CreateAdminUser(t.Context(), "alice", "correct horse battery staple")
+	assert.Error(t, err)
+}
@@ -0,0 +1,400 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"FATE-Vault/backend/db"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Permissions that roles grant. Routes check them with RequirePermission.
+const (
+	PermCharactersRead    = "characters:read"
+	PermCharactersWrite   = "characters:write"
+	PermCharactersPublish = "characters:publish"
+	PermStuntsWrite       = "stunts:write"
+	PermTemplatesWrite    = "templates:write"
+	PermUsersModerate     = "users:moderate"
+	PermRolesManage       = "roles:manage"
+	PermTagsManage        = "tags:manage"
+	// PermUsersManage covers invites and issuing password reset links.
+	PermUsersManage = "users:manage"
+	// PermStorageManage covers orphaned file cleanup here and, in the storage
+	// service, other users' files and usage.
+	PermStorageManage = "storage:manage"
+)
+
+var allPermissions = []string{
+	PermCharactersRead,
+	PermCharactersWrite,
+	PermCharactersPublish,
+	PermStuntsWrite,
+	PermTemplatesWrite,
+	PermUsersModerate,
+	PermRolesManage,
+	PermTagsManage,
+	PermUsersManage,
+	PermStorageManage,
+}
+
+const (
+	RoleAdmin     = "admin"
+	RoleModerator = "moderator"
+	RoleGM        = "gm"
+	RolePlayer    = "player"
+	RoleViewer    = "viewer"
+	// RoleUser is the role accounts got before roles were configurable. It grants
+	// the same permissions as player.
+	RoleUser = "user"
+)
+
+// Role maps a role name, as stored on users, to the permissions it grants.
+type Role struct {
+	ID          string    `json:"id" bson:"_id"`
+	Description string    `json:"description" bson:"description"`
+	Permissions []string  `json:"permissions" bson:"permissions"`
+	BuiltIn     bool      `json:"builtIn" bson:"builtIn"`
+	UpdatedAt   time.Time `json:"updatedAt,omitempty" bson:"updatedAt,omitempty"`
+}
+
+type RoleRequest struct {
+	ID          string   `json:"id,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Permissions []string `json:"permissions"`
+}
+
+var roleIDPattern = regexp.MustCompile(`^[a-z][a-z0-9-]{0,31}$`)
+
+// builtInRoles are the roles that exist even before anything is stored. Admins can
+// change their permissions, except admin's, and cannot delete them.
+func builtInRoles() map[string]Role {
+	player := []string{PermCharactersRead, PermCharactersWrite}
+	gm := append(append([]string{}, player...), PermCharactersPublish, PermStuntsWrite, PermTemplatesWrite)
+	moderator := append(append([]string{}, gm...), PermUsersModerate, PermTagsManage)
+
+	return map[string]Role{
+		RoleAdmin:     {ID: RoleAdmin, Description: "Full access, including role management", Permissions: allPermissions, BuiltIn: true},
+		RoleModerator: {ID: RoleModerator, Description: "Game master who can also moderate users and tags", Permissions: moderator, BuiltIn: true},
+		RoleGM:        {ID: RoleGM, Description: "Publishes characters and curates shared stunts and templates", Permissions: gm, BuiltIn: true},
+		RolePlayer:    {ID: RolePlayer, Description: "Creates and edits characters", Permissions: player, BuiltIn: true},
+		RoleViewer:    {ID: RoleViewer, Description: "Read-only access", Permissions: []string{PermCharactersRead}, BuiltIn: true},
+		RoleUser:      {ID: RoleUser, Description: "Default role for new accounts; same as player", Permissions: player, BuiltIn: true},
+	}
+}
+
+func rolesCollection() *mongo.Collection {
+	return db.Client.Database("main").Collection("roles")
+}
+
+// roleCache keeps role definitions in memory so permission checks do not hit
+// Mongo on every request. Changes made through the API invalidate it right away;
+// changes made by other backend instances show up within the TTL.
+type roleCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	roles    map[string]Role
+	loadedAt time.Time
+}
+
+var roles = &roleCache{ttl: 30 * time.Second}
+
+func (r *roleCache) invalidate() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.roles = nil
+}
+
+// all returns every role: the built-in defaults overlaid with stored definitions.
+// Without a database only the defaults exist.
+func (r *roleCache) all(ctx context.Context) (map[string]Role, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.roles != nil && time.Since(r.loadedAt) < r.ttl {
+		return r.roles, nil
+	}
+
+	loaded := builtInRoles()
+	if db.Client != nil {
+		cur, err := rolesCollection().Find(ctx, bson.M{})
+		if err != nil {
+			return nil, err
+		}
+		var stored []Role
+		if err := cur.All(ctx, &stored); err != nil {
+			return nil, err
+		}
+		for _, role := range stored {
+			if role.ID == RoleAdmin {
+				continue
+			}
+			_, role.BuiltIn = loaded[role.ID]
+			loaded[role.ID] = role
+		}
+	}
+
+	r.roles = loaded
+	r.loadedAt = time.Now()
+	return loaded, nil
+}
+
+func (r *roleCache) get(ctx context.Context, id string) (*Role, error) {
+	all, err := r.all(ctx)
+	if err != nil {
+		return nil, err
+	}
+	role, ok := all[id]
+	if !ok {
+		return nil, nil
+	}
+	return &role, nil
+}
+
+func (r *Role) grants(permission string) bool {
+	if r.ID == RoleAdmin {
+		return true
+	}
+	for _, p := range r.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenScopeFor is the API token scope a token needs to use a permission. Tokens
+// only carry coarse scopes, so everything beyond characters needs the admin scope.
+func tokenScopeFor(permission string) string {
+	switch permission {
+	case PermCharactersRead:
+		return ScopeCharactersRead
+	case PermCharactersWrite, PermCharactersPublish:
+		return ScopeCharactersWrite
+	default:
+		return ScopeAdmin
+	}
+}
+
+// hasPermission reports whether the authenticated caller's role grants the
+// permission and, for API token requests, whether the token has the scope the
+// permission needs or one of alsoScopes.
+func hasPermission(ctx context.Context, c *gin.Context, permission string, alsoScopes ...string) (bool, error) {
+	roleName, _ := c.Get("role")
+	name, _ := roleName.(string)
+	if name == "" {
+		return false, nil
+	}
+	role, err := roles.get(ctx, name)
+	if err != nil || role == nil {
+		return false, err
+	}
+	if !role.grants(permission) {
+		return false, nil
+	}
+	if token := currentAPIToken(c); token != nil {
+		for _, scope := range append([]string{tokenScopeFor(permission)}, alsoScopes...) {
+			if token.hasScope(scope) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// callerPermissions lists the permissions the caller can use, taking API token
+// scopes into account.
+func callerPermissions(ctx context.Context, c *gin.Context) ([]string, error) {
+	permissions := []string{}
+	for _, p := range allPermissions {
+		ok, err := hasPermission(ctx, c, p)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			permissions = append(permissions, p)
+		}
+	}
+	return permissions, nil
+}
+
+// RequirePermission lets the request through only when the caller's role grants
+// the permission. API tokens may use alsoScopes instead of the scope the
+// permission needs. It must run after AuthMiddleware.
+func RequirePermission(permission string, alsoScopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		ok, err := hasPermission(ctx, c, permission, alsoScopes...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load roles: " + err.Error()})
+			c.Abort()
+			return
+		}
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "missing permission " + permission})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func validatePermissions(perms []string) ([]string, bool) {
+	valid := make(map[string]bool, len(allPermissions))
+	for _, p := range allPermissions {
+		valid[p] = true
+	}
+	seen := make(map[string]bool)
+	out := []string{}
+	for _, p := range perms {
+		if !valid[p] {
+			return nil, false
+		}
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	sort.Strings(out)
+	return out, true
+}
+
+// ListRoles returns all role definitions and the permissions they can use.
+func ListRoles(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	all, err := roles.all(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list roles: " + err.Error()})
+		return
+	}
+
+	list := make([]Role, 0, len(all))
+	for _, role := range all {
+		list = append(list, role)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	c.IndentedJSON(http.StatusOK, gin.H{"roles": list, "permissions": allPermissions})
+}
+
+// SaveRole creates a role, or replaces the description and permissions of an
+// existing one when called with an id parameter.
+func SaveRole(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	id := c.Param("id")
+	creating := id == ""
+	if creating {
+		id = req.ID
+	}
+	if !roleIDPattern.MatchString(id) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role id must be 1-32 lowercase letters, digits or dashes, starting with a letter"})
+		return
+	}
+	if id == RoleAdmin {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the admin role always has every permission and cannot be changed"})
+		return
+	}
+	perms, ok := validatePermissions(req.Permissions)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown permission; valid permissions are listed by GET /admin/roles"})
+		return
+	}
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
+	}
+
+	existing, err := roles.get(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load roles: " + err.Error()})
+		return
+	}
+	if creating && existing != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "role already exists"})
+		return
+	}
+	if !creating && existing == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "role not found"})
+		return
+	}
+
+	role := Role{ID: id, Description: req.Description, Permissions: perms, UpdatedAt: time.Now()}
+	if existing != nil {
+		role.BuiltIn = existing.BuiltIn
+		if req.Description == "" {
+			role.Description = existing.Description
+		}
+	}
+	if _, err := rolesCollection().ReplaceOne(ctx, bson.M{"_id": id}, role, options.Replace().SetUpsert(true)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save role: " + err.Error()})
+		return
+	}
+	roles.invalidate()
+
+	status := http.StatusOK
+	if creating {
+		status = http.StatusCreated
+	}
+	c.JSON(status, role)
+}
+
+// DeleteRole removes a custom role that no user has.
+func DeleteRole(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	id := c.Param("id")
+	if _, builtIn := builtInRoles()[id]; builtIn {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "built-in roles cannot be deleted"})
+		return
+	}
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
+	}
+
+	inUse, err := usersCollection().CountDocuments(ctx, bson.M{"role": id}, options.Count().SetLimit(1))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check role usage: " + err.Error()})
+		return
+	}
+	if inUse > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "role is still assigned to users"})
+		return
+	}
+
+	result, err := rolesCollection().DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete role: " + err.Error()})
+		return
+	}
+	if result.DeletedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "role not found"})
+		return
+	}
+	roles.invalidate()
+
+	c.Status(http.StatusNoContent)
+}
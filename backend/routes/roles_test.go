@@ -0,0 +1,159 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"FATE-Vault/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequirePermission_BuiltInRoles(t *testing.T) {
+	cases := []struct {
+		role   string
+		stunts int
+		chars  int
+	}{
+		{RoleAdmin, http.StatusNoContent, http.StatusNoContent},
+		{RoleModerator, http.StatusNoContent, http.StatusNoContent},
+		{RoleGM, http.StatusNoContent, http.StatusNoContent},
+		{RolePlayer, http.StatusForbidden, http.StatusNoContent},
+		{RoleUser, http.StatusForbidden, http.StatusNoContent},
+		{RoleViewer, http.StatusForbidden, http.StatusForbidden},
+		{"no-such-role", http.StatusForbidden, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		t.Run(tc.role, func(t *testing.T) {
+			router := setupTokenRouter(tc.role, nil)
+			assert.Equal(t, tc.stunts, serve(router, http.MethodPost, "/stunts/create", nil).Code)
+			assert.Equal(t, tc.chars, serve(router, http.MethodPost, "/characters/create", nil).Code)
+		})
+	}
+}
+
+func TestRequirePermission_TokenScopes(t *testing.T) {
+	router := setupTokenRouter(RoleGM, &APIToken{Scopes: []string{ScopeCharactersWrite}})
+	assert.Equal(t, http.StatusNoContent, serve(router, http.MethodPost, "/characters/create", nil).Code)
+	// The role allows stunt writes, but the token's scopes do not.
+	assert.Equal(t, http.StatusForbidden, serve(router, http.MethodPost, "/stunts/create", nil).Code)
+
+	router = setupTokenRouter(RoleGM, &APIToken{Scopes: []string{ScopeAdmin}})
+	assert.Equal(t, http.StatusNoContent, serve(router, http.MethodPost, "/stunts/create", nil).Code)
+}
+
+func TestListRoles_DefaultsWithoutDatabase(t *testing.T) {
+	router := setupTokenRouter(RoleAdmin, nil)
+
+	w := serve(router, http.MethodGet, "/admin/roles", nil)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Roles       []Role   `json:"roles"`
+		Permissions []string `json:"permissions"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.ElementsMatch(t, allPermissions, body.Permissions)
+	ids := []string{}
+	for _, r := range body.Roles {
+		ids = append(ids, r.ID)
+		assert.True(t, r.BuiltIn)
+	}
+	assert.Equal(t, []string{RoleAdmin, RoleGM, RoleModerator, RolePlayer, RoleUser, RoleViewer}, ids)
+}
+
+func TestSaveRole_Validation(t *testing.T) {
+	router := setupTokenRouter(RoleAdmin, nil)
+
+	w := serve(router, http.MethodPost, "/admin/roles", map[string]interface{}{"id": "Bad Name", "permissions": []string{}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(router, http.MethodPost, "/admin/roles/admin", map[string]interface{}{"permissions": []string{PermCharactersRead}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "cannot be changed")
+
+	w = serve(router, http.MethodPost, "/admin/roles", map[string]interface{}{"id": "scribe", "permissions": []string{"dice:cheat"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "unknown permission")
+
+	w = serve(router, http.MethodPost, "/admin/roles", map[string]interface{}{"id": "scribe", "permissions": []string{PermTemplatesWrite}})
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "database connection not available")
+}
+
+func TestDeleteRole_RejectsBuiltIn(t *testing.T) {
+	router := setupTokenRouter(RoleAdmin, nil)
+
+	w := serve(router, http.MethodDelete, "/admin/roles/"+RoleGM, nil)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestValidatePermissions(t *testing.T) {
+	perms, ok := validatePermissions([]string{PermStuntsWrite, PermCharactersRead, PermStuntsWrite})
+	assert.True(t, ok)
+	assert.Equal(t, []string{PermCharactersRead, PermStuntsWrite}, perms)
+
+	_, ok = validatePermissions([]string{"stunts:*"})
+	assert.False(t, ok)
+}
+
+func TestAllowPublishing(t *testing.T) {
+	for role, want := range map[string]bool{RoleGM: true, RoleAdmin: true, RolePlayer: false, RoleViewer: false} {
+		t.Run(role, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Set("role", role)
+
+			assert.Equal(t, want, allowPublishing(context.Background(), c))
+			if !want {
+				assert.Equal(t, http.StatusForbidden, w.Code)
+			}
+		})
+	}
+}
+
+func TestGetCurrentUser_ListsPermissions(t *testing.T) {
+	router := setupRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set("role", RolePlayer)
+		c.Set("user", models.Users{ID: "user-1", Role: RolePlayer})
+		c.Set("apiToken", &APIToken{Scopes: []string{ScopeCharactersRead}})
+		c.Next()
+	})
+	router.GET("/users/me", GetCurrentUser)
+
+	w := serve(router, http.MethodGet, "/users/me", nil)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Permissions []string `json:"permissions"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	// The role could write characters too, but the token cannot.
+	assert.Equal(t, []string{PermCharactersRead}, body.Permissions)
+}
+
+func TestUpdateUser_PlayerCannotEditOthers(t *testing.T) {
+	router := setupTokenRouter(RolePlayer, nil)
+
+	w := serve(router, http.MethodPost, "/users/update/user-2", map[string]interface{}{"username": "mallory"})
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestUpdateUser_ModeratorPassesPermissionCheck(t *testing.T) {
+	router := setupTokenRouter(RoleModerator, nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, createTestRequest(http.MethodPost, "/users/update/user-2", map[string]interface{}{"username": "renamed"}))
+
+	// Allowed to try; the request then fails on the missing database.
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "database connection not available")
+}
@@ -0,0 +1,327 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"FATE-Vault/backend/db"
+	"FATE-Vault/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type SavedSearchRequest struct {
+	Name    string   `json:"name" binding:"required,max=100"`
+	Query   string   `json:"query" binding:"required"`
+	GameIDs []string `json:"gameIds,omitempty"`
+}
+
+var errSavedSearchNotFound = errors.New("saved search not found")
+
+func savedSearchesCollection() *mongo.Collection {
+	return db.Client.Database("main").Collection("saved_searches")
+}
+
+// normalizeSavedQuery checks that the query is a valid character filter and keeps
+// only its filter parameters, dropping a leading "?" and any paging or sorting.
+func normalizeSavedQuery(ctx context.Context, raw string) (string, error) {
+	params, err := url.ParseQuery(strings.TrimPrefix(strings.TrimSpace(raw), "?"))
+	if err != nil {
+		return "", errors.New("query must be a URL query string")
+	}
+	params = characterFilterParams(params)
+	if len(params) == 0 {
+		return "", errors.New("query has no character filters")
+	}
+	if _, err := characterFilterFromParams(ctx, params); err != nil {
+		return "", err
+	}
+	return params.Encode(), nil
+}
+
+// gameMemberIDs returns the games, out of gameIDs, that the user is a member of:
+// games they own or whose owner linked at least one of the user's characters.
+func gameMemberIDs(ctx context.Context, userID string, gameIDs []string) ([]string, error) {
+	if len(gameIDs) == 0 {
+		return nil, nil
+	}
+	cur, err := db.Client.Database("main").Collection("games").Find(ctx, bson.M{"_id": bson.M{"$in": gameIDs}})
+	if err != nil {
+		return nil, err
+	}
+	var games []models.Game
+	if err := cur.All(ctx, &games); err != nil {
+		return nil, err
+	}
+
+	characters := db.Client.Database("main").Collection("characters")
+	var member []string
+	for _, game := range games {
+		if game.OwnerID == userID {
+			member = append(member, game.ID)
+			continue
+		}
+		if len(game.LinkedCharactersIds) == 0 {
+			continue
+		}
+		n, err := characters.CountDocuments(ctx, bson.M{"_id": bson.M{"$in": game.LinkedCharactersIds}, "creatorId": userID}, options.Count().SetLimit(1))
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			member = append(member, game.ID)
+		}
+	}
+	return member, nil
+}
+
+// userGameIDs returns every game the user is a member of.
+func userGameIDs(ctx context.Context, userID string) ([]string, error) {
+	characterIDs, err := db.Client.Database("main").Collection("characters").Distinct(ctx, "_id", bson.M{"creatorId": userID})
+	if err != nil {
+		return nil, err
+	}
+	filter := bson.M{"ownerId": userID}
+	if len(characterIDs) > 0 {
+		filter = bson.M{"$or": bson.A{filter, bson.M{"linkedCharactersIds": bson.M{"$in": characterIDs}}}}
+	}
+	ids, err := db.Client.Database("main").Collection("games").Distinct(ctx, "_id", filter)
+	if err != nil {
+		return nil, err
+	}
+	games := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if s, ok := id.(string); ok {
+			games = append(games, s)
+		}
+	}
+	return games, nil
+}
+
+// readableSavedSearch loads a saved search the user owns or is shared into.
+// Searches the user may not read are reported as not found.
+func readableSavedSearch(ctx context.Context, userID, id string) (*models.SavedSearch, error) {
+	var search models.SavedSearch
+	err := savedSearchesCollection().FindOne(ctx, bson.M{"_id": id}).Decode(&search)
+	if err == mongo.ErrNoDocuments {
+		return nil, errSavedSearchNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if userID == "" {
+		return nil, errSavedSearchNotFound
+	}
+	if search.OwnerID == userID {
+		return &search, nil
+	}
+	shared, err := gameMemberIDs(ctx, userID, search.GameIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(shared) == 0 {
+		return nil, errSavedSearchNotFound
+	}
+	return &search, nil
+}
+
+// savedSearchFilter resolves ?savedSearch= on the characters list to the search's
+// filter. It returns nil when the parameter is absent.
+func savedSearchFilter(ctx context.Context, c *gin.Context) (bson.M, error) {
+	id := c.Query("savedSearch")
+	if id == "" {
+		return nil, nil
+	}
+	search, err := readableSavedSearch(ctx, optionalUserID(c), id)
+	if err != nil {
+		return nil, err
+	}
+	params, err := url.ParseQuery(search.Query)
+	if err != nil {
+		return nil, err
+	}
+	return characterFilterFromParams(ctx, params)
+}
+
+// ListSavedSearches returns the caller's saved searches and those shared with
+// them through their games.
+func ListSavedSearches(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
+	}
+
+	userID := currentUserID(c)
+	games, err := userGameIDs(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to find games: " + err.Error()})
+		return
+	}
+
+	filter := bson.M{"ownerId": userID}
+	if len(games) > 0 {
+		filter = bson.M{"$or": bson.A{filter, bson.M{"gameIds": bson.M{"$in": games}}}}
+	}
+	cur, err := savedSearchesCollection().Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list saved searches: " + err.Error()})
+		return
+	}
+	defer cur.Close(ctx)
+
+	searches := []models.SavedSearch{}
+	if err := cur.All(ctx, &searches); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to decode saved searches: " + err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, searches)
+}
+
+// bindSavedSearch validates a create or update request on behalf of the owner.
+func bindSavedSearch(ctx context.Context, c *gin.Context, ownerID string) (*SavedSearchRequest, bool) {
+	var req SavedSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return nil, false
+	}
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return nil, false
+	}
+
+	query, err := normalizeSavedQuery(ctx, req.Query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query: " + err.Error()})
+		return nil, false
+	}
+	req.Query = query
+
+	// Only share into games the owner belongs to.
+	if req.GameIDs == nil {
+		req.GameIDs = []string{}
+	}
+	member, err := gameMemberIDs(ctx, ownerID, req.GameIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check games: " + err.Error()})
+		return nil, false
+	}
+	if len(member) != len(req.GameIDs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "searches can only be shared with games you have a character in"})
+		return nil, false
+	}
+
+	return &req, true
+}
+
+func CreateSavedSearch(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	userID := currentUserID(c)
+	req, ok := bindSavedSearch(ctx, c, userID)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	search := models.SavedSearch{
+		ID:        uuid.NewString(),
+		Name:      req.Name,
+		OwnerID:   userID,
+		Query:     req.Query,
+		GameIDs:   req.GameIDs,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if _, err := savedSearchesCollection().InsertOne(ctx, search); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create saved search: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, search)
+}
+
+// UpdateSavedSearch replaces the name, query and sharing of one of the caller's
+// saved searches.
+func UpdateSavedSearch(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id parameter is required"})
+		return
+	}
+
+	userID := currentUserID(c)
+	req, ok := bindSavedSearch(ctx, c, userID)
+	if !ok {
+		return
+	}
+
+	update := bson.M{"$set": bson.M{
+		"name":      req.Name,
+		"query":     req.Query,
+		"gameIds":   req.GameIDs,
+		"updatedAt": time.Now(),
+	}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var search models.SavedSearch
+	err := savedSearchesCollection().FindOneAndUpdate(ctx, bson.M{"_id": id, "ownerId": userID}, update, opts).Decode(&search)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": errSavedSearchNotFound.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update saved search: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, search)
+}
+
+func DeleteSavedSearch(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id parameter is required"})
+		return
+	}
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
+	}
+
+	result, err := savedSearchesCollection().DeleteOne(ctx, bson.M{"_id": id, "ownerId": currentUserID(c)})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete saved search: " + err.Error()})
+		return
+	}
+	if result.DeletedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": errSavedSearchNotFound.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "saved search deleted successfully"})
+}
@@ -0,0 +1,84 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeSavedQuery(t *testing.T) {
+	query, err := normalizeSavedQuery(context.Background(), "?edition=accelerated&aspect=злодей&limit=10&sort=-name")
+
+	require.NoError(t, err)
+	params, err := url.ParseQuery(query)
+	require.NoError(t, err)
+	assert.Equal(t, url.Values{"edition": {"accelerated"}, "aspect": {"злодей"}}, params)
+}
+
+func TestNormalizeSavedQuery_KeepsEncodedPlus(t *testing.T) {
+	query, err := normalizeSavedQuery(context.Background(), "skill="+url.QueryEscape("Провокация:+3"))
+
+	require.NoError(t, err)
+	params, _ := url.ParseQuery(query)
+	assert.Equal(t, "Провокация:+3", params.Get("skill"))
+}
+
+func TestNormalizeSavedQuery_Rejects(t *testing.T) {
+	for name, raw := range map[string]string{
+		"no filters":     "limit=10&sort=name",
+		"invalid filter": "published=maybe",
+		"bad expression": "filter=" + url.QueryEscape(`{"$where": "1"}`),
+		"malformed":      "name=%zz",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := normalizeSavedQuery(context.Background(), raw)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestCharacterFilterParams(t *testing.T) {
+	params := url.Values{
+		"name":         {"a"},
+		"characterIds": {"1", "2"},
+		"match":        {"any"},
+		"page":         {"2"},
+		"savedSearch":  {"s"},
+	}
+
+	assert.Equal(t, url.Values{"name": {"a"}, "characterIds": {"1", "2"}, "match": {"any"}}, characterFilterParams(params))
+}
+
+func TestCreateSavedSearch_Validation(t *testing.T) {
+	router := setupRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set("userId", "user-1")
+		c.Next()
+	})
+	router.POST("/searches/create", CreateSavedSearch)
+
+	w := serve(router, http.MethodPost, "/searches/create", map[string]interface{}{"query": "edition=core"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(router, http.MethodPost, "/searches/create", map[string]interface{}{"name": "   ", "query": "edition=core"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(router, http.MethodPost, "/searches/create", map[string]interface{}{"name": "Villains", "query": "edition=core"})
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "database connection not available")
+}
+
+func TestCharactersList_SavedSearchNeedsDatabase(t *testing.T) {
+	router := setupRouter()
+	router.GET("/characters", CharactersList)
+
+	w := serve(router, http.MethodGet, "/characters?savedSearch=abc", nil)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "database connection not available")
+}
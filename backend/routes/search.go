@@ -0,0 +1,265 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"FATE-Vault/backend/db"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	maxSearchQuery     = 200
+	searchSnippetRunes = 200
+)
+
+// searchTarget describes how one collection takes part in GET /search. Weights
+// rank a match in the name above one buried in the notes.
+type searchTarget struct {
+	Type       string
+	Collection string
+	Weights    bson.D
+}
+
+var searchTargets = []searchTarget{
+	{
+		Type:       "character",
+		Collection: "characters",
+		Weights: bson.D{
+			{Key: "name", Value: 10},
+			{Key: "aspects.value", Value: 5},
+			{Key: "stunts.name", Value: 4},
+			{Key: "description", Value: 3},
+			{Key: "stunts.description", Value: 2},
+			{Key: "notes", Value: 1},
+		},
+	},
+	{
+		Type:       "stunt",
+		Collection: "stunts",
+		Weights: bson.D{
+			{Key: "name", Value: 10},
+			{Key: "description", Value: 3},
+		},
+	},
+	{
+		Type:       "game",
+		Collection: "games",
+		Weights: bson.D{
+			{Key: "name", Value: 10},
+			{Key: "gameAspects.value", Value: 5},
+			{Key: "description", Value: 3},
+		},
+	},
+}
+
+// SearchHit is one result of GET /search.
+type SearchHit struct {
+	Type    string  `json:"type"`
+	ID      string  `json:"id"`
+	Name    string  `json:"name"`
+	Edition string  `json:"edition,omitempty"`
+	Snippet string  `json:"snippet,omitempty"`
+	Score   float64 `json:"score"`
+}
+
+// searchLanguage is the MongoDB text search language used for stemming and stop
+// words. Most of our content is Russian; "none" disables stemming entirely.
+func searchLanguage() string {
+	if v := os.Getenv("SEARCH_LANGUAGE"); v != "" {
+		return v
+	}
+	return "russian"
+}
+
+var (
+	searchIndexesMu    sync.Mutex
+	searchIndexesReady bool
+)
+
+// ensureSearchIndexes creates the text index on every searchable collection once
+// per process. Failures are retried on the next search.
+func ensureSearchIndexes(ctx context.Context) error {
+	searchIndexesMu.Lock()
+	defer searchIndexesMu.Unlock()
+	if searchIndexesReady {
+		return nil
+	}
+
+	for _, target := range searchTargets {
+		keys := bson.D{}
+		for _, w := range target.Weights {
+			keys = append(keys, bson.E{Key: w.Key, Value: "text"})
+		}
+		// language_override points at a field we never write, so a document that
+		// happens to have a "language" field cannot switch the stemmer.
+		opts := options.Index().
+			SetName("search_text").
+			SetWeights(target.Weights).
+			SetDefaultLanguage(searchLanguage()).
+			SetLanguageOverride("searchLanguage")
+		_, err := db.Client.Database("main").Collection(target.Collection).Indexes().CreateOne(ctx, mongo.IndexModel{Keys: keys, Options: opts})
+		if err != nil {
+			return err
+		}
+	}
+
+	searchIndexesReady = true
+	return nil
+}
+
+func snippet(text string) string {
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) <= searchSnippetRunes {
+		return text
+	}
+	runes := []rune(text)
+	return strings.TrimSpace(string(runes[:searchSnippetRunes])) + "…"
+}
+
+// searchCollection returns the best matches for the query in one collection.
+func searchCollection(ctx context.Context, target searchTarget, query string, filter bson.M, limit int) ([]SearchHit, error) {
+	filter["$text"] = bson.M{"$search": query}
+	opts := options.Find().
+		SetProjection(bson.M{
+			"name":        1,
+			"edition":     1,
+			"description": 1,
+			"score":       bson.M{"$meta": "textScore"},
+		}).
+		SetSort(bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}}).
+		SetLimit(int64(limit))
+
+	cur, err := db.Client.Database("main").Collection(target.Collection).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var docs []struct {
+		ID          interface{} `bson:"_id"`
+		Name        string      `bson:"name"`
+		Edition     string      `bson:"edition"`
+		Description string      `bson:"description"`
+		Score       float64     `bson:"score"`
+	}
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	hits := make([]SearchHit, 0, len(docs))
+	for _, d := range docs {
+		hits = append(hits, SearchHit{
+			Type:    target.Type,
+			ID:      idString(d.ID),
+			Name:    d.Name,
+			Edition: d.Edition,
+			Snippet: snippet(d.Description),
+			Score:   d.Score,
+		})
+	}
+	return hits, nil
+}
+
+// idString renders string and ObjectID document IDs alike.
+func idString(id interface{}) string {
+	switch v := id.(type) {
+	case string:
+		return v
+	case interface{ Hex() string }:
+		return v.Hex()
+	default:
+		return ""
+	}
+}
+
+// Search runs a full-text query over characters, stunts and games and returns
+// typed hits ordered by relevance. Characters are limited to those the caller may
+// see. ?types=character,stunt narrows the search; ?limit caps the number of hits.
+func Search(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q parameter is required"})
+		return
+	}
+	if utf8.RuneCountInString(query) > maxSearchQuery {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q must be at most " + strconv.Itoa(maxSearchQuery) + " characters"})
+		return
+	}
+
+	limit := defaultSearchLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSearchLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxSearchLimit)})
+			return
+		}
+		limit = n
+	}
+
+	targets := searchTargets
+	if v := c.Query("types"); v != "" {
+		wanted := make(map[string]bool)
+		for _, t := range strings.Split(v, ",") {
+			wanted[strings.TrimSpace(t)] = true
+		}
+		targets = nil
+		for _, target := range searchTargets {
+			if wanted[target.Type] {
+				targets = append(targets, target)
+				delete(wanted, target.Type)
+			}
+		}
+		if len(wanted) > 0 || len(targets) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "types must be a comma-separated list of character, stunt, game"})
+			return
+		}
+	}
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
+	}
+
+	if err := ensureSearchIndexes(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to prepare search indexes: " + err.Error()})
+		return
+	}
+
+	hits := []SearchHit{}
+	for _, target := range targets {
+		filter := bson.M{}
+		if target.Type == "character" {
+			filter = visibilityFilter(c)
+		}
+		found, err := searchCollection(ctx, target, query, filter, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search " + target.Collection + ": " + err.Error()})
+			return
+		}
+		hits = append(hits, found...)
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"query": query, "hits": hits})
+}
@@ -0,0 +1,55 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSearch_Validation(t *testing.T) {
+	router := setupRouter()
+	router.GET("/search", Search)
+
+	cases := map[string]struct {
+		query string
+		want  int
+	}{
+		"missing q":     {"", http.StatusBadRequest},
+		"blank q":       {"q=%20%20", http.StatusBadRequest},
+		"long q":        {"q=" + strings.Repeat("я", maxSearchQuery+1), http.StatusBadRequest},
+		"bad limit":     {"q=вуду&limit=0", http.StatusBadRequest},
+		"unknown type":  {"q=вуду&types=character,user", http.StatusBadRequest},
+		"valid request": {"q=" + url.QueryEscape("знахарь вуду") + "&types=character,stunt&limit=5", http.StatusInternalServerError},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search?"+tc.query, nil))
+			assert.Equal(t, tc.want, w.Code)
+		})
+	}
+}
+
+func TestSnippet_CountsRunes(t *testing.T) {
+	short := "Пожилой знахарь и жрец вуду"
+	assert.Equal(t, short, snippet("  "+short+"  "))
+
+	long := strings.Repeat("духи ", 100)
+	got := snippet(long)
+	assert.True(t, utf8.ValidString(got))
+	assert.True(t, strings.HasSuffix(got, "…"))
+	assert.LessOrEqual(t, utf8.RuneCountInString(got), searchSnippetRunes+1)
+}
+
+func TestIDString(t *testing.T) {
+	oid := primitive.NewObjectID()
+	assert.Equal(t, "abc", idString("abc"))
+	assert.Equal(t, oid.Hex(), idString(oid))
+	assert.Equal(t, "", idString(42))
+}
@@ -1,65 +1,147 @@
 package routes
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"fmt"
 	"io"
+	"log"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
 type Session struct {
-	ID             string
-	UserID         string
-	CreatedAt      time.Time
-	LastActivityAt time.Time
+	ID             string    `json:"id" bson:"_id"`
+	UserID         string    `json:"userId" bson:"userId"`
+	CreatedAt      time.Time `json:"createdAt" bson:"createdAt"`
+	LastActivityAt time.Time `json:"lastActivityAt" bson:"lastActivityAt"`
+	UserAgent      string    `json:"userAgent" bson:"userAgent"`
+	IP             string    `json:"ip" bson:"ip"`
+}
+
+// expiresAt is when the session dies if it sees no further activity.
+func (s *Session) expiresAt(idleExpiration, absoluteExpiration time.Duration) time.Time {
+	idle := s.LastActivityAt.Add(idleExpiration)
+	absolute := s.CreatedAt.Add(absoluteExpiration)
+	if idle.Before(absolute) {
+		return idle
+	}
+	return absolute
+}
+
+// SessionStore persists sessions. read returns (nil, nil) for unknown IDs.
+type SessionStore interface {
+	read(ctx context.Context, id string) (*Session, error)
+	write(ctx context.Context, session *Session) error
+	// refresh saves a session only if it still exists, so a session revoked
+	// while a request was using it stays revoked.
+	refresh(ctx context.Context, session *Session) error
+	destroy(ctx context.Context, id string) error
+	gc(ctx context.Context, idleExpiration, absoluteExpiration time.Duration) error
+	listByUser(ctx context.Context, userID string) ([]*Session, error)
 }
 
 type InMemorySessionStore struct {
 	mu       sync.RWMutex
 	sessions map[string]*Session
+	byUser   map[string]map[string]struct{}
 }
 
 func NewInMemorySessionStore() *InMemorySessionStore {
 	return &InMemorySessionStore{
 		sessions: make(map[string]*Session),
+		byUser:   make(map[string]map[string]struct{}),
 	}
 }
 
-func (s *InMemorySessionStore) read(id string) *Session {
+// removeLocked drops a session and its entry in the per-user index.
+func (s *InMemorySessionStore) removeLocked(id string) {
+	session, ok := s.sessions[id]
+	if !ok {
+		return
+	}
+	delete(s.sessions, id)
+	if ids := s.byUser[session.UserID]; ids != nil {
+		delete(ids, id)
+		if len(ids) == 0 {
+			delete(s.byUser, session.UserID)
+		}
+	}
+}
+
+func (s *InMemorySessionStore) read(ctx context.Context, id string) (*Session, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return s.sessions[id]
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *session
+	return &copied, nil
 }
 
-func (s *InMemorySessionStore) write(session *Session) {
+func (s *InMemorySessionStore) write(ctx context.Context, session *Session) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.sessions[session.ID] = session
+	copied := *session
+	s.sessions[session.ID] = &copied
+	ids, ok := s.byUser[session.UserID]
+	if !ok {
+		ids = make(map[string]struct{})
+		s.byUser[session.UserID] = ids
+	}
+	ids[session.ID] = struct{}{}
+	return nil
 }
 
-func (s *InMemorySessionStore) destroy(id string) {
+func (s *InMemorySessionStore) refresh(ctx context.Context, session *Session) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	delete(s.sessions, id)
+	if _, ok := s.sessions[session.ID]; ok {
+		copied := *session
+		s.sessions[session.ID] = &copied
+	}
+	return nil
 }
 
-func (s *InMemorySessionStore) gc(idleExpiration, absoluteExpiration time.Duration) {
+func (s *InMemorySessionStore) destroy(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeLocked(id)
+	return nil
+}
+
+func (s *InMemorySessionStore) gc(ctx context.Context, idleExpiration, absoluteExpiration time.Duration) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	for id, session := range s.sessions {
 		if time.Since(session.LastActivityAt) > idleExpiration ||
 			time.Since(session.CreatedAt) > absoluteExpiration {
-			delete(s.sessions, id)
+			s.removeLocked(id)
 		}
 	}
+	return nil
+}
+
+func (s *InMemorySessionStore) listByUser(ctx context.Context, userID string) ([]*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*Session
+	for id := range s.byUser[userID] {
+		copied := *s.sessions[id]
+		result = append(result, &copied)
+	}
+	return result, nil
 }
 
 type SessionManager struct {
-	store              *InMemorySessionStore
+	store              SessionStore
 	idleExpiration     time.Duration
 	absoluteExpiration time.Duration
 }
@@ -83,7 +165,7 @@ func envIntSeconds(key string, fallbackSeconds int) time.Duration {
 }
 
 func NewSessionManager(
-	store *InMemorySessionStore,
+	store SessionStore,
 	gcInterval time.Duration,
 	idleExpiration time.Duration,
 	absoluteExpiration time.Duration,
@@ -102,7 +184,11 @@ func NewSessionManager(
 func (m *SessionManager) gc(interval time.Duration) {
 	ticker := time.NewTicker(interval)
 	for range ticker.C {
-		m.store.gc(m.idleExpiration, m.absoluteExpiration)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		if err := m.store.gc(ctx, m.idleExpiration, m.absoluteExpiration); err != nil {
+			log.Printf("session gc error: %v", err)
+		}
+		cancel()
 	}
 }
 
@@ -114,48 +200,103 @@ func generateSessionID() string {
 	return base64.RawURLEncoding.EncodeToString(id)
 }
 
-func (m *SessionManager) validate(session *Session) bool {
+func (m *SessionManager) validate(ctx context.Context, session *Session) (bool, error) {
 	if session == nil {
-		return false
+		return false, nil
 	}
 	if time.Since(session.CreatedAt) > m.absoluteExpiration ||
 		time.Since(session.LastActivityAt) > m.idleExpiration {
-		m.store.destroy(session.ID)
-		return false
+		return false, m.store.destroy(ctx, session.ID)
 	}
-	return true
+	return true, nil
 }
 
-func (m *SessionManager) Create(userID string) *Session {
+// maxUserAgentLength bounds what a client can make us store per session.
+const maxUserAgentLength = 256
+
+func (m *SessionManager) Create(ctx context.Context, userID, userAgent, ip string) (*Session, error) {
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
 	now := time.Now()
 	session := &Session{
 		ID:             generateSessionID(),
 		UserID:         userID,
 		CreatedAt:      now,
 		LastActivityAt: now,
+		UserAgent:      userAgent,
+		IP:             ip,
 	}
-	m.store.write(session)
-	return session
+	if err := m.store.write(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
 }
 
-func (m *SessionManager) ReadValid(id string) *Session {
-	session := m.store.read(id)
-	if !m.validate(session) {
-		return nil
+// ReadValid returns the session if it exists and has not expired, or nil otherwise.
+func (m *SessionManager) ReadValid(ctx context.Context, id string) (*Session, error) {
+	session, err := m.store.read(ctx, id)
+	if err != nil {
+		return nil, err
 	}
-	return session
+	valid, err := m.validate(ctx, session)
+	if err != nil || !valid {
+		return nil, err
+	}
+	return session, nil
 }
 
-func (m *SessionManager) Touch(session *Session) {
+func (m *SessionManager) Touch(ctx context.Context, session *Session) error {
 	if session == nil {
-		return
+		return nil
 	}
 	session.LastActivityAt = time.Now()
-	m.store.write(session)
+	return m.store.refresh(ctx, session)
 }
 
-func (m *SessionManager) Destroy(id string) {
-	m.store.destroy(id)
+func (m *SessionManager) Destroy(ctx context.Context, id string) error {
+	return m.store.destroy(ctx, id)
+}
+
+// ListByUser returns the user's sessions that are still valid.
+func (m *SessionManager) ListByUser(ctx context.Context, userID string) ([]*Session, error) {
+	sessions, err := m.store.listByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	valid := make([]*Session, 0, len(sessions))
+	for _, session := range sessions {
+		ok, err := m.validate(ctx, session)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			valid = append(valid, session)
+		}
+	}
+	return valid, nil
+}
+
+// DestroyAllForUser removes every session of the user except exceptID (which may be
+// empty) and reports how many were removed.
+func (m *SessionManager) DestroyAllForUser(ctx context.Context, userID, exceptID string) (int, error) {
+	sessions, err := m.store.listByUser(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, session := range sessions {
+		if session.ID == exceptID {
+			continue
+		}
+		if err := m.store.destroy(ctx, session.ID); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
 }
 
 func (m *SessionManager) IdleExpiration() time.Duration {
@@ -166,9 +307,44 @@ func (m *SessionManager) AbsoluteExpiration() time.Duration {
 	return m.absoluteExpiration
 }
 
-var sessionManager = NewSessionManager(
-	NewInMemorySessionStore(),
-	envDuration("SESSION_GC_INTERVAL", 30*time.Minute),
-	envDuration("SESSION_IDLE_EXPIRATION", 1*time.Hour),
-	envDuration("SESSION_ABSOLUTE_EXPIRATION", 12*time.Hour),
-)
+// sessionManager is installed by the server at startup via SetSessionManager.
+var sessionManager *SessionManager
+
+// SetSessionManager installs the session manager used by the handlers and middleware.
+func SetSessionManager(m *SessionManager) {
+	sessionManager = m
+}
+
+// NewSessionManagerFromEnv builds a session manager whose store is selected by
+// SESSION_STORE: memory (default), mongo or redis.
+func NewSessionManagerFromEnv() (*SessionManager, error) {
+	gcInterval := envDuration("SESSION_GC_INTERVAL", 30*time.Minute)
+	idleExpiration := envDuration("SESSION_IDLE_EXPIRATION", 1*time.Hour)
+	absoluteExpiration := envDuration("SESSION_ABSOLUTE_EXPIRATION", 12*time.Hour)
+
+	var store SessionStore
+	switch driver := strings.ToLower(os.Getenv("SESSION_STORE")); driver {
+	case "", "memory":
+		store = NewInMemorySessionStore()
+	case "mongo", "mongodb":
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		mongoStore, err := NewMongoSessionStore(ctx, idleExpiration, absoluteExpiration)
+		if err != nil {
+			return nil, err
+		}
+		store = mongoStore
+	case "redis":
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		client, err := redisClientFromEnv(ctx)
+		if err != nil {
+			return nil, err
+		}
+		store = NewRedisSessionStore(client, redisKeyPrefix(), idleExpiration, absoluteExpiration)
+	default:
+		return nil, fmt.Errorf("unknown SESSION_STORE %q (expected memory, mongo or redis)", driver)
+	}
+
+	return NewSessionManager(store, gcInterval, idleExpiration, absoluteExpiration), nil
+}
@@ -0,0 +1,115 @@
+package routes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"FATE-Vault/backend/resp"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRedisSessionStore(t *testing.T) (*RedisSessionStore, *resp.FakeServer) {
+	t.Helper()
+	server, err := resp.NewFakeServer()
+	require.NoError(t, err)
+	t.Cleanup(func() { server.Close() })
+
+	client := resp.NewClient(server.Addr(), "", 0)
+	t.Cleanup(func() { client.Close() })
+
+	return NewRedisSessionStore(client, "test:", time.Hour, 12*time.Hour), server
+}
+
+func testSessionStoreContract(t *testing.T, store SessionStore) {
+	ctx := context.Background()
+	m := &SessionManager{store: store, idleExpiration: time.Hour, absoluteExpiration: 12 * time.Hour}
+
+	missing, err := m.ReadValid(ctx, "missing")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	first, err := m.Create(ctx, "user-1", "test-agent", "127.0.0.1")
+	require.NoError(t, err)
+	second, err := m.Create(ctx, "user-1", "test-agent", "127.0.0.1")
+	require.NoError(t, err)
+	_, err = m.Create(ctx, "user-2", "test-agent", "127.0.0.1")
+	require.NoError(t, err)
+
+	read, err := m.ReadValid(ctx, first.ID)
+	require.NoError(t, err)
+	require.NotNil(t, read)
+	assert.Equal(t, "user-1", read.UserID)
+	assert.WithinDuration(t, first.CreatedAt, read.CreatedAt, time.Millisecond)
+
+	sessions, err := m.ListByUser(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Len(t, sessions, 2)
+
+	require.NoError(t, m.Destroy(ctx, second.ID))
+	gone, err := m.ReadValid(ctx, second.ID)
+	require.NoError(t, err)
+	assert.Nil(t, gone)
+
+	// A request that read the session before it was revoked does not bring it back.
+	require.NoError(t, m.Touch(ctx, second))
+	gone, err = m.ReadValid(ctx, second.ID)
+	require.NoError(t, err)
+	assert.Nil(t, gone)
+
+	sessions, err = m.ListByUser(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Len(t, sessions, 1)
+
+	// A session idle for too long is rejected and removed.
+	read.LastActivityAt = time.Now().Add(-2 * time.Hour)
+	require.NoError(t, store.write(ctx, read))
+	expired, err := m.ReadValid(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Nil(t, expired)
+}
+
+func TestInMemorySessionStore_Contract(t *testing.T) {
+	testSessionStoreContract(t, NewInMemorySessionStore())
+}
+
+func TestRedisSessionStore_Contract(t *testing.T) {
+	store, _ := newTestRedisSessionStore(t)
+	testSessionStoreContract(t, store)
+}
+
+func TestRedisSessionStore_ExpiresWithTTL(t *testing.T) {
+	ctx := context.Background()
+	store, server := newTestRedisSessionStore(t)
+
+	now := time.Now()
+	session := &Session{ID: "abc", UserID: "user-1", CreatedAt: now, LastActivityAt: now}
+	require.NoError(t, store.write(ctx, session))
+
+	ttl, err := resp.Int64(store.client.Do(ctx, "PTTL", "test:session:abc"))
+	require.NoError(t, err)
+	assert.InDelta(t, time.Hour.Milliseconds(), ttl, float64(time.Second.Milliseconds()))
+
+	server.FastForward(2 * time.Hour)
+
+	read, err := store.read(ctx, "abc")
+	require.NoError(t, err)
+	assert.Nil(t, read)
+
+	sessions, err := store.listByUser(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+	members, err := resp.Strings(store.client.Do(ctx, "SMEMBERS", "test:user_sessions:user-1"))
+	require.NoError(t, err)
+	assert.Empty(t, members)
+}
+
+func TestSessionExpiresAt_UsesEarliestDeadline(t *testing.T) {
+	created := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	session := &Session{CreatedAt: created, LastActivityAt: created.Add(11 * time.Hour)}
+
+	assert.Equal(t, created.Add(12*time.Hour), session.expiresAt(time.Hour*2, 12*time.Hour))
+	assert.Equal(t, created.Add(11*time.Hour+30*time.Minute), session.expiresAt(30*time.Minute, 12*time.Hour))
+}
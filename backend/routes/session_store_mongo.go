@@ -0,0 +1,114 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"FATE-Vault/backend/db"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoSessionStore keeps sessions in the "sessions" collection. A TTL index on
+// expiresAt lets MongoDB drop expired sessions on its own.
+type MongoSessionStore struct {
+	idleExpiration     time.Duration
+	absoluteExpiration time.Duration
+}
+
+type mongoSession struct {
+	Session   `bson:",inline"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+func NewMongoSessionStore(ctx context.Context, idleExpiration, absoluteExpiration time.Duration) (*MongoSessionStore, error) {
+	if db.Client == nil {
+		return nil, errors.New("mongo session store requires a database connection")
+	}
+
+	s := &MongoSessionStore{
+		idleExpiration:     idleExpiration,
+		absoluteExpiration: absoluteExpiration,
+	}
+
+	_, err := s.collection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *MongoSessionStore) collection() *mongo.Collection {
+	return db.Client.Database("main").Collection("sessions")
+}
+
+func (s *MongoSessionStore) read(ctx context.Context, id string) (*Session, error) {
+	var doc mongoSession
+	err := s.collection().FindOne(ctx, bson.M{"_id": id}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &doc.Session, nil
+}
+
+func (s *MongoSessionStore) write(ctx context.Context, session *Session) error {
+	doc := mongoSession{
+		Session:   *session,
+		ExpiresAt: session.expiresAt(s.idleExpiration, s.absoluteExpiration),
+	}
+	_, err := s.collection().ReplaceOne(ctx, bson.M{"_id": session.ID}, doc, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (s *MongoSessionStore) refresh(ctx context.Context, session *Session) error {
+	_, err := s.collection().UpdateOne(ctx, bson.M{"_id": session.ID}, bson.M{"$set": bson.M{
+		"lastActivityAt": session.LastActivityAt,
+		"expiresAt":      session.expiresAt(s.idleExpiration, s.absoluteExpiration),
+	}})
+	return err
+}
+
+func (s *MongoSessionStore) destroy(ctx context.Context, id string) error {
+	_, err := s.collection().DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+// gc removes expired sessions right away; the TTL monitor only runs about once a minute.
+func (s *MongoSessionStore) gc(ctx context.Context, idleExpiration, absoluteExpiration time.Duration) error {
+	_, err := s.collection().DeleteMany(ctx, bson.M{"expiresAt": bson.M{"$lte": time.Now()}})
+	return err
+}
+
+func (s *MongoSessionStore) listByUser(ctx context.Context, userID string) ([]*Session, error) {
+	cur, err := s.collection().Find(ctx, bson.M{"userId": userID})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var result []*Session
+	for cur.Next(ctx) {
+		var doc mongoSession
+		if err := cur.Decode(&doc); err != nil {
+			return nil, err
+		}
+		session := doc.Session
+		result = append(result, &session)
+	}
+	return result, cur.Err()
+}
@@ -0,0 +1,160 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"FATE-Vault/backend/resp"
+)
+
+// RedisSessionStore keeps each session as a JSON string with a TTL, plus a set of
+// session IDs per user so sessions can be listed by user.
+type RedisSessionStore struct {
+	client             *resp.Client
+	prefix             string
+	idleExpiration     time.Duration
+	absoluteExpiration time.Duration
+}
+
+func NewRedisSessionStore(client *resp.Client, prefix string, idleExpiration, absoluteExpiration time.Duration) *RedisSessionStore {
+	return &RedisSessionStore{
+		client:             client,
+		prefix:             prefix,
+		idleExpiration:     idleExpiration,
+		absoluteExpiration: absoluteExpiration,
+	}
+}
+
+func redisKeyPrefix() string {
+	if v := os.Getenv("REDIS_KEY_PREFIX"); v != "" {
+		return v
+	}
+	return "fate-vault:"
+}
+
+// redisClientFromEnv connects to REDIS_ADDR (default localhost:6379) and checks it answers.
+func redisClientFromEnv(ctx context.Context) (*resp.Client, error) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	database := 0
+	if v := os.Getenv("REDIS_DB"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, errors.New("REDIS_DB must be a number")
+		}
+		database = n
+	}
+
+	client := resp.NewClient(addr, os.Getenv("REDIS_PASSWORD"), database)
+	if err := client.Ping(ctx); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func (s *RedisSessionStore) sessionKey(id string) string {
+	return s.prefix + "session:" + id
+}
+
+func (s *RedisSessionStore) userKey(userID string) string {
+	return s.prefix + "user_sessions:" + userID
+}
+
+func (s *RedisSessionStore) read(ctx context.Context, id string) (*Session, error) {
+	raw, err := resp.String(s.client.Do(ctx, "GET", s.sessionKey(id)))
+	if err == resp.ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var session Session
+	if err := json.Unmarshal([]byte(raw), &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (s *RedisSessionStore) write(ctx context.Context, session *Session) error {
+	return s.set(ctx, session)
+}
+
+func (s *RedisSessionStore) refresh(ctx context.Context, session *Session) error {
+	return s.set(ctx, session, "XX")
+}
+
+// set stores a session with extra SET options; a session that is not set
+// because of them is left out of the user index too.
+func (s *RedisSessionStore) set(ctx context.Context, session *Session, options ...string) error {
+	ttl := time.Until(session.expiresAt(s.idleExpiration, s.absoluteExpiration))
+	if ttl <= 0 {
+		return s.destroy(ctx, session.ID)
+	}
+
+	raw, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	ms := strconv.FormatInt(ttl.Milliseconds()+1, 10)
+	args := append([]string{"SET", s.sessionKey(session.ID), string(raw), "PX", ms}, options...)
+	reply, err := s.client.Do(ctx, args...)
+	if err != nil || reply == nil {
+		return err
+	}
+	if _, err := s.client.Do(ctx, "SADD", s.userKey(session.UserID), session.ID); err != nil {
+		return err
+	}
+	// The per-user index only needs to outlive the longest possible session.
+	_, err = s.client.Do(ctx, "PEXPIRE", s.userKey(session.UserID), strconv.FormatInt(s.absoluteExpiration.Milliseconds(), 10))
+	return err
+}
+
+func (s *RedisSessionStore) destroy(ctx context.Context, id string) error {
+	session, err := s.read(ctx, id)
+	if err != nil {
+		return err
+	}
+	if _, err := s.client.Do(ctx, "DEL", s.sessionKey(id)); err != nil {
+		return err
+	}
+	if session != nil {
+		_, err = s.client.Do(ctx, "SREM", s.userKey(session.UserID), id)
+	}
+	return err
+}
+
+// gc is a no-op: Redis expires session keys itself and listByUser prunes the user index.
+func (s *RedisSessionStore) gc(ctx context.Context, idleExpiration, absoluteExpiration time.Duration) error {
+	return nil
+}
+
+func (s *RedisSessionStore) listByUser(ctx context.Context, userID string) ([]*Session, error) {
+	ids, err := resp.Strings(s.client.Do(ctx, "SMEMBERS", s.userKey(userID)))
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*Session
+	for _, id := range ids {
+		session, err := s.read(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if session == nil {
+			if _, err := s.client.Do(ctx, "SREM", s.userKey(userID), id); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		result = append(result, session)
+	}
+	return result, nil
+}
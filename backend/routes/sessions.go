@@ -0,0 +1,155 @@
+package routes
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionView is what clients see of a session. The raw session ID is a bearer
+// secret, so sessions are addressed by a handle derived from it instead.
+type SessionView struct {
+	ID             string    `json:"id"`
+	UserAgent      string    `json:"userAgent"`
+	IP             string    `json:"ip"`
+	CreatedAt      time.Time `json:"createdAt"`
+	LastActivityAt time.Time `json:"lastActivityAt"`
+	Current        bool      `json:"current"`
+}
+
+func sessionHandle(sessionID string) string {
+	sum := sha256.Sum256([]byte(sessionID))
+	return base64.RawURLEncoding.EncodeToString(sum[:16])
+}
+
+func currentSessionID(c *gin.Context) string {
+	id, _ := c.Get("sessionId")
+	s, _ := id.(string)
+	return s
+}
+
+func currentUserID(c *gin.Context) string {
+	id, _ := c.Get("userId")
+	s, _ := id.(string)
+	return s
+}
+
+// ListMySessions lists the caller's active sessions, most recently used first.
+func ListMySessions(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	userID := currentUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	sessions, err := sessionManager.ListByUser(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list sessions: " + err.Error()})
+		return
+	}
+
+	current := currentSessionID(c)
+	views := make([]SessionView, 0, len(sessions))
+	for _, s := range sessions {
+		views = append(views, SessionView{
+			ID:             sessionHandle(s.ID),
+			UserAgent:      s.UserAgent,
+			IP:             s.IP,
+			CreatedAt:      s.CreatedAt,
+			LastActivityAt: s.LastActivityAt,
+			Current:        s.ID == current,
+		})
+	}
+	sort.Slice(views, func(i, j int) bool {
+		return views[i].LastActivityAt.After(views[j].LastActivityAt)
+	})
+
+	c.IndentedJSON(http.StatusOK, views)
+}
+
+// RevokeMySession ends one of the caller's sessions, identified by its handle.
+func RevokeMySession(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	userID := currentUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	handle := c.Param("id")
+	sessions, err := sessionManager.ListByUser(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list sessions: " + err.Error()})
+		return
+	}
+
+	for _, s := range sessions {
+		if sessionHandle(s.ID) != handle {
+			continue
+		}
+		if err := sessionManager.Destroy(ctx, s.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke session: " + err.Error()})
+			return
+		}
+		if s.ID == currentSessionID(c) {
+			clearSessionCookie(c)
+		}
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+}
+
+// RevokeMyOtherSessions logs the caller out everywhere except the current session.
+func RevokeMyOtherSessions(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	userID := currentUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	revoked, err := sessionManager.DestroyAllForUser(ctx, userID, currentSessionID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revoked": revoked})
+}
+
+// RevokeUserSessions lets an admin log a user out of every session.
+func RevokeUserSessions(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id parameter is required"})
+		return
+	}
+
+	revoked, err := sessionManager.DestroyAllForUser(ctx, id, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions: " + err.Error()})
+		return
+	}
+	if id == currentUserID(c) {
+		clearSessionCookie(c)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revoked": revoked})
+}
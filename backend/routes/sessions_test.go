@@ -0,0 +1,126 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupSessionsRouter installs a fresh in-memory session manager and a router
+// that authenticates every request as the given session.
+func setupSessionsRouter(t *testing.T, current *Session) *gin.Engine {
+	t.Helper()
+	previous := sessionManager
+	SetSessionManager(&SessionManager{store: NewInMemorySessionStore(), idleExpiration: time.Hour, absoluteExpiration: 12 * time.Hour})
+	t.Cleanup(func() { SetSessionManager(previous) })
+
+	router := setupRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set("userId", current.UserID)
+		c.Set("sessionId", current.ID)
+		c.Next()
+	})
+	router.GET("/users/me/sessions", ListMySessions)
+	router.DELETE("/users/me/sessions", RevokeMyOtherSessions)
+	router.DELETE("/users/me/sessions/:id", RevokeMySession)
+	router.DELETE("/admin/users/:id/sessions", RevokeUserSessions)
+	return router
+}
+
+func createTestSessions(t *testing.T, userID string, n int) []*Session {
+	t.Helper()
+	sessions := make([]*Session, n)
+	for i := range sessions {
+		s, err := sessionManager.Create(context.Background(), userID, "agent", "10.0.0.1")
+		require.NoError(t, err)
+		sessions[i] = s
+	}
+	return sessions
+}
+
+func TestListMySessions_HidesRawIDsAndMarksCurrent(t *testing.T) {
+	current := &Session{UserID: "user-1"}
+	router := setupSessionsRouter(t, current)
+	sessions := createTestSessions(t, "user-1", 2)
+	createTestSessions(t, "user-2", 1)
+	current.ID = sessions[0].ID
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/me/sessions", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), sessions[0].ID)
+	var views []SessionView
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &views))
+	require.Len(t, views, 2)
+	currentCount := 0
+	for _, v := range views {
+		assert.Equal(t, "agent", v.UserAgent)
+		assert.Equal(t, "10.0.0.1", v.IP)
+		if v.Current {
+			currentCount++
+			assert.Equal(t, sessionHandle(sessions[0].ID), v.ID)
+		}
+	}
+	assert.Equal(t, 1, currentCount)
+}
+
+func TestRevokeMySession(t *testing.T) {
+	current := &Session{UserID: "user-1"}
+	router := setupSessionsRouter(t, current)
+	sessions := createTestSessions(t, "user-1", 2)
+	other := createTestSessions(t, "user-2", 1)
+	current.ID = sessions[0].ID
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/users/me/sessions/"+sessionHandle(other[0].ID), nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/users/me/sessions/"+sessionHandle(sessions[1].ID), nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	remaining, err := sessionManager.ListByUser(context.Background(), "user-1")
+	require.NoError(t, err)
+	require.Len(t, remaining, 1)
+	assert.Equal(t, sessions[0].ID, remaining[0].ID)
+}
+
+func TestRevokeMyOtherSessions_KeepsCurrent(t *testing.T) {
+	current := &Session{UserID: "user-1"}
+	router := setupSessionsRouter(t, current)
+	sessions := createTestSessions(t, "user-1", 3)
+	current.ID = sessions[1].ID
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/users/me/sessions", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"revoked":2}`, w.Body.String())
+	remaining, err := sessionManager.ListByUser(context.Background(), "user-1")
+	require.NoError(t, err)
+	require.Len(t, remaining, 1)
+	assert.Equal(t, sessions[1].ID, remaining[0].ID)
+}
+
+func TestRevokeUserSessions(t *testing.T) {
+	router := setupSessionsRouter(t, &Session{ID: "admin-session", UserID: "admin"})
+	createTestSessions(t, "user-1", 2)
+	createTestSessions(t, "user-2", 1)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/admin/users/user-1/sessions", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"revoked":2}`, w.Body.String())
+	remaining, err := sessionManager.ListByUser(context.Background(), "user-2")
+	require.NoError(t, err)
+	assert.Len(t, remaining, 1)
+}
@@ -0,0 +1,117 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// StoredFile is a single entry returned by the storage service /list endpoint.
+type StoredFile struct {
+	Name         string    `json:"name"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"lastModified"`
+	ContentType  string    `json:"contentType"`
+}
+
+// StorageClient talks to the standalone storage service over HTTP.
+type StorageClient struct {
+	baseURL    string
+	httpClient *http.Client
+	// serviceSecret is sent as X-Service-Secret so the storage service lets the
+	// backend manage every user's files.
+	serviceSecret string
+}
+
+func NewStorageClient(baseURL string) *StorageClient {
+	return &StorageClient{
+		baseURL:       strings.TrimRight(baseURL, "/"),
+		httpClient:    &http.Client{Timeout: 30 * time.Second},
+		serviceSecret: os.Getenv("STORAGE_SERVICE_SECRET"),
+	}
+}
+
+func (s *StorageClient) do(req *http.Request) (*http.Response, error) {
+	if s.serviceSecret != "" {
+		req.Header.Set("X-Service-Secret", s.serviceSecret)
+	}
+	return s.httpClient.Do(req)
+}
+
+func storageServiceURL() string {
+	if v := os.Getenv("STORAGE_URL"); v != "" {
+		return v
+	}
+	return "http://localhost:8081"
+}
+
+// List returns every object stored under folder, following the service's cursors.
+func (s *StorageClient) List(ctx context.Context, folder string) ([]StoredFile, error) {
+	var files []StoredFile
+	cursor := ""
+	for {
+		query := url.Values{}
+		query.Set("limit", "1000")
+		if folder != "" {
+			query.Set("folder", folder)
+		}
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.baseURL+"/list?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := s.do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		var body struct {
+			Files      []StoredFile `json:"files"`
+			NextCursor *string      `json:"nextCursor"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("storage list returned status %d", resp.StatusCode)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decode storage list: %w", err)
+		}
+
+		files = append(files, body.Files...)
+		if body.NextCursor == nil || *body.NextCursor == "" {
+			return files, nil
+		}
+		cursor = *body.NextCursor
+	}
+}
+
+// Delete removes a single object by its key.
+func (s *StorageClient) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.baseURL+"/delete/"+key, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("storage delete of %q returned status %d", key, resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,346 @@
+package routes
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"FATE-Vault/backend/db"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// OrphanReport is the result of comparing storage objects against database references.
+type OrphanReport struct {
+	GeneratedAt       time.Time    `json:"generatedAt"`
+	DryRun            bool         `json:"dryRun"`
+	Prefix            string       `json:"prefix"`
+	GracePeriod       string       `json:"gracePeriod"`
+	ScannedObjects    int          `json:"scannedObjects"`
+	ReferencedObjects int          `json:"referencedObjects"`
+	Orphans           []StoredFile `json:"orphans"`
+	OrphanBytes       int64        `json:"orphanBytes"`
+	Deleted           []string     `json:"deleted,omitempty"`
+	Errors            []string     `json:"errors,omitempty"`
+}
+
+var (
+	lastStorageReportMu sync.RWMutex
+	lastStorageReport   *OrphanReport
+)
+
+func storageGCPrefix() string {
+	if v := os.Getenv("STORAGE_GC_PREFIX"); v != "" {
+		return v
+	}
+	return "images/"
+}
+
+// storagePublicURL is the base URL the web client builds download links from
+// (its VITE_STORAGE_URL), e.g. /storage or https://cdn.example.com/files.
+func storagePublicURL() string {
+	if v := os.Getenv("STORAGE_PUBLIC_URL"); v != "" {
+		return v
+	}
+	return "/storage"
+}
+
+// storageKeyPrefixes are the path prefixes a stored reference may put before an
+// object key: download links through the public URL and straight to the storage
+// service.
+func storageKeyPrefixes() []string {
+	var prefixes []string
+	for _, base := range []string{storagePublicURL(), storageServiceURL()} {
+		path := base
+		if u, err := url.Parse(base); err == nil {
+			path = u.Path
+		}
+		prefix := strings.TrimPrefix(strings.Trim(path, "/")+"/download/", "/")
+		if !containsString(prefixes, prefix) {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeStorageKey turns whatever the client stored (object key, download
+// path or absolute URL) into the bare object key used by the storage service,
+// stripping the first of prefixes the path starts with.
+func normalizeStorageKey(ref string, prefixes []string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	if u, err := url.Parse(ref); err == nil && u.Scheme != "" {
+		ref = u.Path
+	}
+	if i := strings.IndexAny(ref, "?#"); i >= 0 {
+		ref = ref[:i]
+	}
+	ref = strings.TrimPrefix(ref, "/")
+	for _, prefix := range prefixes {
+		if key, ok := strings.CutPrefix(ref, prefix); ok {
+			return key
+		}
+	}
+	return ref
+}
+
+// imageReferenceFields lists where documents keep storage references, as a
+// collection and a dotted path to a string or a list of strings. Templates embed
+// a character sheet, and archived template versions keep a whole template.
+var imageReferenceFields = []struct{ collection, path string }{
+	{"characters", "images"},
+	{"templates", "images"},
+	{"template_versions", "template.images"},
+	{"users", "profilePicture"},
+}
+
+// collectImageReferences gathers every object key referenced by the documents in
+// imageReferenceFields.
+func collectImageReferences(ctx context.Context) (map[string]struct{}, error) {
+	refs := make(map[string]struct{})
+	prefixes := storageKeyPrefixes()
+	database := db.Client.Database("main")
+
+	for _, field := range imageReferenceFields {
+		cur, err := database.Collection(field.collection).Find(ctx,
+			bson.M{field.path: bson.M{"$exists": true}},
+			options.Find().SetProjection(bson.M{field.path: 1}))
+		if err != nil {
+			return nil, err
+		}
+		for cur.Next(ctx) {
+			for _, ref := range referencesAt(cur.Current, field.path) {
+				if key := normalizeStorageKey(ref, prefixes); key != "" {
+					refs[key] = struct{}{}
+				}
+			}
+		}
+		err = cur.Err()
+		cur.Close(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return refs, nil
+}
+
+// referencesAt returns the string or strings at a dotted path of doc.
+func referencesAt(doc bson.Raw, path string) []string {
+	value, err := doc.LookupErr(strings.Split(path, ".")...)
+	if err != nil {
+		return nil
+	}
+	if s, ok := value.StringValueOK(); ok {
+		return []string{s}
+	}
+	values, ok := value.ArrayOK()
+	if !ok {
+		return nil
+	}
+	elements, err := values.Values()
+	if err != nil {
+		return nil
+	}
+	var refs []string
+	for _, element := range elements {
+		if s, ok := element.StringValueOK(); ok {
+			refs = append(refs, s)
+		}
+	}
+	return refs
+}
+
+// findOrphans returns unreferenced objects last modified before now minus the grace period.
+func findOrphans(objects []StoredFile, refs map[string]struct{}, grace time.Duration, now time.Time) []StoredFile {
+	cutoff := now.Add(-grace)
+	orphans := []StoredFile{}
+	for _, object := range objects {
+		if _, ok := refs[object.Name]; ok {
+			continue
+		}
+		if object.LastModified.After(cutoff) {
+			continue
+		}
+		orphans = append(orphans, object)
+	}
+	return orphans
+}
+
+// ReconcileStorage lists storage objects under the configured prefix and reports,
+// or with dryRun=false deletes, the ones nothing in the database points to.
+func ReconcileStorage(ctx context.Context, client *StorageClient, grace time.Duration, dryRun bool) (*OrphanReport, error) {
+	prefix := storageGCPrefix()
+
+	// Collect references first so objects uploaded mid-scan are covered by the grace period.
+	refs, err := collectImageReferences(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := client.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &OrphanReport{
+		GeneratedAt:       time.Now().UTC(),
+		DryRun:            dryRun,
+		Prefix:            prefix,
+		GracePeriod:       grace.String(),
+		ScannedObjects:    len(objects),
+		ReferencedObjects: len(refs),
+		Orphans:           findOrphans(objects, refs, grace, time.Now()),
+	}
+	for _, orphan := range report.Orphans {
+		report.OrphanBytes += orphan.Size
+	}
+
+	if !dryRun {
+		for _, orphan := range report.Orphans {
+			if err := client.Delete(ctx, orphan.Name); err != nil {
+				report.Errors = append(report.Errors, err.Error())
+				continue
+			}
+			report.Deleted = append(report.Deleted, orphan.Name)
+		}
+	}
+
+	lastStorageReportMu.Lock()
+	lastStorageReport = report
+	lastStorageReportMu.Unlock()
+
+	return report, nil
+}
+
+// StartStorageGC runs ReconcileStorage periodically when STORAGE_GC_INTERVAL is set.
+// Objects are only deleted when STORAGE_GC_DELETE=true; otherwise the job just logs reports.
+func StartStorageGC() {
+	interval := envDuration("STORAGE_GC_INTERVAL", 0)
+	if interval <= 0 {
+		return
+	}
+	grace := envDuration("STORAGE_GC_GRACE_PERIOD", 24*time.Hour)
+	dryRun := os.Getenv("STORAGE_GC_DELETE") != "true"
+	client := NewStorageClient(storageServiceURL())
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		for range ticker.C {
+			if db.Client == nil {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+			report, err := ReconcileStorage(ctx, client, grace, dryRun)
+			cancel()
+			if err != nil {
+				log.Printf("storage gc error: %v", err)
+				continue
+			}
+			log.Printf("storage gc: scanned %d objects, %d orphans, %d deleted (dry run: %t)",
+				report.ScannedObjects, len(report.Orphans), len(report.Deleted), report.DryRun)
+		}
+	}()
+}
+
+func storageGCParams(c *gin.Context) (time.Duration, bool) {
+	grace := envDuration("STORAGE_GC_GRACE_PERIOD", 24*time.Hour)
+	if v := c.Query("grace"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "grace must be a non-negative duration, e.g. 24h"})
+			return 0, false
+		}
+		grace = d
+	}
+	return grace, true
+}
+
+// GetStorageOrphans reports orphaned storage objects without deleting anything.
+func GetStorageOrphans(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
+	}
+
+	grace, ok := storageGCParams(c)
+	if !ok {
+		return
+	}
+
+	report, err := ReconcileStorage(ctx, NewStorageClient(storageServiceURL()), grace, true)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to reconcile storage: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// CleanupStorageOrphans reports orphaned storage objects like GetStorageOrphans
+// and only deletes them when called with ?confirm=true.
+func CleanupStorageOrphans(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
+	}
+
+	grace, ok := storageGCParams(c)
+	if !ok {
+		return
+	}
+	confirm := false
+	if v := c.Query("confirm"); v != "" {
+		var err error
+		if confirm, err = strconv.ParseBool(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "confirm must be true or false"})
+			return
+		}
+	}
+
+	report, err := ReconcileStorage(ctx, NewStorageClient(storageServiceURL()), grace, !confirm)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to reconcile storage: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetLastStorageReport returns the most recent report from a manual or scheduled run.
+func GetLastStorageReport(c *gin.Context) {
+	lastStorageReportMu.RLock()
+	report := lastStorageReport
+	lastStorageReportMu.RUnlock()
+
+	if report == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no storage reconciliation has run yet"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
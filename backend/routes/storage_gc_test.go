@@ -0,0 +1,131 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"FATE-Vault/backend/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestNormalizeStorageKey(t *testing.T) {
+	t.Setenv("STORAGE_PUBLIC_URL", "")
+	t.Setenv("STORAGE_URL", "")
+	prefixes := storageKeyPrefixes()
+	assert.Equal(t, []string{"storage/download/", "download/"}, prefixes)
+
+	cases := map[string]string{
+		"images/characters/abc/file_1":                           "images/characters/abc/file_1",
+		"/images/characters/abc/file_1":                          "images/characters/abc/file_1",
+		"/storage/download/images/characters/abc/file_1":         "images/characters/abc/file_1",
+		"http://localhost:8081/download/images/users/file_2?x=1": "images/users/file_2",
+		"   ": "",
+	}
+	for in, want := range cases {
+		assert.Equal(t, want, normalizeStorageKey(in, prefixes), "input %q", in)
+	}
+}
+
+func TestNormalizeStorageKey_ConfiguredPublicURL(t *testing.T) {
+	t.Setenv("STORAGE_PUBLIC_URL", "https://cdn.example.com/files/")
+	t.Setenv("STORAGE_URL", "http://storage:8081/api")
+	prefixes := storageKeyPrefixes()
+	assert.Equal(t, []string{"files/download/", "api/download/"}, prefixes)
+
+	assert.Equal(t, "images/users/a", normalizeStorageKey("https://cdn.example.com/files/download/images/users/a", prefixes))
+	assert.Equal(t, "images/users/b", normalizeStorageKey("/api/download/images/users/b", prefixes))
+	// Only whole prefixes are stripped; "storage/" is an ordinary folder here.
+	assert.Equal(t, "storage/download/images/c", normalizeStorageKey("/storage/download/images/c", prefixes))
+}
+
+func TestReferencesAt_TemplatesAndVersions(t *testing.T) {
+	template := models.Template{Character: models.Character{Images: []string{"/storage/download/images/templates/a", ""}}}
+	version := models.TemplateVersion{TemplateID: "t-1", Template: template}
+
+	templateDoc, err := bson.Marshal(template)
+	require.NoError(t, err)
+	versionDoc, err := bson.Marshal(version)
+	require.NoError(t, err)
+	userDoc, err := bson.Marshal(bson.M{"profilePicture": "images/users/b"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"/storage/download/images/templates/a", ""}, referencesAt(templateDoc, "images"))
+	assert.Equal(t, []string{"/storage/download/images/templates/a", ""}, referencesAt(versionDoc, "template.images"))
+	assert.Equal(t, []string{"images/users/b"}, referencesAt(userDoc, "profilePicture"))
+	assert.Empty(t, referencesAt(userDoc, "images"))
+
+	scanned := map[string]string{}
+	for _, field := range imageReferenceFields {
+		scanned[field.collection] = field.path
+	}
+	assert.Equal(t, "images", scanned["templates"])
+	assert.Equal(t, "template.images", scanned["template_versions"])
+}
+
+func TestFindOrphans_RespectsReferencesAndGracePeriod(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	objects := []StoredFile{
+		{Name: "images/characters/1/file_a", LastModified: now.Add(-72 * time.Hour)},
+		{Name: "images/characters/1/file_b", LastModified: now.Add(-72 * time.Hour)},
+		{Name: "images/characters/2/file_c", LastModified: now.Add(-1 * time.Hour)},
+	}
+	refs := map[string]struct{}{"images/characters/1/file_a": {}}
+
+	orphans := findOrphans(objects, refs, 24*time.Hour, now)
+
+	assert.Len(t, orphans, 1)
+	assert.Equal(t, "images/characters/1/file_b", orphans[0].Name)
+}
+
+func TestStorageClient_ListAndDelete(t *testing.T) {
+	var deleted, secret string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/list":
+			assert.Equal(t, "images/", r.URL.Query().Get("folder"))
+			if r.URL.Query().Get("cursor") == "" {
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"files": []map[string]interface{}{
+						{"name": "images/a", "size": 3, "lastModified": time.Now()},
+					},
+					"count":      1,
+					"nextCursor": "next",
+				})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"files": []map[string]interface{}{
+					{"name": "images/b", "size": 4, "lastModified": time.Now()},
+				},
+				"count":      1,
+				"nextCursor": nil,
+			})
+		case r.Method == http.MethodDelete:
+			deleted = r.URL.Path
+			secret = r.Header.Get("X-Service-Secret")
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	t.Setenv("STORAGE_SERVICE_SECRET", "s3cret")
+	client := NewStorageClient(ts.URL + "/")
+	files, err := client.List(context.Background(), "images/")
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+	assert.Equal(t, int64(3), files[0].Size)
+	assert.Equal(t, "images/b", files[1].Name)
+
+	assert.NoError(t, client.Delete(context.Background(), "images/a"))
+	assert.Equal(t, "/delete/images/a", deleted)
+	assert.Equal(t, "s3cret", secret)
+}
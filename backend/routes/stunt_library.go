@@ -0,0 +1,273 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"FATE-Vault/backend/db"
+	"FATE-Vault/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type PromoteStuntRequest struct {
+	CharacterID string             `json:"characterId" binding:"required"`
+	Index       *int               `json:"index" binding:"required"`
+	Skill       string             `json:"skill,omitempty"`
+	Action      models.StuntAction `json:"action,omitempty"`
+	Tags        []string           `json:"tags,omitempty"`
+}
+
+func stuntsCollection() *mongo.Collection {
+	return db.Client.Database("main").Collection("stunts")
+}
+
+func validStuntAction(action models.StuntAction) bool {
+	switch action {
+	case "", models.OvercomeAction, models.CreateAdvantageAction, models.AttackAction, models.DefendAction:
+		return true
+	}
+	return false
+}
+
+// validateStunt tidies a library stunt before it is stored.
+func validateStunt(stunt *models.Stunt) error {
+	stunt.Name = strings.TrimSpace(stunt.Name)
+	if stunt.Name == "" {
+		return errors.New("name is required")
+	}
+	if err := validateMechanics(&stunt.StuntMechanics); err != nil {
+		return err
+	}
+	tags, err := normalizeTags(stunt.Tags)
+	if err != nil {
+		return err
+	}
+	stunt.Tags = tags
+	return nil
+}
+
+// stuntListFilter narrows the stunt list by ?edition=, ?skill= and ?action=.
+func stuntListFilter(c *gin.Context) (bson.M, error) {
+	filter := bson.M{}
+	if edition := c.Query("edition"); edition != "" {
+		filter["edition"] = edition
+	}
+	if skill := canonicalSkill(c.Query("skill")); skill != "" {
+		filter["skill"] = bson.M{"$regex": "^" + regexp.QuoteMeta(skill) + "$", "$options": "i"}
+	}
+	if action := models.StuntAction(c.Query("action")); action != "" {
+		if !validStuntAction(action) {
+			return nil, fmt.Errorf("unknown action %q", action)
+		}
+		filter["action"] = action
+	}
+	return filter, nil
+}
+
+// resolveCharacterStunts checks the mechanics and library links of a character's
+// stunts and copies the library text and mechanics into every linked stunt that
+// is not overridden.
+func resolveCharacterStunts(ctx context.Context, stunts []models.CharacterStunt) error {
+	var ids []string
+	for i := range stunts {
+		if err := validateMechanics(&stunts[i].StuntMechanics); err != nil {
+			return invalidStuntError{fmt.Sprintf("stunt %q: %v", stunts[i].Name, err)}
+		}
+		if stunts[i].Used < 0 {
+			stunts[i].Used = 0
+		}
+		if stunts[i].StuntID == "" {
+			// Only linked stunts can override library text.
+			stunts[i].Override = false
+			continue
+		}
+		ids = append(ids, stunts[i].StuntID)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	cur, err := stuntsCollection().Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return err
+	}
+	var library []models.Stunt
+	if err := cur.All(ctx, &library); err != nil {
+		return err
+	}
+	byID := make(map[string]models.Stunt, len(library))
+	for _, s := range library {
+		byID[s.ID] = s
+	}
+
+	for i := range stunts {
+		if stunts[i].StuntID == "" {
+			continue
+		}
+		linked, ok := byID[stunts[i].StuntID]
+		if !ok {
+			return invalidStuntError{fmt.Sprintf("library stunt %q not found", stunts[i].StuntID)}
+		}
+		if !stunts[i].Override {
+			stunts[i].Name = linked.Name
+			stunts[i].Description = linked.Description
+			stunts[i].StuntMechanics = linked.StuntMechanics
+		}
+	}
+	return nil
+}
+
+// invalidStuntError is a problem with the stunts a client sent, as opposed to a
+// failure looking them up.
+type invalidStuntError struct{ msg string }
+
+func (e invalidStuntError) Error() string {
+	return e.msg
+}
+
+// propagateStunt copies a library stunt's text and mechanics to the characters
+// linking to it, except where the sheet overrides it. Use counters are kept.
+func propagateStunt(ctx context.Context, stunt models.Stunt) (int64, error) {
+	set := mechanicsFields("stunts.$[s]", stunt.StuntMechanics)
+	set["stunts.$[s].name"] = stunt.Name
+	set["stunts.$[s].description"] = stunt.Description
+	update := bson.M{"$set": set}
+	opts := options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{
+		bson.M{"s.stuntId": stunt.ID, "s.override": bson.M{"$ne": true}},
+	}})
+	result, err := db.Client.Database("main").Collection("characters").UpdateMany(ctx, bson.M{"stunts.stuntId": stunt.ID}, update, opts)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// unlinkStunt turns the links to a deleted library stunt into the characters' own
+// stunts, keeping their text.
+func unlinkStunt(ctx context.Context, stuntID string) error {
+	update := bson.M{"$unset": bson.M{
+		"stunts.$[s].stuntId":  "",
+		"stunts.$[s].override": "",
+	}}
+	opts := options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{
+		bson.M{"s.stuntId": stuntID},
+	}})
+	_, err := db.Client.Database("main").Collection("characters").UpdateMany(ctx, bson.M{"stunts.stuntId": stuntID}, update, opts)
+	return err
+}
+
+// StuntCharacters lists the characters visible to the caller that use a library
+// stunt.
+func StuntCharacters(c *gin.Context) {
+	filter := bson.M{"$and": bson.A{visibilityFilter(c), bson.M{"stunts.stuntId": c.Param("id")}}}
+	respondWithList(c, "characters", filter, "name", localizeSheetDocument(requestLocale(c)))
+}
+
+// PromoteStunt copies a character's own stunt into the library and links the
+// character's stunt to the new library stunt.
+func PromoteStunt(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var req PromoteStuntRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
+	}
+
+	characters := db.Client.Database("main").Collection("characters")
+	owned, err := ownedCharacterFilter(ctx, c, req.CharacterID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load roles: " + err.Error()})
+		return
+	}
+	var character models.Character
+	err = characters.FindOne(ctx, owned).Decode(&character)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "character not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load character: " + err.Error()})
+		return
+	}
+
+	index := *req.Index
+	if index < 0 || index >= len(character.Stunts) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "index does not refer to a stunt of the character"})
+		return
+	}
+	own := character.Stunts[index]
+	if own.StuntID != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "stunt is already in the library"})
+		return
+	}
+
+	mechanics := own.StuntMechanics
+	if req.Skill != "" {
+		mechanics.Skill = req.Skill
+	}
+	if req.Action != "" {
+		mechanics.Action = req.Action
+	}
+
+	now := time.Now()
+	stunt := models.Stunt{
+		ID:             uuid.NewString(),
+		Edition:        character.Edition,
+		Name:           own.Name,
+		Description:    own.Description,
+		StuntMechanics: mechanics,
+		Tags:           req.Tags,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := validateStunt(&stunt); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := stuntsCollection().InsertOne(ctx, stunt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create stunt: " + err.Error()})
+		return
+	}
+
+	// Match the stunt's text as well as its position so a concurrent edit of the
+	// sheet is not linked to the wrong stunt.
+	field := "stunts." + strconv.Itoa(index)
+	filter := bson.M{"_id": character.ID, field + ".name": own.Name, field + ".stuntId": bson.M{"$exists": false}}
+	link := bson.M{field + ".stuntId": stunt.ID}
+	for k, v := range mechanicsFields(field, stunt.StuntMechanics) {
+		link[k] = v
+	}
+	result, err := characters.UpdateOne(ctx, filter, bson.M{"$set": link})
+	if err != nil || result.MatchedCount == 0 {
+		stuntsCollection().DeleteOne(ctx, bson.M{"_id": stunt.ID})
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to link stunt: " + err.Error()})
+		return
+	}
+	if result.MatchedCount == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "character changed while promoting the stunt"})
+		return
+	}
+
+	stunt.Skill = skillName(stunt.Skill, requestLocale(c))
+	c.JSON(http.StatusCreated, stunt)
+}
@@ -0,0 +1,82 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"FATE-Vault/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestValidateStunt(t *testing.T) {
+	stunt := models.Stunt{
+		Name:           "  Увечье ",
+		StuntMechanics: models.StuntMechanics{Skill: " Драка ", Action: models.AttackAction},
+		Tags:           []string{"Combat"},
+	}
+	require.NoError(t, validateStunt(&stunt))
+	assert.Equal(t, "Увечье", stunt.Name)
+	assert.Equal(t, "fight", stunt.Skill)
+	assert.Equal(t, []string{"combat"}, stunt.Tags)
+
+	assert.Error(t, validateStunt(&models.Stunt{Name: " "}))
+	assert.Error(t, validateStunt(&models.Stunt{Name: "Увечье", StuntMechanics: models.StuntMechanics{Action: "dodge"}}))
+}
+
+func TestStuntListFilter(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/stunts?edition=accelerated&skill=Force(ful)&action=createAdvantage", nil)
+
+	filter, err := stuntListFilter(c)
+
+	require.NoError(t, err)
+	assert.Equal(t, bson.M{
+		"edition": "accelerated",
+		"skill":   bson.M{"$regex": `^Force\(ful\)$`, "$options": "i"},
+		"action":  models.CreateAdvantageAction,
+	}, filter)
+
+	c, _ = gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/stunts?action=dodge", nil)
+	_, err = stuntListFilter(c)
+	assert.Error(t, err)
+}
+
+func TestResolveCharacterStunts_OwnStunts(t *testing.T) {
+	stunts := []models.CharacterStunt{{Name: "Свой трюк", Override: true}}
+
+	require.NoError(t, resolveCharacterStunts(context.Background(), stunts))
+	assert.False(t, stunts[0].Override)
+}
+
+func TestStuntEndpoints_Validation(t *testing.T) {
+	router := setupRouter()
+	router.POST("/stunts/create", CreateStunt)
+	router.POST("/stunts/promote", PromoteStunt)
+	router.GET("/stunts", ListStunts)
+
+	cases := map[string]struct {
+		method, url string
+		body        interface{}
+		want        int
+	}{
+		"create without name":   {http.MethodPost, "/stunts/create", gin.H{"edition": "core"}, http.StatusBadRequest},
+		"create bad action":     {http.MethodPost, "/stunts/create", gin.H{"name": "Увечье", "action": "dodge"}, http.StatusBadRequest},
+		"create valid":          {http.MethodPost, "/stunts/create", gin.H{"name": "Увечье", "action": "attack"}, http.StatusInternalServerError},
+		"promote without index": {http.MethodPost, "/stunts/promote", gin.H{"characterId": "c1"}, http.StatusBadRequest},
+		"promote valid":         {http.MethodPost, "/stunts/promote", gin.H{"characterId": "c1", "index": 0}, http.StatusInternalServerError},
+		"list bad action":       {http.MethodGet, "/stunts?action=dodge", nil, http.StatusBadRequest},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := serve(router, tc.method, tc.url, tc.body)
+			assert.Equal(t, tc.want, w.Code)
+		})
+	}
+}
@@ -0,0 +1,343 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"FATE-Vault/backend/db"
+	"FATE-Vault/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	maxStuntBonus      = 4
+	maxStuntUses       = 10
+	maxStuntFatePoints = 3
+	maxStuntConditions = 500
+)
+
+type ResetStuntUsesRequest struct {
+	// Scope is "scene" or "session". A new scene resets scene-limited stunts; a
+	// new session resets every counter.
+	Scope models.StuntLimit `json:"scope" binding:"required"`
+}
+
+// ApplicableStunt is a stunt on a character that applies to a roll.
+type ApplicableStunt struct {
+	Index int `json:"index"`
+	models.CharacterStunt
+	// Remaining is how many uses are left for a limited stunt.
+	Remaining *int `json:"remaining,omitempty"`
+	// Usable is false when the stunt has no uses left or costs more fate points
+	// than the character has.
+	Usable bool `json:"usable"`
+}
+
+func validStuntLimit(limit models.StuntLimit) bool {
+	switch limit {
+	case models.UnlimitedStunt, models.PerSceneStunt, models.PerSessionStunt:
+		return true
+	}
+	return false
+}
+
+// validateMechanics checks a stunt's mechanics and fills in defaults: a limited
+// stunt can be used once unless it says otherwise. Skills from the vocabulary
+// are stored by identifier.
+func validateMechanics(m *models.StuntMechanics) error {
+	m.Skill = canonicalSkill(m.Skill)
+	m.Conditions = strings.TrimSpace(m.Conditions)
+	if !validStuntAction(m.Action) {
+		return fmt.Errorf("action must be one of %s, %s, %s or %s",
+			models.OvercomeAction, models.CreateAdvantageAction, models.AttackAction, models.DefendAction)
+	}
+	if m.Bonus < 0 || m.Bonus > maxStuntBonus {
+		return fmt.Errorf("bonus must be between 0 and %d", maxStuntBonus)
+	}
+	if utf8.RuneCountInString(m.Conditions) > maxStuntConditions {
+		return fmt.Errorf("conditions must be at most %d characters", maxStuntConditions)
+	}
+	if !validStuntLimit(m.Limit) {
+		return fmt.Errorf("limit must be empty, %s or %s", models.PerSceneStunt, models.PerSessionStunt)
+	}
+	if m.Limit == models.UnlimitedStunt && m.Uses != 0 {
+		return errors.New("uses needs a scene or session limit")
+	}
+	if m.Limit != models.UnlimitedStunt && m.Uses == 0 {
+		m.Uses = 1
+	}
+	if m.Uses < 0 || m.Uses > maxStuntUses {
+		return fmt.Errorf("uses must be between 1 and %d", maxStuntUses)
+	}
+	if m.FatePointCost < 0 || m.FatePointCost > maxStuntFatePoints {
+		return fmt.Errorf("fatePointCost must be between 0 and %d", maxStuntFatePoints)
+	}
+	return nil
+}
+
+// mechanicsFields are the update paths for the mechanics of the stunt at prefix.
+func mechanicsFields(prefix string, m models.StuntMechanics) bson.M {
+	return bson.M{
+		prefix + ".skill":         m.Skill,
+		prefix + ".action":        m.Action,
+		prefix + ".bonus":         m.Bonus,
+		prefix + ".conditions":    m.Conditions,
+		prefix + ".limit":         m.Limit,
+		prefix + ".uses":          m.Uses,
+		prefix + ".fatePointCost": m.FatePointCost,
+	}
+}
+
+// ownedCharacterFilter matches the character if the caller may change it. Only
+// the creator and moderators may; characters without a creator predate accounts
+// and stay editable by anyone allowed through the route.
+func ownedCharacterFilter(ctx context.Context, c *gin.Context, id string) (bson.M, error) {
+	filter := bson.M{"_id": id}
+	canModerate, err := hasPermission(ctx, c, PermUsersModerate)
+	if err != nil {
+		return nil, err
+	}
+	if !canModerate {
+		filter["$or"] = bson.A{
+			bson.M{"creatorId": bson.M{"$exists": false}},
+			bson.M{"creatorId": currentUserID(c)},
+		}
+	}
+	return filter, nil
+}
+
+// remainingUses is how many more times a stunt can be used, or nil when it is
+// not limited.
+func remainingUses(s models.CharacterStunt) *int {
+	if s.Limit == models.UnlimitedStunt {
+		return nil
+	}
+	left := s.Uses - s.Used
+	if left < 0 {
+		left = 0
+	}
+	return &left
+}
+
+// applicableStunts picks the character's stunts that work with the skill and
+// action, usable ones first and then by bonus. An empty action matches stunts
+// for any action, and stunts without an action apply to every action.
+func applicableStunts(character models.Character, skill string, action models.StuntAction) []ApplicableStunt {
+	fatePoints := character.Refresh.Current
+	out := []ApplicableStunt{}
+	for i, s := range character.Stunts {
+		if s.Skill == "" || !strings.EqualFold(s.Skill, skill) {
+			continue
+		}
+		if action != "" && s.Action != "" && s.Action != action {
+			continue
+		}
+		remaining := remainingUses(s)
+		out = append(out, ApplicableStunt{
+			Index:          i,
+			CharacterStunt: s,
+			Remaining:      remaining,
+			Usable:         (remaining == nil || *remaining > 0) && s.FatePointCost <= fatePoints,
+		})
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Usable != out[j].Usable {
+			return out[i].Usable
+		}
+		return out[i].Bonus > out[j].Bonus
+	})
+	return out
+}
+
+// loadCharacter finds a character by a filter, reporting a missing one as
+// mongo.ErrNoDocuments.
+func loadCharacter(ctx context.Context, filter bson.M) (*models.Character, error) {
+	var character models.Character
+	if err := db.Client.Database("main").Collection("characters").FindOne(ctx, filter).Decode(&character); err != nil {
+		return nil, err
+	}
+	return &character, nil
+}
+
+// ApplicableCharacterStunts suggests the stunts of a character that apply to a
+// roll of ?skill= for ?action=.
+func ApplicableCharacterStunts(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	skill := canonicalSkill(c.Query("skill"))
+	if skill == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "skill is required"})
+		return
+	}
+	action := models.StuntAction(c.Query("action"))
+	if !validStuntAction(action) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown action %q", action)})
+		return
+	}
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
+	}
+
+	filter := bson.M{"$and": bson.A{visibilityFilter(c), bson.M{"_id": c.Param("id")}}}
+	character, err := loadCharacter(ctx, filter)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "character not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load character: " + err.Error()})
+		return
+	}
+
+	stunts := applicableStunts(*character, skill, action)
+	locale := requestLocale(c)
+	for i := range stunts {
+		stunts[i].Skill = skillName(stunts[i].Skill, locale)
+	}
+	c.IndentedJSON(http.StatusOK, stunts)
+}
+
+// UseCharacterStunt records one use of a character's stunt, spending its fate
+// point cost. Limited stunts cannot be used once their uses run out.
+func UseCharacterStunt(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil || index < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "index must be a stunt position"})
+		return
+	}
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
+	}
+
+	id := c.Param("id")
+	filter, err := ownedCharacterFilter(ctx, c, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load roles: " + err.Error()})
+		return
+	}
+	character, err := loadCharacter(ctx, filter)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "character not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load character: " + err.Error()})
+		return
+	}
+	if index >= len(character.Stunts) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "index does not refer to a stunt of the character"})
+		return
+	}
+	stunt := character.Stunts[index]
+	if left := remainingUses(stunt); left != nil && *left == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "stunt has no uses left this " + string(stunt.Limit)})
+		return
+	}
+	if stunt.FatePointCost > character.Refresh.Current {
+		c.JSON(http.StatusConflict, gin.H{"error": "not enough fate points"})
+		return
+	}
+
+	// The checks above are repeated in the filter so two concurrent uses cannot
+	// both take the last one.
+	field := "stunts." + strconv.Itoa(index)
+	filter[field+".name"] = stunt.Name
+	if stunt.Limit != models.UnlimitedStunt {
+		filter[field+".used"] = bson.M{"$not": bson.M{"$gte": stunt.Uses}}
+	}
+	if stunt.FatePointCost > 0 {
+		filter["refresh.current"] = bson.M{"$gte": stunt.FatePointCost}
+	}
+	update := bson.M{
+		"$inc": bson.M{field + ".used": 1, "refresh.current": -stunt.FatePointCost},
+		"$set": bson.M{"updatedAt": time.Now()},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var updated models.Character
+	err = db.Client.Database("main").Collection("characters").FindOneAndUpdate(ctx, filter, update, opts).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusConflict, gin.H{"error": "character changed while using the stunt; try again"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to use stunt: " + err.Error()})
+		return
+	}
+
+	used := updated.Stunts[index]
+	c.JSON(http.StatusOK, gin.H{
+		"index":      index,
+		"used":       used.Used,
+		"remaining":  remainingUses(used),
+		"fatePoints": updated.Refresh.Current,
+	})
+}
+
+// ResetStuntUses clears a character's stunt counters at the end of a scene or
+// session.
+func ResetStuntUses(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var req ResetStuntUsesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limits := bson.A{nil, models.UnlimitedStunt, models.PerSceneStunt}
+	switch req.Scope {
+	case models.PerSceneStunt:
+	case models.PerSessionStunt:
+		limits = append(limits, models.PerSessionStunt)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope must be scene or session"})
+		return
+	}
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
+	}
+
+	update := bson.M{
+		"$set": bson.M{"stunts.$[s].used": 0, "updatedAt": time.Now()},
+	}
+	opts := options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{
+		bson.M{"s.limit": bson.M{"$in": limits}},
+	}})
+	filter, err := ownedCharacterFilter(ctx, c, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load roles: " + err.Error()})
+		return
+	}
+	result, err := db.Client.Database("main").Collection("characters").UpdateOne(ctx, filter, update, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset stunts: " + err.Error()})
+		return
+	}
+	if result.MatchedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "character not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "stunt uses reset successfully"})
+}
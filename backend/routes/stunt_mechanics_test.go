@@ -0,0 +1,97 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"FATE-Vault/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateMechanics(t *testing.T) {
+	m := models.StuntMechanics{Skill: " Драка ", Action: models.AttackAction, Bonus: 2, Limit: models.PerSessionStunt}
+	require.NoError(t, validateMechanics(&m))
+	assert.Equal(t, "fight", m.Skill)
+	assert.Equal(t, 1, m.Uses)
+
+	cases := map[string]models.StuntMechanics{
+		"unknown action":      {Action: "dodge"},
+		"negative bonus":      {Bonus: -1},
+		"huge bonus":          {Bonus: maxStuntBonus + 1},
+		"unknown limit":       {Limit: "campaign"},
+		"uses without limit":  {Uses: 2},
+		"too many uses":       {Limit: models.PerSceneStunt, Uses: maxStuntUses + 1},
+		"expensive":           {FatePointCost: maxStuntFatePoints + 1},
+		"negative fate point": {FatePointCost: -1},
+	}
+	for name, m := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Error(t, validateMechanics(&m))
+		})
+	}
+}
+
+func TestApplicableStunts(t *testing.T) {
+	stunt := func(name, skill string, action models.StuntAction, bonus int) models.CharacterStunt {
+		return models.CharacterStunt{Name: name, StuntMechanics: models.StuntMechanics{Skill: skill, Action: action, Bonus: bonus}}
+	}
+	spent := stunt("Последний шанс", "Драка", models.AttackAction, 4)
+	spent.Limit, spent.Uses, spent.Used = models.PerSceneStunt, 1, 1
+	costly := stunt("Ярость", "Драка", "", 3)
+	costly.FatePointCost = 2
+
+	character := models.Character{
+		Refresh: models.Refresh{Current: 1, Max: 3},
+		Stunts: []models.CharacterStunt{
+			stunt("Увечье", "драка", models.AttackAction, 2),
+			stunt("Блок", "Драка", models.DefendAction, 2),
+			stunt("Без механики", "", "", 0),
+			spent,
+			costly,
+			stunt("Удар исподтишка", "Драка", models.AttackAction, 1),
+		},
+	}
+
+	got := applicableStunts(character, "Драка", models.AttackAction)
+
+	var names []string
+	for _, s := range got {
+		names = append(names, s.Name)
+	}
+	assert.Equal(t, []string{"Увечье", "Удар исподтишка", "Последний шанс", "Ярость"}, names)
+	assert.Equal(t, 0, got[0].Index)
+	assert.Nil(t, got[0].Remaining)
+	require.NotNil(t, got[2].Remaining)
+	assert.Equal(t, 0, *got[2].Remaining)
+	assert.False(t, got[3].Usable)
+}
+
+func TestStuntUseEndpoints_Validation(t *testing.T) {
+	router := setupRouter()
+	router.GET("/characters/:id/stunts/applicable", ApplicableCharacterStunts)
+	router.POST("/characters/:id/stunts/use/:index", UseCharacterStunt)
+	router.POST("/characters/:id/stunts/reset", ResetStuntUses)
+
+	cases := map[string]struct {
+		method, url string
+		body        interface{}
+		want        int
+	}{
+		"applicable without skill": {http.MethodGet, "/characters/c1/stunts/applicable", nil, http.StatusBadRequest},
+		"applicable bad action":    {http.MethodGet, "/characters/c1/stunts/applicable?skill=x&action=dodge", nil, http.StatusBadRequest},
+		"applicable valid":         {http.MethodGet, "/characters/c1/stunts/applicable?skill=x&action=attack", nil, http.StatusInternalServerError},
+		"use bad index":            {http.MethodPost, "/characters/c1/stunts/use/first", nil, http.StatusBadRequest},
+		"use valid":                {http.MethodPost, "/characters/c1/stunts/use/0", nil, http.StatusInternalServerError},
+		"reset bad scope":          {http.MethodPost, "/characters/c1/stunts/reset", gin.H{"scope": "campaign"}, http.StatusBadRequest},
+		"reset valid":              {http.MethodPost, "/characters/c1/stunts/reset", gin.H{"scope": "scene"}, http.StatusInternalServerError},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := serve(router, tc.method, tc.url, tc.body)
+			assert.Equal(t, tc.want, w.Code)
+		})
+	}
+}
@@ -3,6 +3,7 @@ package routes
 import (
 	"context"
 	"net/http"
+	"strconv"
 	"time"
 
 	"FATE-Vault/backend/db"
@@ -22,9 +23,16 @@ func CreateStunt(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := validateStunt(&stunt); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Always assign a new UUID for the stunt ID
 	stunt.ID = uuid.NewString()
+	now := time.Now()
+	stunt.CreatedAt = now
+	stunt.UpdatedAt = now
 
 	if db.Client == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
@@ -38,6 +46,7 @@ func CreateStunt(c *gin.Context) {
 		return
 	}
 
+	stunt.Skill = skillName(stunt.Skill, requestLocale(c))
 	c.JSON(http.StatusCreated, stunt)
 }
 
@@ -56,12 +65,20 @@ func UpdateStunt(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := validateStunt(&stunt); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	if db.Client == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
 		return
 	}
 
+	// Keep the stored createdAt; a zero one is left out of the update.
+	stunt.CreatedAt = time.Time{}
+	stunt.UpdatedAt = time.Now()
+
 	coll := db.Client.Database("main").Collection("stunts")
 	filter := bson.M{"_id": id}
 	update := bson.M{"$set": stunt}
@@ -76,6 +93,19 @@ func UpdateStunt(c *gin.Context) {
 		return
 	}
 
+	// With ?propagate=true the new text is copied to the character sheets that
+	// link to the stunt, except where a sheet overrides it.
+	if c.Query("propagate") == "true" {
+		stunt.ID = id
+		n, err := propagateStunt(ctx, stunt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update characters: " + err.Error()})
+			return
+		}
+		c.Header("X-Propagated-Characters", strconv.FormatInt(n, 10))
+	}
+
+	stunt.Skill = skillName(stunt.Skill, requestLocale(c))
 	c.JSON(http.StatusOK, stunt)
 }
 
@@ -108,40 +138,19 @@ func DeleteStunt(c *gin.Context) {
 		return
 	}
 
+	if err := unlinkStunt(ctx, id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update characters: " + err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "stunt deleted successfully"})
 }
 
 func ListStunts(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	if db.Client == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
-		return
-	}
-
-	coll := db.Client.Database("main").Collection("stunts")
-	cur, err := coll.Find(ctx, bson.D{})
+	filter, err := stuntListFilter(c)
 	if err != nil {
-		c.String(http.StatusInternalServerError, "find error: %v", err)
-		return
-	}
-	defer cur.Close(ctx)
-
-	var results []models.Stunt
-	for cur.Next(ctx) {
-		var stunt models.Stunt
-		if err := cur.Decode(&stunt); err != nil {
-			c.String(http.StatusInternalServerError, "decode error: %v", err)
-			return
-		}
-
-		results = append(results, stunt)
-	}
-	if err := cur.Err(); err != nil {
-		c.String(http.StatusInternalServerError, "cursor error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	c.IndentedJSON(http.StatusOK, results)
+	respondWithTaggedList(c, "stunts", filter, "name", localizeStuntDocument(requestLocale(c)))
 }
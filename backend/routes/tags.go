@@ -0,0 +1,364 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"FATE-Vault/backend/db"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	maxTagLength      = 50
+	maxTagsPerItem    = 20
+	maxTagSuggestions = 50
+)
+
+// taggedCollections maps the tag types accepted by the API to the collections
+// whose documents carry tags.
+var taggedCollections = map[string]string{
+	"character": "characters",
+	"stunt":     "stunts",
+	"game":      "games",
+}
+
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+type RenameTagRequest struct {
+	From string `json:"from" binding:"required"`
+	To   string `json:"to" binding:"required"`
+}
+
+// normalizeTag lowercases a tag and tidies its spacing, so "Campaign : Zandalar"
+// and "campaign:zandalar" are the same tag. A colon separates an optional
+// namespace from the name.
+func normalizeTag(raw string) (string, error) {
+	tag := strings.ToLower(strings.Join(strings.Fields(raw), " "))
+	if ns, name, ok := strings.Cut(tag, ":"); ok {
+		ns, name = strings.TrimSpace(ns), strings.TrimSpace(name)
+		if ns == "" || name == "" {
+			return "", fmt.Errorf("tag %q needs text on both sides of the colon", raw)
+		}
+		tag = ns + ":" + name
+	}
+	if tag == "" {
+		return "", errors.New("tags must not be empty")
+	}
+	if utf8.RuneCountInString(tag) > maxTagLength {
+		return "", fmt.Errorf("tag %q is longer than %d characters", raw, maxTagLength)
+	}
+	for _, r := range tag {
+		if unicode.IsControl(r) || r == ',' {
+			return "", fmt.Errorf("tag %q contains an invalid character", raw)
+		}
+	}
+	return tag, nil
+}
+
+// normalizeTagPrefix normalizes what has been typed of a tag so far the way
+// normalizeTag does, but lets the name after the colon be missing: "Campaign :"
+// is the prefix "campaign:", which suggests every tag in that namespace.
+func normalizeTagPrefix(raw string) (string, error) {
+	prefix := strings.ToLower(strings.Join(strings.Fields(raw), " "))
+	if ns, name, ok := strings.Cut(prefix, ":"); ok {
+		ns = strings.TrimSpace(ns)
+		if ns == "" {
+			return "", fmt.Errorf("tag prefix %q needs a namespace before the colon", raw)
+		}
+		prefix = ns + ":" + strings.TrimSpace(name)
+	}
+	if utf8.RuneCountInString(prefix) > maxTagLength {
+		return "", fmt.Errorf("tag prefix %q is longer than %d characters", raw, maxTagLength)
+	}
+	for _, r := range prefix {
+		if unicode.IsControl(r) || r == ',' {
+			return "", fmt.Errorf("tag prefix %q contains an invalid character", raw)
+		}
+	}
+	return prefix, nil
+}
+
+// normalizeTags normalizes, deduplicates and sorts the tags of one document.
+func normalizeTags(raw []string) ([]string, error) {
+	seen := make(map[string]bool, len(raw))
+	tags := []string{}
+	for _, r := range raw {
+		tag, err := normalizeTag(r)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > maxTagsPerItem {
+		return nil, fmt.Errorf("at most %d tags are allowed", maxTagsPerItem)
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+// tagListFilter narrows a list endpoint to documents carrying every ?tag= given.
+// It returns nil when the request has no tag parameter.
+func tagListFilter(c *gin.Context) (bson.M, error) {
+	raw := c.QueryArray("tag")
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	tags, err := normalizeTags(raw)
+	if err != nil {
+		return nil, err
+	}
+	return bson.M{"tags": bson.M{"$all": tags}}, nil
+}
+
+// respondWithTaggedList is respondWithList with support for ?tag= filters.
+func respondWithTaggedList(c *gin.Context, collection string, filter bson.M, defaultSort string, mapItem func(bson.M)) {
+	tags, err := tagListFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if tags != nil {
+		filter = bson.M{"$and": bson.A{filter, tags}}
+	}
+	respondWithList(c, collection, filter, defaultSort, mapItem)
+}
+
+// filterTag is the character filter for one tag.
+func filterTag(_ context.Context, value string) (bson.M, error) {
+	tag, err := normalizeTag(value)
+	if err != nil {
+		return nil, err
+	}
+	return bson.M{"tags": tag}, nil
+}
+
+var (
+	tagIndexesMu    sync.Mutex
+	tagIndexesReady bool
+)
+
+// ensureTagIndexes indexes the tags of every tagged collection once per process.
+// Failures are retried on the next call.
+func ensureTagIndexes(ctx context.Context) error {
+	tagIndexesMu.Lock()
+	defer tagIndexesMu.Unlock()
+	if tagIndexesReady {
+		return nil
+	}
+
+	for _, collection := range taggedCollections {
+		_, err := db.Client.Database("main").Collection(collection).Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "tags", Value: 1}}})
+		if err != nil {
+			return err
+		}
+	}
+
+	tagIndexesReady = true
+	return nil
+}
+
+// tagTypes parses ?type=character,stunt; every tagged type by default.
+func tagTypes(raw string) ([]string, error) {
+	if raw == "" {
+		types := make([]string, 0, len(taggedCollections))
+		for t := range taggedCollections {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+		return types, nil
+	}
+	var types []string
+	for _, t := range strings.Split(raw, ",") {
+		t = strings.TrimSpace(t)
+		if _, ok := taggedCollections[t]; !ok {
+			return nil, fmt.Errorf("unknown tag type %q", t)
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+// countTags counts how many documents in the collection carry each tag that
+// starts with prefix.
+func countTags(ctx context.Context, collection string, filter bson.M, prefix string) (map[string]int, error) {
+	match := bson.M{}
+	if prefix != "" {
+		match = bson.M{"tags": bson.M{"$regex": "^" + regexp.QuoteMeta(prefix)}}
+	}
+	if filter != nil {
+		match = bson.M{"$and": bson.A{filter, match}}
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$unwind", Value: "$tags"}},
+		{{Key: "$match", Value: bson.M{"tags": bson.M{"$regex": "^" + regexp.QuoteMeta(prefix)}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$tags", "count": bson.M{"$sum": 1}}}},
+	}
+	cur, err := db.Client.Database("main").Collection(collection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		Tag   string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+	if err := cur.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.Tag] = row.Count
+	}
+	return counts, nil
+}
+
+// rankTags orders tags by how often they are used, then alphabetically.
+func rankTags(counts map[string]int, limit int) []TagCount {
+	tags := make([]TagCount, 0, len(counts))
+	for tag, n := range counts {
+		tags = append(tags, TagCount{Tag: tag, Count: n})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+	return tags
+}
+
+// ListTags suggests tags for autocomplete: the tags starting with ?q=, most used
+// first, with how many documents carry them. Only characters visible to the
+// caller are counted.
+func ListTags(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	prefix := ""
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		var err error
+		if prefix, err = normalizeTagPrefix(q); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	types, err := tagTypes(c.Query("type"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limit := 20
+	if raw := c.Query("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxTagSuggestions {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxTagSuggestions)})
+			return
+		}
+	}
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
+	}
+	if err := ensureTagIndexes(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create tag indexes: " + err.Error()})
+		return
+	}
+
+	total := make(map[string]int)
+	for _, t := range types {
+		var filter bson.M
+		if t == "character" {
+			filter = visibilityFilter(c)
+		}
+		counts, err := countTags(ctx, taggedCollections[t], filter, prefix)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count tags: " + err.Error()})
+			return
+		}
+		for tag, n := range counts {
+			total[tag] += n
+		}
+	}
+
+	c.IndentedJSON(http.StatusOK, rankTags(total, limit))
+}
+
+// RenameTag renames a tag on every character, stunt and game. Renaming onto a tag
+// that already exists merges the two.
+func RenameTag(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var req RenameTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	from, err := normalizeTag(req.From)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	to, err := normalizeTag(req.To)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if from == to {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to are the same tag"})
+		return
+	}
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
+	}
+	if err := ensureTagIndexes(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create tag indexes: " + err.Error()})
+		return
+	}
+
+	// One pipeline update per document swaps the tag and drops the duplicate a
+	// merge would leave, so no document is ever seen with both or neither.
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"tags": bson.M{"$setUnion": bson.A{
+			bson.M{"$setDifference": bson.A{"$tags", bson.A{from}}},
+			bson.A{to},
+		}},
+		"updatedAt": time.Now(),
+	}}}}
+	updated := gin.H{}
+	for t, collection := range taggedCollections {
+		result, err := db.Client.Database("main").Collection(collection).UpdateMany(ctx, bson.M{"tags": from}, update)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to rename tag: " + err.Error()})
+			return
+		}
+		updated[t] = result.ModifiedCount
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "tag renamed successfully", "from": from, "to": to, "updated": updated})
+}
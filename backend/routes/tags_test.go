@@ -0,0 +1,116 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	cases := map[string]string{
+		"Villain":             "villain",
+		"  one-shot ":         "one-shot",
+		"Campaign : Zandalar": "campaign:zandalar",
+		"Злодей   второго плана": "злодей второго плана",
+	}
+	for raw, want := range cases {
+		got, err := normalizeTag(raw)
+		require.NoError(t, err, raw)
+		assert.Equal(t, want, got)
+	}
+
+	for _, bad := range []string{"", "   ", ":zandalar", "campaign:", "a,b", "tab\x00", strings.Repeat("я", maxTagLength+1)} {
+		_, err := normalizeTag(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestNormalizeTagPrefix(t *testing.T) {
+	cases := map[string]string{
+		"vil":           "vil",
+		"campaign:":     "campaign:",
+		" Campaign : ":  "campaign:",
+		"Campaign : Za": "campaign:za",
+		"one  shot":     "one shot",
+	}
+	for raw, want := range cases {
+		got, err := normalizeTagPrefix(raw)
+		require.NoError(t, err, raw)
+		assert.Equal(t, want, got)
+	}
+
+	for _, bad := range []string{":zandalar", " : ", "a,b", strings.Repeat("я", maxTagLength+1)} {
+		_, err := normalizeTagPrefix(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	tags, err := normalizeTags([]string{"Villain", "one-shot", "villain ", "campaign:Zandalar"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"campaign:zandalar", "one-shot", "villain"}, tags)
+
+	tags, err = normalizeTags(nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{}, tags)
+
+	many := make([]string, maxTagsPerItem+1)
+	for i := range many {
+		many[i] = strings.Repeat("x", i+1)
+	}
+	_, err = normalizeTags(many)
+	assert.Error(t, err)
+}
+
+func TestRankTags(t *testing.T) {
+	ranked := rankTags(map[string]int{"villain": 3, "ally": 3, "one-shot": 5, "npc": 1}, 3)
+
+	assert.Equal(t, []TagCount{{"one-shot", 5}, {"ally", 3}, {"villain", 3}}, ranked)
+}
+
+func TestBuildCharacterFilter_Tags(t *testing.T) {
+	filter, err := buildCharacterFilter(context.Background(), filterContext(url.Values{"tag": {"Villain", "campaign:Zandalar"}}))
+
+	require.NoError(t, err)
+	assert.Equal(t, bson.M{"$and": bson.A{
+		bson.M{"tags": "villain"},
+		bson.M{"tags": "campaign:zandalar"},
+	}}, filter)
+}
+
+func TestTagEndpoints_Validation(t *testing.T) {
+	router := setupRouter()
+	router.GET("/tags", ListTags)
+	router.POST("/tags/rename", RenameTag)
+	router.GET("/stunts", ListStunts)
+
+	cases := map[string]struct {
+		method, url string
+		body        interface{}
+		want        int
+	}{
+		"unknown type":   {http.MethodGet, "/tags?type=user", nil, http.StatusBadRequest},
+		"bad limit":      {http.MethodGet, "/tags?limit=500", nil, http.StatusBadRequest},
+		"bad prefix":     {http.MethodGet, "/tags?q=" + url.QueryEscape(":x"), nil, http.StatusBadRequest},
+		"namespace only": {http.MethodGet, "/tags?q=" + url.QueryEscape("campaign:"), nil, http.StatusInternalServerError},
+		"valid suggest":  {http.MethodGet, "/tags?q=vil&type=character,stunt", nil, http.StatusInternalServerError},
+		"same tag":       {http.MethodPost, "/tags/rename", gin.H{"from": "Villain", "to": "villain"}, http.StatusBadRequest},
+		"missing to":     {http.MethodPost, "/tags/rename", gin.H{"from": "villain"}, http.StatusBadRequest},
+		"valid rename":   {http.MethodPost, "/tags/rename", gin.H{"from": "villain", "to": "antagonist"}, http.StatusInternalServerError},
+		"bad list tag":   {http.MethodGet, "/stunts?tag=a,b", nil, http.StatusBadRequest},
+		"valid list tag": {http.MethodGet, "/stunts?tag=one-shot", nil, http.StatusInternalServerError},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := serve(router, tc.method, tc.url, tc.body)
+			assert.Equal(t, tc.want, w.Code)
+		})
+	}
+}
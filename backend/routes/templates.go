@@ -1,17 +1,181 @@
 package routes
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 
 	"FATE-Vault/backend/db"
+	"FATE-Vault/backend/models"
+	"FATE-Vault/backend/vocab"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+type TemplateRequest struct {
+	models.Template
+	// Shared makes the template available to everyone. Only users who may write
+	// templates can create shared ones.
+	Shared bool `json:"shared"`
+}
+
+type CharacterFromTemplateRequest struct {
+	Name string `json:"name,omitempty"`
+}
+
+// SeedResult reports what SeedTemplates did with each template.
+type SeedResult struct {
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+}
+
+var errTemplateNotFound = errors.New("template not found")
+
+func templatesCollection() *mongo.Collection {
+	return db.Client.Database("main").Collection("templates")
+}
+
+func templateVersionsCollection() *mongo.Collection {
+	return db.Client.Database("main").Collection("template_versions")
+}
+
+func validEdition(edition models.Edition) bool {
+	switch edition {
+	case models.Core, models.Accelerated, models.Condensed, models.Custom:
+		return true
+	}
+	return false
+}
+
+// validateTemplate tidies a template before it is stored.
+func validateTemplate(t *models.Template) error {
+	t.Title = strings.TrimSpace(t.Title)
+	if t.Title == "" {
+		return errors.New("title is required")
+	}
+	if len([]rune(t.Title)) > 100 {
+		return errors.New("title must be at most 100 characters")
+	}
+	if !validEdition(t.Edition) {
+		return fmt.Errorf("unknown edition %q", t.Edition)
+	}
+	for locale, text := range t.Translations {
+		if vocab.Match(locale) != locale {
+			return fmt.Errorf("translations: unsupported locale %q", locale)
+		}
+		text.Title = strings.TrimSpace(text.Title)
+		if len([]rune(text.Title)) > 100 {
+			return fmt.Errorf("translations: %s title must be at most 100 characters", locale)
+		}
+		t.Translations[locale] = text
+	}
+	tags, err := normalizeTags(t.Tags)
+	if err != nil {
+		return err
+	}
+	t.Tags = tags
+	canonicalizeSheet(&t.Character)
+	return nil
+}
+
+// visibleTemplatesFilter matches the shared templates and the user's own.
+func visibleTemplatesFilter(userID string) bson.M {
+	shared := bson.M{"creatorId": bson.M{"$exists": false}}
+	if userID == "" {
+		return shared
+	}
+	return bson.M{"$or": bson.A{shared, bson.M{"creatorId": userID}}}
+}
+
+// versionFilter matches a template version; templates loaded before versioning
+// have none stored.
+func versionFilter(version int) interface{} {
+	if version <= 1 {
+		return bson.M{"$in": bson.A{nil, 0, 1}}
+	}
+	return version
+}
+
+func currentVersion(t *models.Template) int {
+	if t.Version < 1 {
+		return 1
+	}
+	return t.Version
+}
+
+func loadTemplate(ctx context.Context, userID, id string) (*models.Template, error) {
+	var t models.Template
+	filter := bson.M{"$and": bson.A{visibleTemplatesFilter(userID), bson.M{"_id": id}}}
+	err := templatesCollection().FindOne(ctx, filter).Decode(&t)
+	if err == mongo.ErrNoDocuments {
+		return nil, errTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// canEditTemplate reports whether the caller may change the template: their own,
+// or a shared one if they may write templates.
+func canEditTemplate(ctx context.Context, c *gin.Context, t *models.Template) (bool, error) {
+	if t.CreatorID != "" {
+		return t.CreatorID == currentUserID(c), nil
+	}
+	return hasPermission(ctx, c, PermTemplatesWrite)
+}
+
+// replaceTemplate stores next as the new version of current, keeping current as
+// a TemplateVersion. It fails with errTemplateNotFound if the template changed
+// in the meantime.
+func replaceTemplate(ctx context.Context, current, next *models.Template, replacedBy string) error {
+	now := time.Now()
+	archived := models.TemplateVersion{
+		ID:         uuid.NewString(),
+		TemplateID: current.ID,
+		Version:    currentVersion(current),
+		Template:   *current,
+		ReplacedBy: replacedBy,
+		ReplacedAt: now,
+	}
+	if _, err := templateVersionsCollection().InsertOne(ctx, archived); err != nil {
+		return err
+	}
+
+	next.ID = current.ID
+	next.CreatorID = current.CreatorID
+	next.Key = current.Key
+	next.CreatedAt = current.CreatedAt
+	next.UpdatedAt = now
+	next.Version = archived.Version + 1
+	result, err := templatesCollection().ReplaceOne(ctx, bson.M{"_id": current.ID, "version": versionFilter(current.Version)}, next)
+	if err == nil && result.MatchedCount == 0 {
+		err = errTemplateNotFound
+	}
+	if err != nil {
+		templateVersionsCollection().DeleteOne(ctx, bson.M{"_id": archived.ID})
+		return err
+	}
+	return nil
+}
+
+// GetTemplates returns a page of the shared templates and the caller's own.
 func GetTemplates(c *gin.Context) {
+	respondWithList(c, "templates", visibleTemplatesFilter(optionalUserID(c)), "name", localizeTemplateDocument(requestLocale(c)))
+}
+
+func GetTemplate(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -20,28 +184,403 @@ func GetTemplates(c *gin.Context) {
 		return
 	}
 
-	coll := db.Client.Database("main").Collection("templates")
-	cur, err := coll.Find(ctx, bson.D{})
+	t, err := loadTemplate(ctx, optionalUserID(c), c.Param("id"))
+	if err == errTemplateNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
-		c.String(http.StatusInternalServerError, "find error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load template: " + err.Error()})
+		return
+	}
+
+	localizeTemplate(t, requestLocale(c))
+	c.JSON(http.StatusOK, t)
+}
+
+// bindTemplate validates a create or update request.
+func bindTemplate(ctx context.Context, c *gin.Context) (*TemplateRequest, bool) {
+	var req TemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if err := validateTemplate(&req.Template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return nil, false
+	}
+
+	if err := resolveCharacterStunts(ctx, req.Stunts); err != nil {
+		if _, ok := err.(invalidStuntError); ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load library stunts: " + err.Error()})
+		return nil, false
+	}
+	return &req, true
+}
+
+// CreateTemplate creates a custom template for the caller, or a shared one.
+func CreateTemplate(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, ok := bindTemplate(ctx, c)
+	if !ok {
+		return
+	}
+
+	t := req.Template
+	t.CreatorID = currentUserID(c)
+	if req.Shared {
+		allowed, err := hasPermission(ctx, c, PermTemplatesWrite)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check permissions: " + err.Error()})
+			return
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": "insufficient permissions to share templates"})
+			return
+		}
+		t.CreatorID = ""
+	}
+
+	// Always assign a new UUID for the template ID
+	now := time.Now()
+	t.ID = uuid.NewString()
+	t.Key = ""
+	t.Version = 1
+	t.IsPublished = false
+	t.TemplateID, t.TemplateVersion = "", 0
+	t.CreatedAt = now
+	t.UpdatedAt = now
+	if _, err := templatesCollection().InsertOne(ctx, t); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create template: " + err.Error()})
+		return
+	}
+
+	localizeTemplate(&t, requestLocale(c))
+	c.JSON(http.StatusCreated, t)
+}
+
+// UpdateTemplate saves a new version of a template. The previous version stays
+// available from ListTemplateVersions.
+func UpdateTemplate(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id parameter is required"})
+		return
+	}
+
+	req, ok := bindTemplate(ctx, c)
+	if !ok {
+		return
+	}
+
+	current, err := loadTemplate(ctx, currentUserID(c), id)
+	if err == errTemplateNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load template: " + err.Error()})
+		return
+	}
+	allowed, err := canEditTemplate(ctx, c, current)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check permissions: " + err.Error()})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
+		return
+	}
+
+	next := req.Template
+	next.IsPublished = false
+	next.TemplateID, next.TemplateVersion = "", 0
+	err = replaceTemplate(ctx, current, &next, currentUserID(c))
+	if err == errTemplateNotFound {
+		c.JSON(http.StatusConflict, gin.H{"error": "template was changed by someone else; reload it and try again"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update template: " + err.Error()})
+		return
+	}
+
+	localizeTemplate(&next, requestLocale(c))
+	c.JSON(http.StatusOK, next)
+}
+
+// DeleteTemplate deletes a template and its earlier versions. Characters created
+// from it are not affected.
+func DeleteTemplate(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id parameter is required"})
+		return
+	}
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
+	}
+
+	t, err := loadTemplate(ctx, currentUserID(c), id)
+	if err == errTemplateNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load template: " + err.Error()})
+		return
+	}
+	allowed, err := canEditTemplate(ctx, c, t)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check permissions: " + err.Error()})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
+		return
+	}
+
+	if _, err := templatesCollection().DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete template: " + err.Error()})
+		return
+	}
+	if _, err := templateVersionsCollection().DeleteMany(ctx, bson.M{"templateId": id}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete template versions: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "template deleted successfully"})
+}
+
+// ListTemplateVersions returns the earlier versions of a template, newest first.
+func ListTemplateVersions(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
+	}
+
+	t, err := loadTemplate(ctx, optionalUserID(c), c.Param("id"))
+	if err == errTemplateNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load template: " + err.Error()})
+		return
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "version", Value: -1}})
+	cur, err := templateVersionsCollection().Find(ctx, bson.M{"templateId": t.ID}, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list template versions: " + err.Error()})
 		return
 	}
 	defer cur.Close(ctx)
 
-	var results []map[string]interface{}
-	for cur.Next(ctx) {
-		var doc bson.M
-		if err := cur.Decode(&doc); err != nil {
-			c.String(http.StatusInternalServerError, "decode error: %v", err)
+	versions := []models.TemplateVersion{}
+	if err := cur.All(ctx, &versions); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to decode template versions: " + err.Error()})
+		return
+	}
+	locale := requestLocale(c)
+	for i := range versions {
+		localizeTemplate(&versions[i].Template, locale)
+	}
+
+	c.IndentedJSON(http.StatusOK, versions)
+}
+
+// characterFromTemplate builds a new, unsaved character from a template.
+func characterFromTemplate(t *models.Template, name, creatorID string, now time.Time) models.Character {
+	character := t.Character
+	character.ID = uuid.NewString()
+	if name = strings.TrimSpace(name); name != "" {
+		character.Name = name
+	}
+	character.CreatorID = creatorID
+	character.IsPublished = false
+	character.TemplateID = t.ID
+	character.TemplateVersion = currentVersion(t)
+	character.CreatedAt = now
+	character.UpdatedAt = now
+	for i := range character.Stunts {
+		character.Stunts[i].Used = 0
+	}
+	// Templates loaded before game terms were stored as identifiers.
+	canonicalizeSheet(&character)
+	return character
+}
+
+// CreateCharacterFromTemplate creates a character from a template on the server,
+// recording which template version it came from. The template's title and
+// description are taken in the request locale.
+func CreateCharacterFromTemplate(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var req CharacterFromTemplateRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+	}
 
-		results = append(results, doc)
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
 	}
-	if err := cur.Err(); err != nil {
-		c.String(http.StatusInternalServerError, "cursor error: %v", err)
+
+	userID := optionalUserID(c)
+	t, err := loadTemplate(ctx, userID, c.Param("id"))
+	if err == errTemplateNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load template: " + err.Error()})
 		return
 	}
 
-	c.IndentedJSON(http.StatusOK, results)
+	locale := requestLocale(c)
+	applyTemplateText(t, locale)
+	character := characterFromTemplate(t, req.Name, userID, time.Now())
+	if err := resolveCharacterStunts(ctx, character.Stunts); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load library stunts: " + err.Error()})
+		return
+	}
+	if _, err := db.Client.Database("main").Collection("characters").InsertOne(ctx, character); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create character: " + err.Error()})
+		return
+	}
+
+	localizeSheet(&character, locale)
+	c.JSON(http.StatusCreated, character)
+}
+
+// LoadTemplateFile reads templates in the format of extra/templates.json: an
+// array of character sheets, at most one per edition unless they carry a key.
+func LoadTemplateFile(path string) ([]models.Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var templates []models.Template
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return templates, nil
+}
+
+// seedDefaults fills in the key and title of a template from its edition.
+func seedDefaults(t *models.Template) {
+	if t.Key == "" {
+		t.Key = string(t.Edition)
+	}
+	if strings.TrimSpace(t.Title) == "" && t.Edition != "" {
+		t.Title = strings.ToUpper(string(t.Edition[:1])) + string(t.Edition[1:])
+	}
+}
+
+// sameTemplateContent reports whether two templates have the same title and sheet,
+// ignoring identity, ownership and timestamps.
+func sameTemplateContent(a, b models.Template) (bool, error) {
+	strip := func(t models.Template) models.Template {
+		t.ID, t.Key, t.CreatorID, t.Version = "", "", "", 0
+		t.CreatedAt, t.UpdatedAt = time.Time{}, time.Time{}
+		return t
+	}
+	ab, err := bson.Marshal(strip(a))
+	if err != nil {
+		return false, err
+	}
+	bb, err := bson.Marshal(strip(b))
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(ab, bb), nil
+}
+
+// SeedTemplates loads shared templates, matching them to stored ones by key.
+// New templates are created, changed ones get a new version and the rest are
+// left alone, so seeding the same file again changes nothing. Templates
+// imported by hand before templates had keys are replaced.
+func SeedTemplates(ctx context.Context, templates []models.Template) (SeedResult, error) {
+	var result SeedResult
+	seen := make(map[string]bool, len(templates))
+	for _, t := range templates {
+		seedDefaults(&t)
+		if err := validateTemplate(&t); err != nil {
+			return result, fmt.Errorf("template %q: %w", t.Key, err)
+		}
+		if seen[t.Key] {
+			return result, fmt.Errorf("template key %q appears twice", t.Key)
+		}
+		seen[t.Key] = true
+		t.CreatorID = ""
+		t.IsPublished = false
+
+		var current models.Template
+		err := templatesCollection().FindOne(ctx, bson.M{"key": t.Key}).Decode(&current)
+		if err == mongo.ErrNoDocuments {
+			legacy := bson.M{
+				"edition":   t.Edition,
+				"key":       bson.M{"$exists": false},
+				"version":   bson.M{"$exists": false},
+				"creatorId": bson.M{"$exists": false},
+			}
+			if _, err := templatesCollection().DeleteMany(ctx, legacy); err != nil {
+				return result, err
+			}
+
+			now := time.Now()
+			t.ID = t.Key
+			t.Version = 1
+			t.CreatedAt = now
+			t.UpdatedAt = now
+			if _, err := templatesCollection().InsertOne(ctx, t); err != nil {
+				return result, err
+			}
+			result.Created++
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+
+		same, err := sameTemplateContent(current, t)
+		if err != nil {
+			return result, err
+		}
+		if same {
+			result.Unchanged++
+			continue
+		}
+		if err := replaceTemplate(ctx, &current, &t, "seed"); err != nil {
+			return result, fmt.Errorf("template %q: %w", t.Key, err)
+		}
+		result.Updated++
+	}
+	return result, nil
 }
@@ -0,0 +1,118 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"FATE-Vault/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestValidateTemplate(t *testing.T) {
+	tmpl := models.Template{Title: "  Быстрый старт ", Character: models.Character{Edition: models.Accelerated, Tags: []string{"Starter"}}}
+	require.NoError(t, validateTemplate(&tmpl))
+	assert.Equal(t, "Быстрый старт", tmpl.Title)
+	assert.Equal(t, []string{"starter"}, tmpl.Tags)
+
+	assert.Error(t, validateTemplate(&models.Template{Character: models.Character{Edition: models.Core}}))
+	assert.Error(t, validateTemplate(&models.Template{Title: "Шаблон", Character: models.Character{Edition: "fifth"}}))
+}
+
+func TestVisibleTemplatesFilter(t *testing.T) {
+	shared := bson.M{"creatorId": bson.M{"$exists": false}}
+	assert.Equal(t, shared, visibleTemplatesFilter(""))
+	assert.Equal(t, bson.M{"$or": bson.A{shared, bson.M{"creatorId": "u1"}}}, visibleTemplatesFilter("u1"))
+}
+
+func TestLoadTemplateFile_Seed(t *testing.T) {
+	templates, err := LoadTemplateFile("../extra/templates.json")
+	require.NoError(t, err)
+	require.NotEmpty(t, templates)
+
+	keys := map[string]bool{}
+	for _, tmpl := range templates {
+		seedDefaults(&tmpl)
+		require.NoError(t, validateTemplate(&tmpl))
+		assert.False(t, keys[tmpl.Key], "duplicate key %q", tmpl.Key)
+		keys[tmpl.Key] = true
+		assert.NotEmpty(t, tmpl.Title)
+	}
+	assert.True(t, keys["core"])
+}
+
+func TestSameTemplateContent(t *testing.T) {
+	stored := models.Template{
+		Character: models.Character{ID: "core", Edition: models.Core, CreatedAt: time.Now()},
+		Title:     "Core",
+		Key:       "core",
+		Version:   3,
+	}
+	seeded := models.Template{Character: models.Character{Edition: models.Core}, Title: "Core"}
+
+	same, err := sameTemplateContent(stored, seeded)
+	require.NoError(t, err)
+	assert.True(t, same)
+
+	seeded.Aspects = []models.Aspect{{Type: "concept", Value: "Герой"}}
+	same, err = sameTemplateContent(stored, seeded)
+	require.NoError(t, err)
+	assert.False(t, same)
+}
+
+func TestCharacterFromTemplate(t *testing.T) {
+	tmpl := models.Template{
+		Character: models.Character{
+			ID:          "core",
+			Name:        "Шаблон",
+			Edition:     models.Core,
+			IsPublished: true,
+			Stunts:      []models.CharacterStunt{{Name: "Увечье", Used: 2}},
+		},
+		Title: "Core",
+	}
+	now := time.Now()
+
+	character := characterFromTemplate(&tmpl, " Мира ", "u1", now)
+
+	assert.NotEqual(t, "core", character.ID)
+	assert.Equal(t, "Мира", character.Name)
+	assert.Equal(t, "u1", character.CreatorID)
+	assert.False(t, character.IsPublished)
+	assert.Equal(t, "core", character.TemplateID)
+	assert.Equal(t, 1, character.TemplateVersion)
+	assert.Equal(t, now, character.CreatedAt)
+	assert.Equal(t, 0, character.Stunts[0].Used)
+}
+
+func TestTemplateEndpoints_Validation(t *testing.T) {
+	router := setupRouter()
+	router.POST("/templates/create", CreateTemplate)
+	router.POST("/templates/update/:id", UpdateTemplate)
+	router.GET("/templates/:id", GetTemplate)
+	router.POST("/characters/from-template/:id", CreateCharacterFromTemplate)
+
+	cases := map[string]struct {
+		method, url string
+		body        interface{}
+		want        int
+	}{
+		"create without title":    {http.MethodPost, "/templates/create", gin.H{"edition": "core"}, http.StatusBadRequest},
+		"create bad edition":      {http.MethodPost, "/templates/create", gin.H{"title": "Шаблон", "edition": "fifth"}, http.StatusBadRequest},
+		"create valid":            {http.MethodPost, "/templates/create", gin.H{"title": "Шаблон", "edition": "core"}, http.StatusInternalServerError},
+		"update without title":    {http.MethodPost, "/templates/update/t1", gin.H{"edition": "core"}, http.StatusBadRequest},
+		"get":                     {http.MethodGet, "/templates/t1", nil, http.StatusInternalServerError},
+		"from template":           {http.MethodPost, "/characters/from-template/core", nil, http.StatusInternalServerError},
+		"from template with name": {http.MethodPost, "/characters/from-template/core", gin.H{"name": "Мира"}, http.StatusInternalServerError},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := serve(router, tc.method, tc.url, tc.body)
+			assert.Equal(t, tc.want, w.Code)
+		})
+	}
+}
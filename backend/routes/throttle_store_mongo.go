@@ -0,0 +1,130 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"FATE-Vault/backend/db"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoThrottleStore keeps one document per key in "login_throttle". expiresAt
+// covers both the failure window and any block, and a TTL index removes the
+// document once both are over.
+type MongoThrottleStore struct{}
+
+type throttleDocument struct {
+	Key          string    `bson:"_id"`
+	Failures     int64     `bson:"failures"`
+	BlockedUntil time.Time `bson:"blockedUntil"`
+	ExpiresAt    time.Time `bson:"expiresAt"`
+}
+
+func NewMongoThrottleStore(ctx context.Context) (*MongoThrottleStore, error) {
+	if db.Client == nil {
+		return nil, errors.New("mongo login throttle store requires a database connection")
+	}
+
+	s := &MongoThrottleStore{}
+	_, err := s.collection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *MongoThrottleStore) collection() *mongo.Collection {
+	return db.Client.Database("main").Collection("login_throttle")
+}
+
+func (s *MongoThrottleStore) incr(ctx context.Context, key string, window time.Duration) (int64, error) {
+	now := time.Now()
+	// The TTL monitor runs about once a minute, so drop a stale count ourselves.
+	_, err := s.collection().UpdateOne(ctx,
+		bson.M{"_id": key, "expiresAt": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"failures": 0}},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	var doc throttleDocument
+	err = s.collection().FindOneAndUpdate(ctx,
+		bson.M{"_id": key},
+		bson.M{
+			"$inc": bson.M{"failures": 1},
+			"$max": bson.M{"expiresAt": now.Add(window)},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&doc)
+	if err != nil {
+		return 0, err
+	}
+	return doc.Failures, nil
+}
+
+func (s *MongoThrottleStore) decr(ctx context.Context, key string) error {
+	_, err := s.collection().UpdateOne(ctx,
+		bson.M{"_id": key, "failures": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"failures": -1}},
+	)
+	return err
+}
+
+func (s *MongoThrottleStore) hold(ctx context.Context, key string, d time.Duration) (bool, error) {
+	now := time.Now()
+	until := now.Add(d)
+	// A blocked document does not match, so the upsert collides with it on _id.
+	_, err := s.collection().UpdateOne(ctx,
+		bson.M{"_id": key, "blockedUntil": bson.M{"$not": bson.M{"$gt": now}}},
+		bson.M{
+			"$set": bson.M{"blockedUntil": until},
+			"$max": bson.M{"expiresAt": until},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *MongoThrottleStore) block(ctx context.Context, key string, d time.Duration) error {
+	until := time.Now().Add(d)
+	_, err := s.collection().UpdateOne(ctx,
+		bson.M{"_id": key},
+		bson.M{
+			"$set": bson.M{"blockedUntil": until},
+			"$max": bson.M{"expiresAt": until},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (s *MongoThrottleStore) blockedFor(ctx context.Context, key string) (time.Duration, error) {
+	var doc throttleDocument
+	err := s.collection().FindOne(ctx, bson.M{"_id": key}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if remaining := time.Until(doc.BlockedUntil); remaining > 0 {
+		return remaining, nil
+	}
+	return 0, nil
+}
+
+func (s *MongoThrottleStore) reset(ctx context.Context, key string) error {
+	_, err := s.collection().DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
@@ -0,0 +1,73 @@
+package routes
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"FATE-Vault/backend/resp"
+)
+
+// RedisThrottleStore keeps failure counters and blocks as keys with TTLs, so
+// Redis forgets them on its own.
+type RedisThrottleStore struct {
+	client *resp.Client
+	prefix string
+}
+
+func NewRedisThrottleStore(client *resp.Client, prefix string) *RedisThrottleStore {
+	return &RedisThrottleStore{client: client, prefix: prefix}
+}
+
+func (s *RedisThrottleStore) failuresKey(key string) string {
+	return s.prefix + "login:failures:" + key
+}
+
+func (s *RedisThrottleStore) blockKey(key string) string {
+	return s.prefix + "login:block:" + key
+}
+
+func (s *RedisThrottleStore) incr(ctx context.Context, key string, window time.Duration) (int64, error) {
+	failures, err := resp.Int64(s.client.Do(ctx, "INCR", s.failuresKey(key)))
+	if err != nil {
+		return 0, err
+	}
+	_, err = s.client.Do(ctx, "PEXPIRE", s.failuresKey(key), strconv.FormatInt(window.Milliseconds(), 10))
+	return failures, err
+}
+
+func (s *RedisThrottleStore) decr(ctx context.Context, key string) error {
+	failures, err := resp.Int64(s.client.Do(ctx, "INCRBY", s.failuresKey(key), "-1"))
+	if err != nil || failures > 0 {
+		return err
+	}
+	_, err = s.client.Do(ctx, "DEL", s.failuresKey(key))
+	return err
+}
+
+func (s *RedisThrottleStore) hold(ctx context.Context, key string, d time.Duration) (bool, error) {
+	reply, err := s.client.Do(ctx, "SET", s.blockKey(key), "1", "PX", strconv.FormatInt(d.Milliseconds(), 10), "NX")
+	return err == nil && reply != nil, err
+}
+
+func (s *RedisThrottleStore) block(ctx context.Context, key string, d time.Duration) error {
+	if d.Milliseconds() <= 0 {
+		_, err := s.client.Do(ctx, "DEL", s.blockKey(key))
+		return err
+	}
+	_, err := s.client.Do(ctx, "SET", s.blockKey(key), "1", "PX", strconv.FormatInt(d.Milliseconds(), 10))
+	return err
+}
+
+func (s *RedisThrottleStore) blockedFor(ctx context.Context, key string) (time.Duration, error) {
+	ms, err := resp.Int64(s.client.Do(ctx, "PTTL", s.blockKey(key)))
+	if err != nil || ms <= 0 {
+		return 0, err
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+func (s *RedisThrottleStore) reset(ctx context.Context, key string) error {
+	_, err := s.client.Do(ctx, "DEL", s.failuresKey(key), s.blockKey(key))
+	return err
+}
@@ -0,0 +1,489 @@
+package routes
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"FATE-Vault/backend/db"
+	"FATE-Vault/backend/models"
+	"FATE-Vault/backend/totp"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	recoveryCodeCount = 10
+	// maxChallengeAttempts bounds how many codes can be tried against one login challenge.
+	maxChallengeAttempts = 5
+	// totpSkew accepts codes from one step before or after now, to allow for clock drift.
+	totpSkew = 1
+)
+
+type TwoFactorCodeRequest struct {
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recoveryCode,omitempty"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password     string `json:"password" binding:"required"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recoveryCode,omitempty"`
+}
+
+type CompleteLoginRequest struct {
+	// Challenge comes from the password login. After an OIDC login it is in the
+	// login_challenge cookie instead and can be left out.
+	Challenge    string `json:"challenge,omitempty"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recoveryCode,omitempty"`
+}
+
+// loginChallenge is issued when a password-valid login still needs a second factor.
+type loginChallenge struct {
+	TokenHash string    `bson:"_id"`
+	UserID    string    `bson:"userId"`
+	Attempts  int       `bson:"attempts"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+func totpIssuer() string {
+	if v := os.Getenv("TOTP_ISSUER"); v != "" {
+		return v
+	}
+	return "FATE Vault"
+}
+
+func loginChallengeTTL() time.Duration {
+	return envDuration("LOGIN_CHALLENGE_TTL", 5*time.Minute)
+}
+
+func usersCollection() *mongo.Collection {
+	return db.Client.Database("main").Collection("users")
+}
+
+// generateRecoveryCodes returns codes formatted as xxxxx-xxxxx for display and their hashes for storage.
+func generateRecoveryCodes() ([]string, []string, error) {
+	// 32 symbols without look-alikes (no i, l, o, 0), so each byte maps without bias.
+	const alphabet = "abcdefghjkmnpqrstuvwxyz123456789"
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	buf := make([]byte, 10)
+	for i := range codes {
+		if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+			return nil, nil, err
+		}
+		var b strings.Builder
+		for j, v := range buf {
+			if j == 5 {
+				b.WriteByte('-')
+			}
+			b.WriteByte(alphabet[v&31])
+		}
+		codes[i] = b.String()
+		hashes[i] = hashSecretToken(normalizeRecoveryCode(codes[i]))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// verifySecondFactor checks a TOTP code or consumes a recovery code. TOTP steps are
+// recorded so an observed code cannot be replayed.
+func verifySecondFactor(ctx context.Context, user *models.Users, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		step, ok := totp.Validate(user.TOTPSecret, code, time.Now(), totpSkew)
+		if !ok {
+			return false, nil
+		}
+		result, err := usersCollection().UpdateOne(ctx,
+			bson.M{"_id": user.ID, "$or": bson.A{
+				bson.M{"totpLastStep": bson.M{"$lt": step}},
+				bson.M{"totpLastStep": bson.M{"$exists": false}},
+			}},
+			bson.M{"$set": bson.M{"totpLastStep": step}},
+		)
+		if err != nil {
+			return false, err
+		}
+		return result.ModifiedCount == 1, nil
+	}
+
+	if recoveryCode != "" {
+		hash := hashSecretToken(normalizeRecoveryCode(recoveryCode))
+		result, err := usersCollection().UpdateOne(ctx,
+			bson.M{"_id": user.ID, "recoveryCodes": hash},
+			bson.M{"$pull": bson.M{"recoveryCodes": hash}},
+		)
+		if err != nil {
+			return false, err
+		}
+		return result.ModifiedCount == 1, nil
+	}
+
+	return false, nil
+}
+
+// loadCurrentUser reads the caller's user document fresh from the database,
+// including the 2FA fields.
+func loadCurrentUser(ctx context.Context, c *gin.Context) (*models.Users, bool) {
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return nil, false
+	}
+	userID := currentUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return nil, false
+	}
+	var user models.Users
+	if err := usersCollection().FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to find user: " + err.Error()})
+		return nil, false
+	}
+	return &user, true
+}
+
+// SetupTwoFactor starts TOTP enrollment. The secret stays pending until a code
+// generated from it is confirmed.
+func SetupTwoFactor(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, ok := loadCurrentUser(ctx, c)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate secret: " + err.Error()})
+		return
+	}
+	_, err = usersCollection().UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"pendingTotpSecret": secret}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store secret: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":     secret,
+		"otpauthUri": totp.URI(totpIssuer(), user.Username, secret),
+	})
+}
+
+// ConfirmTwoFactor enables TOTP once the user proves their app produces valid
+// codes, and returns a fresh set of recovery codes.
+func ConfirmTwoFactor(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+		return
+	}
+
+	user, ok := loadCurrentUser(ctx, c)
+	if !ok {
+		return
+	}
+	if user.PendingTOTPSecret == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "no two-factor setup in progress"})
+		return
+	}
+	step, valid := totp.Validate(user.PendingTOTPSecret, req.Code, time.Now(), totpSkew)
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid code"})
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate recovery codes: " + err.Error()})
+		return
+	}
+	_, err = usersCollection().UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{
+		"$set": bson.M{
+			"totpEnabled":   true,
+			"totpSecret":    user.PendingTOTPSecret,
+			"totpLastStep":  step,
+			"recoveryCodes": hashes,
+		},
+		"$unset": bson.M{"pendingTotpSecret": ""},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enable two-factor authentication: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+// DisableTwoFactor turns TOTP off. It requires the password and a current code or
+// recovery code, so a hijacked session alone cannot remove the second factor.
+func DisableTwoFactor(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var req DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := loadCurrentUser(ctx, c)
+	if !ok {
+		return
+	}
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "two-factor authentication is not enabled"})
+		return
+	}
+	if !user.CheckPassword(req.Password) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "password is incorrect"})
+		return
+	}
+	valid, err := verifySecondFactor(ctx, user, req.Code, req.RecoveryCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify code: " + err.Error()})
+		return
+	}
+	if !valid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "invalid code"})
+		return
+	}
+
+	_, err = usersCollection().UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$unset": bson.M{
+		"totpEnabled":       "",
+		"totpSecret":        "",
+		"pendingTotpSecret": "",
+		"totpLastStep":      "",
+		"recoveryCodes":     "",
+	}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to disable two-factor authentication: " + err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after a valid TOTP code.
+func RegenerateRecoveryCodes(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+		return
+	}
+
+	user, ok := loadCurrentUser(ctx, c)
+	if !ok {
+		return
+	}
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "two-factor authentication is not enabled"})
+		return
+	}
+	valid, err := verifySecondFactor(ctx, user, req.Code, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify code: " + err.Error()})
+		return
+	}
+	if !valid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "invalid code"})
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate recovery codes: " + err.Error()})
+		return
+	}
+	if _, err := usersCollection().UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"recoveryCodes": hashes}}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store recovery codes: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+// issueLoginChallenge answers a password-valid login for a 2FA account. No session
+// exists until CompleteLogin succeeds.
+func issueLoginChallenge(ctx context.Context, c *gin.Context, user *models.Users) {
+	token, expiresAt, err := createLoginChallenge(ctx, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create challenge: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"twoFactorRequired": true,
+		"challenge":         token,
+		"expiresAt":         expiresAt,
+	})
+}
+
+const (
+	challengeCookieName = "login_challenge"
+	challengeCookiePath = "/users/auth/2fa"
+)
+
+// setChallengeCookie hands a login challenge to the browser in a short-lived
+// HttpOnly cookie that is only sent to CompleteLogin; maxAge -1 clears it.
+func setChallengeCookie(c *gin.Context, token string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     challengeCookieName,
+		Value:    token,
+		Path:     challengeCookiePath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   sessionCookieSecure(),
+		SameSite: sessionCookieSameSite(),
+	})
+}
+
+// createLoginChallenge stores a pending second-factor challenge and returns its token.
+func createLoginChallenge(ctx context.Context, userID string) (string, time.Time, error) {
+	token, err := generateSecretToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	challenge := loginChallenge{
+		TokenHash: hashSecretToken(token),
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(loginChallengeTTL()),
+	}
+	challenges := db.Client.Database("main").Collection("login_challenges")
+	_, err = challenges.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if _, err := challenges.InsertOne(ctx, challenge); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, challenge.ExpiresAt, nil
+}
+
+var errChallengeInvalid = errors.New("invalid or expired login challenge")
+
+// takeChallengeAttempt counts an attempt against the challenge and returns it, or
+// errChallengeInvalid once it has expired or run out of attempts.
+func takeChallengeAttempt(ctx context.Context, token string) (*loginChallenge, error) {
+	challenges := db.Client.Database("main").Collection("login_challenges")
+	var challenge loginChallenge
+	err := challenges.FindOneAndUpdate(ctx,
+		bson.M{
+			"_id":       hashSecretToken(token),
+			"expiresAt": bson.M{"$gt": time.Now()},
+			"attempts":  bson.M{"$lt": maxChallengeAttempts},
+		},
+		bson.M{"$inc": bson.M{"attempts": 1}},
+	).Decode(&challenge)
+	if err == mongo.ErrNoDocuments {
+		return nil, errChallengeInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// CompleteLogin finishes a two-step login with a TOTP or recovery code and issues the session.
+func CompleteLogin(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var req CompleteLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code or recoveryCode is required"})
+		return
+	}
+	if req.Challenge == "" {
+		req.Challenge, _ = c.Cookie(challengeCookieName)
+	}
+	if req.Challenge == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "challenge is required"})
+		return
+	}
+
+	if db.Client == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database connection not available"})
+		return
+	}
+
+	challenge, err := takeChallengeAttempt(ctx, req.Challenge)
+	if err == errChallengeInvalid {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to find challenge: " + err.Error()})
+		return
+	}
+
+	var user models.Users
+	if err := usersCollection().FindOne(ctx, bson.M{"_id": challenge.UserID}).Decode(&user); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errChallengeInvalid.Error()})
+		return
+	}
+	ip := c.ClientIP()
+	if !beginLoginAttempt(ctx, c, user.Username) {
+		return
+	}
+
+	valid, err := verifySecondFactor(ctx, &user, req.Code, req.RecoveryCode)
+	if err != nil {
+		releaseLoginAttempt(ctx, user.Username, ip)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify code: " + err.Error()})
+		return
+	}
+	if !valid {
+		if err := loginThrottle.RecordFailure(ctx, user.Username, ip); err != nil {
+			log.Printf("failed to record login failure: %v", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid code"})
+		return
+	}
+
+	challenges := db.Client.Database("main").Collection("login_challenges")
+	if _, err := challenges.DeleteOne(ctx, bson.M{"_id": challenge.TokenHash}); err != nil {
+		releaseLoginAttempt(ctx, user.Username, ip)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to consume challenge: " + err.Error()})
+		return
+	}
+	if err := loginThrottle.RecordSuccess(ctx, user.Username, ip); err != nil {
+		log.Printf("failed to reset login failures: %v", err)
+	}
+	setChallengeCookie(c, "", -1)
+
+	if err := respondWithSessionUser(c, &user, http.StatusOK); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session: " + err.Error()})
+	}
+}
@@ -0,0 +1,77 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, hashes, err := generateRecoveryCodes()
+	require.NoError(t, err)
+	require.Len(t, codes, recoveryCodeCount)
+	require.Len(t, hashes, recoveryCodeCount)
+
+	format := regexp.MustCompile(`^[a-z1-9]{5}-[a-z1-9]{5}$`)
+	seen := map[string]bool{}
+	for i, code := range codes {
+		assert.Regexp(t, format, code)
+		assert.Equal(t, hashSecretToken(normalizeRecoveryCode(code)), hashes[i])
+		assert.False(t, seen[code], "codes must be unique")
+		seen[code] = true
+	}
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	assert.Equal(t, "abcde12345", normalizeRecoveryCode(" ABCDE-12345"))
+	assert.Equal(t, "abcde12345", normalizeRecoveryCode("abcde 12345"))
+}
+
+func TestCompleteLogin_RequiresCode(t *testing.T) {
+	router := setupRouter()
+	router.POST("/users/auth/2fa", CompleteLogin)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, createTestRequest(http.MethodPost, "/users/auth/2fa", map[string]string{"challenge": "abc"}))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, createTestRequest(http.MethodPost, "/users/auth/2fa", map[string]string{"code": "123456"}))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "challenge is required")
+}
+
+func TestCompleteLogin_ReadsChallengeCookie(t *testing.T) {
+	router := setupRouter()
+	router.POST("/users/auth/2fa", CompleteLogin)
+
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	setChallengeCookie(c, "abc", 300)
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.True(t, cookies[0].HttpOnly)
+	assert.Equal(t, challengeCookiePath, cookies[0].Path)
+
+	req := createTestRequest(http.MethodPost, "/users/auth/2fa", map[string]string{"code": "123456"})
+	req.AddCookie(cookies[0])
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	// The challenge was found; the request only fails on the missing database.
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "database connection not available")
+}
+
+func TestConfirmTwoFactor_RequiresCode(t *testing.T) {
+	router := setupRouter()
+	router.POST("/users/me/2fa/confirm", ConfirmTwoFactor)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, createTestRequest(http.MethodPost, "/users/me/2fa/confirm", map[string]string{}))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -2,7 +2,10 @@ package routes
 
 import (
 	"context"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"FATE-Vault/backend/db"
@@ -15,9 +18,9 @@ import (
 )
 
 type RegisterRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
-	Role     string `json:"role,omitempty"`
+	Username   string `json:"username" binding:"required"`
+	Password   string `json:"password" binding:"required"`
+	InviteCode string `json:"inviteCode,omitempty"`
 }
 
 type AuthRequest struct {
@@ -61,57 +64,78 @@ func RegisterUser(c *gin.Context) {
 		return
 	}
 
-	// Set default role if not provided
-	role := req.Role
-	if role == "" {
-		role = "user"
+	if err := passwordPolicy.Validate(req.Password, req.Username); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	// Validate role
-	if role != "admin" && role != "user" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be either 'admin' or 'user'"})
+	admission, err := admitRegistration(ctx, req.InviteCode)
+	if err != nil {
+		respondRegistrationError(c, err)
 		return
 	}
 
 	// Create new user
+	now := time.Now()
 	user := models.Users{
-		ID:       uuid.NewString(),
-		Username: req.Username,
-		Role:     role,
+		ID:        uuid.NewString(),
+		Username:  req.Username,
+		Role:      admission.role(),
+		CreatedAt: now,
+		UpdatedAt: now,
 	}
 
 	// Hash password
-	if err := user.SetPassword(req.Password); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to hash password: " + err.Error()})
-		return
+	err = user.SetPassword(req.Password)
+	if err == nil {
+		_, err = coll.InsertOne(ctx, user)
 	}
-
-	_, err = coll.InsertOne(ctx, user)
 	if err != nil {
+		admission.release(ctx)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create user: " + err.Error()})
 		return
 	}
 
 	// Return user without password; session ID is stored in HttpOnly cookie.
 	user.HashedPassword = ""
-	session := sessionManager.Create(user.ID)
+	session, err := sessionManager.Create(ctx, user.ID, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session: " + err.Error()})
+		return
+	}
 	setSessionCookie(c, session.ID)
 	c.JSON(http.StatusCreated, gin.H{"user": user})
 }
 
 func respondWithSessionUser(c *gin.Context, user *models.Users, status int) error {
-	// Rotate existing session ID to mitigate session fixation.
-	if oldSessionID := sessionIDFromRequest(c); oldSessionID != "" {
-		sessionManager.Destroy(oldSessionID)
+	if err := startSession(c, user); err != nil {
+		return err
 	}
-	session := sessionManager.Create(user.ID)
-	setSessionCookie(c, session.ID)
 	u := *user
 	u.HashedPassword = ""
 	c.JSON(status, gin.H{"user": u})
 	return nil
 }
 
+// startSession issues a new session cookie for the user.
+func startSession(c *gin.Context, user *models.Users) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Rotate existing session ID to mitigate session fixation.
+	if oldSessionID := sessionIDFromRequest(c); oldSessionID != "" {
+		if err := sessionManager.Destroy(ctx, oldSessionID); err != nil {
+			return err
+		}
+	}
+	session, err := sessionManager.Create(ctx, user.ID, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		return err
+	}
+	setSessionCookie(c, session.ID)
+	return nil
+}
+
 // GetCurrentUser returns user data from already-authenticated middleware context.
 func GetCurrentUser(c *gin.Context) {
 	user, ok := GetUserFromContext(c)
@@ -120,18 +144,57 @@ func GetCurrentUser(c *gin.Context) {
 		return
 	}
 	user.HashedPassword = ""
-	c.JSON(http.StatusOK, gin.H{"user": user})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	permissions, err := callerPermissions(ctx, c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load roles: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user": user, "permissions": permissions})
 }
 
 // LogoutUser clears the session cookie.
 func LogoutUser(c *gin.Context) {
 	if sessionID := sessionIDFromRequest(c); sessionID != "" {
-		sessionManager.Destroy(sessionID)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := sessionManager.Destroy(ctx, sessionID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to destroy session: " + err.Error()})
+			return
+		}
 	}
 	clearSessionCookie(c)
 	c.Status(http.StatusNoContent)
 }
 
+// beginLoginAttempt reserves a login attempt for username, or answers 429 and
+// returns false while the username or the client IP is backed off or locked out.
+// An allowed attempt must be ended through loginThrottle.
+func beginLoginAttempt(ctx context.Context, c *gin.Context, username string) bool {
+	retryAfter, err := loginThrottle.Begin(ctx, username, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check login attempts: " + err.Error()})
+		return false
+	}
+	if retryAfter > 0 {
+		seconds := int(math.Ceil(retryAfter.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(seconds))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed login attempts, try again later", "retryAfter": seconds})
+		return false
+	}
+	return true
+}
+
+// releaseLoginAttempt ends an attempt from beginLoginAttempt without a verdict.
+func releaseLoginAttempt(ctx context.Context, username, ip string) {
+	if err := loginThrottle.Release(ctx, username, ip); err != nil {
+		log.Printf("failed to release login attempt: %v", err)
+	}
+}
+
 func AuthUser(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -154,21 +217,42 @@ func AuthUser(c *gin.Context) {
 		return
 	}
 
+	ip := c.ClientIP()
+	if !beginLoginAttempt(ctx, c, req.Username) {
+		return
+	}
+
 	var user models.Users
 	filter := bson.M{"username": req.Username}
 	err := coll.FindOne(ctx, filter).Decode(&user)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid username or password"})
-			return
-		}
+	if err != nil && err != mongo.ErrNoDocuments {
+		releaseLoginAttempt(ctx, req.Username, ip)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to find user: " + err.Error()})
 		return
 	}
-	if !user.CheckPassword(req.Password) {
+	// Unknown usernames still pay for a bcrypt comparison so timing does not reveal them.
+	valid := false
+	if err == mongo.ErrNoDocuments {
+		models.CompareDummyPassword(req.Password)
+	} else {
+		valid = user.CheckPassword(req.Password)
+	}
+	if !valid {
+		if err := loginThrottle.RecordFailure(ctx, req.Username, ip); err != nil {
+			log.Printf("failed to record login failure: %v", err)
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid username or password"})
 		return
 	}
+	// Failures are only cleared once the second factor is also passed.
+	if user.TOTPEnabled {
+		releaseLoginAttempt(ctx, req.Username, ip)
+		issueLoginChallenge(ctx, c, &user)
+		return
+	}
+	if err := loginThrottle.RecordSuccess(ctx, req.Username, ip); err != nil {
+		log.Printf("failed to reset login failures: %v", err)
+	}
 
 	if err := respondWithSessionUser(c, &user, http.StatusOK); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session: " + err.Error()})
@@ -185,10 +269,14 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
-	// Check authorization: user must be admin or updating their own account
+	// Check authorization: users can update their own account, moderators anyone's
 	userId, exists := c.Get("userId")
-	role, _ := c.Get("role")
-	if !exists || (role != "admin" && userId != id) {
+	canModerate, err := hasPermission(ctx, c, PermUsersModerate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load roles: " + err.Error()})
+		return
+	}
+	if !exists || (!canModerate && userId != id) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
 		return
 	}
@@ -206,11 +294,39 @@ func UpdateUser(c *gin.Context) {
 
 	coll := db.Client.Database("main").Collection("users")
 
-	// Only admin can change role
-	if req.Role != "" && role != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "only admin can change user role"})
+	// Only role managers can change roles
+	canManageRoles, err := hasPermission(ctx, c, PermRolesManage)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load roles: " + err.Error()})
+		return
+	}
+	if req.Role != "" && !canManageRoles {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only role managers can change user role"})
+		return
+	}
+
+	var existing models.Users
+	if err := coll.FindOne(ctx, bson.M{"_id": id}).Decode(&existing); err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to find user: " + err.Error()})
 		return
 	}
+	// Moderators cannot touch the accounts of role managers, such as admins; only
+	// another role manager can.
+	if userId != id && !canManageRoles {
+		target, err := roles.get(ctx, existing.Role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load roles: " + err.Error()})
+			return
+		}
+		if target != nil && target.grants(PermRolesManage) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
+			return
+		}
+	}
 
 	// Build update document
 	update := bson.M{}
@@ -234,13 +350,21 @@ func UpdateUser(c *gin.Context) {
 	}
 	if req.Role != "" {
 		// Validate role
-		if req.Role != "admin" && req.Role != "user" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "role must be either 'admin' or 'user'"})
+		defined, err := roles.get(ctx, req.Role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load roles: " + err.Error()})
+			return
+		}
+		if defined == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown role " + req.Role})
 			return
 		}
 		update["role"] = req.Role
 	}
 
+	// A role change alters what existing sessions may do, so they are revoked below.
+	roleChanged := req.Role != "" && existing.Role != req.Role
+
 	if len(update) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields to update"})
 		return
@@ -259,6 +383,16 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
+	if roleChanged {
+		if _, err := sessionManager.DestroyAllForUser(ctx, id, ""); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions: " + err.Error()})
+			return
+		}
+		if id == userId {
+			clearSessionCookie(c)
+		}
+	}
+
 	// Fetch updated user
 	var user models.Users
 	err = coll.FindOne(ctx, filter).Decode(&user)
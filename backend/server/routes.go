@@ -9,33 +9,106 @@ import (
 func registerRoutes(router *gin.Engine) {
 	//characters
 	router.GET("/characters", routes.CharactersList)
-	router.POST("/characters/create", routes.CreateCharacter)
-	router.POST("/characters/update/:id", routes.UpdateCharacter)
-	router.DELETE("/characters/delete/:id", routes.DeleteCharacter)
+	router.POST("/characters/create", routes.AuthMiddleware(), routes.RequirePermission(routes.PermCharactersWrite), routes.CreateCharacter)
+	router.POST("/characters/update/:id", routes.AuthMiddleware(), routes.RequirePermission(routes.PermCharactersWrite), routes.UpdateCharacter)
+	router.DELETE("/characters/delete/:id", routes.AuthMiddleware(), routes.RequirePermission(routes.PermCharactersWrite), routes.DeleteCharacter)
 	router.GET("/characters/find", routes.FindCharacters)
+	router.GET("/characters/:id/stunts/applicable", routes.ApplicableCharacterStunts)
+	router.POST("/characters/:id/stunts/use/:index", routes.AuthMiddleware(), routes.RequirePermission(routes.PermCharactersWrite, routes.ScopeDiceRoll), routes.UseCharacterStunt)
+	router.POST("/characters/:id/stunts/reset", routes.AuthMiddleware(), routes.RequirePermission(routes.PermCharactersWrite, routes.ScopeDiceRoll), routes.ResetStuntUses)
+	router.POST("/characters/from-template/:id", routes.AuthMiddleware(), routes.RequirePermission(routes.PermCharactersWrite), routes.CreateCharacterFromTemplate)
+
+	//templates
 	router.GET("/templates", routes.GetTemplates)
+	router.GET("/templates/:id", routes.GetTemplate)
+	router.GET("/templates/:id/versions", routes.ListTemplateVersions)
+	router.POST("/templates/create", routes.AuthMiddleware(), routes.RequirePermission(routes.PermCharactersWrite), routes.CreateTemplate)
+	router.POST("/templates/update/:id", routes.AuthMiddleware(), routes.RequirePermission(routes.PermCharactersWrite), routes.UpdateTemplate)
+	router.DELETE("/templates/delete/:id", routes.AuthMiddleware(), routes.RequirePermission(routes.PermCharactersWrite), routes.DeleteTemplate)
+
+	//search
+	router.GET("/search", routes.Search)
+
+	//saved searches
+	router.GET("/searches", routes.AuthMiddleware(), routes.RequirePermission(routes.PermCharactersRead), routes.ListSavedSearches)
+	router.POST("/searches/create", routes.AuthMiddleware(), routes.RequirePermission(routes.PermCharactersRead), routes.CreateSavedSearch)
+	router.POST("/searches/update/:id", routes.AuthMiddleware(), routes.RequirePermission(routes.PermCharactersRead), routes.UpdateSavedSearch)
+	router.DELETE("/searches/delete/:id", routes.AuthMiddleware(), routes.RequirePermission(routes.PermCharactersRead), routes.DeleteSavedSearch)
 
 	//categories
-	router.GET("/categories", routes.ListCategories)
-	router.POST("/categories/create", routes.CreateCategory)
-	router.POST("/categories/update/:id", routes.UpdateCategory)
-	router.DELETE("/categories/delete/:id", routes.DeleteCategory)
+	router.GET("/categories", routes.AuthMiddleware(), routes.RequirePermission(routes.PermCharactersRead), routes.ListCategories)
+	router.GET("/categories/tree", routes.AuthMiddleware(), routes.RequirePermission(routes.PermCharactersRead), routes.GetCategoryTree)
+	router.POST("/categories/create", routes.AuthMiddleware(), routes.RequirePermission(routes.PermCharactersWrite), routes.CreateCategory)
+	router.POST("/categories/update/:id", routes.AuthMiddleware(), routes.RequirePermission(routes.PermCharactersWrite), routes.UpdateCategory)
+	router.POST("/categories/move/:id", routes.AuthMiddleware(), routes.RequirePermission(routes.PermCharactersWrite), routes.MoveCategory)
+	router.POST("/categories/reorder", routes.AuthMiddleware(), routes.RequirePermission(routes.PermCharactersWrite), routes.ReorderCategories)
+	router.POST("/categories/characters/add/:id", routes.AuthMiddleware(), routes.RequirePermission(routes.PermCharactersWrite), routes.AddCategoryCharacters)
+	router.POST("/categories/characters/remove/:id", routes.AuthMiddleware(), routes.RequirePermission(routes.PermCharactersWrite), routes.RemoveCategoryCharacters)
+	router.DELETE("/categories/delete/:id", routes.AuthMiddleware(), routes.RequirePermission(routes.PermCharactersWrite), routes.DeleteCategory)
+
+	//vocabulary
+	router.GET("/vocabulary", routes.GetVocabulary)
+
+	//tags
+	router.GET("/tags", routes.ListTags)
+	router.POST("/tags/rename", routes.AuthMiddleware(), routes.RequirePermission(routes.PermTagsManage), routes.RenameTag)
+
 	//games
 	router.GET("/games", routes.ListGames)
-	router.POST("/games/create", routes.CreateGame)
-	router.POST("/games/update/:id", routes.UpdateGame)
-	router.DELETE("/games/delete/:id", routes.DeleteGame)
+	router.POST("/games/create", routes.AuthMiddleware(), routes.RequirePermission(routes.PermCharactersWrite), routes.CreateGame)
+	router.POST("/games/update/:id", routes.AuthMiddleware(), routes.RequirePermission(routes.PermCharactersWrite), routes.UpdateGame)
+	router.DELETE("/games/delete/:id", routes.AuthMiddleware(), routes.RequirePermission(routes.PermCharactersWrite), routes.DeleteGame)
 
 	//stunts
 	router.GET("/stunts", routes.ListStunts)
-	router.POST("/stunts/create", routes.CreateStunt)
-	router.POST("/stunts/update/:id", routes.UpdateStunt)
-	router.DELETE("/stunts/delete/:id", routes.DeleteStunt)
+	router.GET("/stunts/:id/characters", routes.StuntCharacters)
+	router.POST("/stunts/promote", routes.AuthMiddleware(), routes.RequirePermission(routes.PermStuntsWrite), routes.PromoteStunt)
+	router.POST("/stunts/create", routes.AuthMiddleware(), routes.RequirePermission(routes.PermStuntsWrite), routes.CreateStunt)
+	router.POST("/stunts/update/:id", routes.AuthMiddleware(), routes.RequirePermission(routes.PermStuntsWrite), routes.UpdateStunt)
+	router.DELETE("/stunts/delete/:id", routes.AuthMiddleware(), routes.RequirePermission(routes.PermStuntsWrite), routes.DeleteStunt)
 
 	//users
 	router.POST("/users/register", routes.RegisterUser)
 	router.POST("/users/auth", routes.AuthUser)
+	router.POST("/users/auth/2fa", routes.CompleteLogin)
+	router.GET("/auth/oidc/login", routes.StartOIDCLogin)
+	router.GET("/auth/oidc/callback", routes.FinishOIDCLogin)
 	router.POST("/users/logout", routes.LogoutUser)
+	router.POST("/users/password/reset", routes.ResetPassword)
 	router.GET("/users/me", routes.AuthMiddleware(), routes.GetCurrentUser)
-	router.POST("/users/update/:id", routes.AuthMiddleware(), routes.UpdateUser)
+	router.GET("/users/csrf", routes.AuthMiddleware(), routes.RequireSession(), routes.GetCSRFToken)
+	router.POST("/users/update/:id", routes.AuthMiddleware(), routes.RequireSession(), routes.UpdateUser)
+	router.POST("/users/me/locale", routes.AuthMiddleware(), routes.RequireSession(), routes.UpdateMyLocale)
+	router.POST("/users/me/password", routes.AuthMiddleware(), routes.RequireSession(), routes.ChangePassword)
+	router.POST("/users/me/2fa/setup", routes.AuthMiddleware(), routes.RequireSession(), routes.SetupTwoFactor)
+	router.POST("/users/me/2fa/confirm", routes.AuthMiddleware(), routes.RequireSession(), routes.ConfirmTwoFactor)
+	router.POST("/users/me/2fa/disable", routes.AuthMiddleware(), routes.RequireSession(), routes.DisableTwoFactor)
+	router.POST("/users/me/2fa/recovery-codes", routes.AuthMiddleware(), routes.RequireSession(), routes.RegenerateRecoveryCodes)
+	router.GET("/users/me/sessions", routes.AuthMiddleware(), routes.RequireSession(), routes.ListMySessions)
+	router.DELETE("/users/me/sessions", routes.AuthMiddleware(), routes.RequireSession(), routes.RevokeMyOtherSessions)
+	router.DELETE("/users/me/sessions/:id", routes.AuthMiddleware(), routes.RequireSession(), routes.RevokeMySession)
+	router.GET("/users/me/tokens", routes.AuthMiddleware(), routes.RequireSession(), routes.ListMyTokens)
+	router.POST("/users/me/tokens", routes.AuthMiddleware(), routes.RequireSession(), routes.CreateMyToken)
+	router.DELETE("/users/me/tokens/:id", routes.AuthMiddleware(), routes.RequireSession(), routes.DeleteMyToken)
+
+	//admin
+	storage := router.Group("/admin/storage", routes.AuthMiddleware(), routes.RequirePermission(routes.PermStorageManage))
+	storage.GET("/orphans", routes.GetStorageOrphans)
+	storage.GET("/orphans/last", routes.GetLastStorageReport)
+	storage.POST("/orphans/cleanup", routes.CleanupStorageOrphans)
+
+	users := router.Group("/admin", routes.AuthMiddleware(), routes.RequirePermission(routes.PermUsersManage))
+	users.POST("/users/:id/password-reset", routes.IssuePasswordReset)
+	users.GET("/invites", routes.ListInvites)
+	users.POST("/invites", routes.CreateInvite)
+	users.DELETE("/invites/:id", routes.DeleteInvite)
+
+	moderation := router.Group("/admin", routes.AuthMiddleware(), routes.RequirePermission(routes.PermUsersModerate))
+	moderation.DELETE("/users/:id/sessions", routes.RevokeUserSessions)
+
+	roles := router.Group("/admin/roles", routes.AuthMiddleware(), routes.RequirePermission(routes.PermRolesManage))
+	roles.GET("", routes.ListRoles)
+	roles.POST("", routes.SaveRole)
+	roles.POST("/:id", routes.SaveRole)
+	roles.DELETE("/:id", routes.DeleteRole)
 }
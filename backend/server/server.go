@@ -3,6 +3,9 @@ package server
 import (
 	"log"
 	"os"
+	"strings"
+
+	"FATE-Vault/backend/routes"
 
 	"github.com/gin-gonic/gin"
 )
@@ -14,9 +17,49 @@ func webOrigin() string {
 	return "http://localhost:3000"
 }
 
+// trustedProxies lists the proxies whose X-Forwarded-For is believed when
+// finding the client IP, from TRUSTED_PROXIES. By default none are, so clients
+// cannot pick the IP that login throttling and sessions record.
+func trustedProxies() []string {
+	var proxies []string
+	for _, p := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			proxies = append(proxies, p)
+		}
+	}
+	return proxies
+}
+
 // New creates and configures a new Gin engine (HTTP server).
 func New() *gin.Engine {
+	sessions, err := routes.NewSessionManagerFromEnv()
+	if err != nil {
+		log.Fatalf("session store error: %v", err)
+	}
+	routes.SetSessionManager(sessions)
+
+	passwords, err := routes.LoadPasswordPolicyFromEnv()
+	if err != nil {
+		log.Fatalf("password policy error: %v", err)
+	}
+	routes.SetPasswordPolicy(passwords)
+
+	throttle, err := routes.NewLoginThrottleFromEnv()
+	if err != nil {
+		log.Fatalf("login throttle error: %v", err)
+	}
+	routes.SetLoginThrottle(throttle)
+
+	oidcClient, err := routes.NewOIDCClientFromEnv()
+	if err != nil {
+		log.Fatalf("oidc configuration error: %v", err)
+	}
+	routes.SetOIDCClient(oidcClient)
+
 	router := gin.Default()
+	if err := router.SetTrustedProxies(trustedProxies()); err != nil {
+		log.Fatalf("TRUSTED_PROXIES error: %v", err)
+	}
 	allowed := webOrigin()
 
 	// CORS: credentials require a specific origin (not *).
@@ -38,6 +81,7 @@ func New() *gin.Engine {
 
 		c.Next()
 	})
+	router.Use(routes.CSRFProtect())
 
 	registerRoutes(router)
 
@@ -46,6 +90,13 @@ func New() *gin.Engine {
 
 // Run starts the HTTP server on the given address.
 func Run(addr string) {
+	if err := routes.EnsureIdentityIndex(); err != nil {
+		log.Fatalf("identity index error: %v", err)
+	}
+	routes.StartStorageGC()
+	routes.MigrateCategories()
+	routes.MigrateVocabulary()
+
 	if err := New().Run(addr); err != nil {
 		log.Fatalf("server run error: %v", err)
 	}
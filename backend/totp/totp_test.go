@@ -0,0 +1,65 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 key from the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestHOTP_RFC6238Vectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+	cases := map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	}
+	for unix, want := range cases {
+		if got := hotp(key, Step(time.Unix(unix, 0)), 8); got != want {
+			t.Errorf("T=%d: got %s, want %s", unix, got, want)
+		}
+	}
+}
+
+func TestValidate_AcceptsSkewAndReturnsStep(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	code, err := Code(rfcSecret, now.Add(-Period))
+	if err != nil {
+		t.Fatalf("Code: %v", err)
+	}
+
+	step, ok := Validate(rfcSecret, code, now, 1)
+	if !ok || step != Step(now)-1 {
+		t.Fatalf("expected previous step to validate, got step=%d ok=%v", step, ok)
+	}
+	if _, ok := Validate(rfcSecret, code, now, 0); ok {
+		t.Fatalf("code from the previous step must fail without skew")
+	}
+	if _, ok := Validate(rfcSecret, "12345", now, 1); ok {
+		t.Fatalf("short code must fail")
+	}
+}
+
+func TestGenerateSecretAndURI(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret: %v", err)
+	}
+	if len(secret) != 32 {
+		t.Fatalf("expected 32 base32 characters, got %d", len(secret))
+	}
+	if _, err := Code(secret, time.Now()); err != nil {
+		t.Fatalf("generated secret does not decode: %v", err)
+	}
+
+	uri := URI("FATE Vault", "alice", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/FATE%20Vault:alice?") || !strings.Contains(uri, "secret="+secret) {
+		t.Fatalf("unexpected URI %s", uri)
+	}
+}
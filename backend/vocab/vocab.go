@@ -0,0 +1,199 @@
+// Package vocab is the game vocabulary of character sheets: skills, approaches
+// and the types of aspects, stress tracks and consequences. Sheets store a term's
+// stable identifier; each term has a display name per locale.
+package vocab
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Kind string
+
+const (
+	Skill           Kind = "skill"
+	Approach        Kind = "approach"
+	AspectType      Kind = "aspect"
+	StressType      Kind = "stress"
+	ConsequenceType Kind = "consequence"
+)
+
+// Kinds lists every kind of term, in the order clients show them.
+var Kinds = []Kind{Skill, Approach, AspectType, StressType, ConsequenceType}
+
+// DefaultLocale is used when a client's locale is not supported. Every term has
+// a name in it.
+const DefaultLocale = "en"
+
+// Locales are the locales every term has a name in.
+var Locales = []string{"en", "ru"}
+
+// Term is one word of the vocabulary. Aliases are other names it is recognised
+// by, such as an older translation, but never shown.
+type Term struct {
+	ID      string            `json:"id"`
+	Names   map[string]string `json:"names"`
+	Aliases []string          `json:"aliases,omitempty"`
+}
+
+type Vocabulary struct {
+	terms map[Kind][]Term
+	byID  map[Kind]map[string]*Term
+	// byName maps folded names and aliases in every locale to identifiers.
+	byName map[Kind]map[string]string
+}
+
+//go:embed vocabulary.json
+var builtin []byte
+
+var defaultVocabulary = mustParse(builtin)
+
+// Default is the vocabulary built into the server.
+func Default() *Vocabulary {
+	return defaultVocabulary
+}
+
+func mustParse(data []byte) *Vocabulary {
+	v, err := Parse(data)
+	if err != nil {
+		panic("vocab: " + err.Error())
+	}
+	return v
+}
+
+func fold(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// Parse reads a vocabulary: an object mapping each kind to its terms. Every term
+// needs a name in each of Locales, and no name may mean two terms of a kind.
+func Parse(data []byte) (*Vocabulary, error) {
+	var terms map[Kind][]Term
+	if err := json.Unmarshal(data, &terms); err != nil {
+		return nil, err
+	}
+
+	v := &Vocabulary{
+		terms:  terms,
+		byID:   map[Kind]map[string]*Term{},
+		byName: map[Kind]map[string]string{},
+	}
+	for kind, list := range terms {
+		ids := map[string]*Term{}
+		names := map[string]string{}
+		for i := range list {
+			t := &list[i]
+			if t.ID == "" {
+				return nil, fmt.Errorf("%s term %d has no id", kind, i)
+			}
+			if ids[t.ID] != nil {
+				return nil, fmt.Errorf("%s %q is defined twice", kind, t.ID)
+			}
+			ids[t.ID] = t
+
+			keys := []string{t.ID}
+			for _, locale := range Locales {
+				if t.Names[locale] == "" {
+					return nil, fmt.Errorf("%s %q has no %s name", kind, t.ID, locale)
+				}
+			}
+			for _, name := range t.Names {
+				keys = append(keys, name)
+			}
+			keys = append(keys, t.Aliases...)
+			for _, key := range keys {
+				key = fold(key)
+				if other, ok := names[key]; ok && other != t.ID {
+					return nil, fmt.Errorf("%s name %q means both %q and %q", kind, key, other, t.ID)
+				}
+				names[key] = t.ID
+			}
+		}
+		v.byID[kind] = ids
+		v.byName[kind] = names
+	}
+	return v, nil
+}
+
+// Terms returns the terms of a kind.
+func (v *Vocabulary) Terms(kind Kind) []Term {
+	return v.terms[kind]
+}
+
+// ID finds the term a sheet means by s, which may be an identifier or a name or
+// alias in any locale, ignoring case and extra spaces.
+func (v *Vocabulary) ID(kind Kind, s string) (string, bool) {
+	if _, ok := v.byID[kind][s]; ok {
+		return s, true
+	}
+	id, ok := v.byName[kind][fold(s)]
+	return id, ok
+}
+
+// Name is the display name of a term in a locale, falling back to DefaultLocale.
+// Strings that are not identifiers of the kind, such as a custom skill, are
+// returned unchanged.
+func (v *Vocabulary) Name(kind Kind, id, locale string) string {
+	t, ok := v.byID[kind][id]
+	if !ok {
+		return id
+	}
+	if name := t.Names[locale]; name != "" {
+		return name
+	}
+	return t.Names[DefaultLocale]
+}
+
+// Match returns the supported locale for a language tag such as "ru-RU", or ""
+// if there is none.
+func Match(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	for _, locale := range Locales {
+		if tag == locale {
+			return locale
+		}
+	}
+	return ""
+}
+
+// Negotiate picks the supported locale the client prefers most from an
+// Accept-Language header, or "" if it accepts none of them.
+func Negotiate(acceptLanguage string) string {
+	type candidate struct {
+		locale string
+		q      float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		locale := Match(tag)
+		if locale == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{locale, q})
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+	return candidates[0].locale
+}
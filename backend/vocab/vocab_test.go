@@ -0,0 +1,81 @@
+package vocab
+
+import "testing"
+
+func TestID(t *testing.T) {
+	v := Default()
+	cases := []struct {
+		kind Kind
+		in   string
+		want string
+		ok   bool
+	}{
+		{Skill, "fight", "fight", true},
+		{Skill, "Драка", "fight", true},
+		{Skill, "  athletics ", "athletics", true},
+		{Skill, "Оккультные знания", "lore", true},
+		{Approach, "Хитрый", "sneaky", true},
+		{AspectType, "High   Concept", "highConcept", true},
+		{Skill, "Хитрый", "", false},
+		{Skill, "Pilot", "", false},
+	}
+	for _, tc := range cases {
+		got, ok := v.ID(tc.kind, tc.in)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("ID(%s, %q) = %q, %v; want %q, %v", tc.kind, tc.in, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestName(t *testing.T) {
+	v := Default()
+	if got := v.Name(Skill, "fight", "ru"); got != "Драка" {
+		t.Errorf("ru name = %q", got)
+	}
+	if got := v.Name(Skill, "fight", "de"); got != "Fight" {
+		t.Errorf("fallback name = %q", got)
+	}
+	if got := v.Name(Skill, "Pilot", "ru"); got != "Pilot" {
+		t.Errorf("custom skill = %q", got)
+	}
+}
+
+func TestDefaultHasEveryKind(t *testing.T) {
+	v := Default()
+	for _, kind := range Kinds {
+		if len(v.Terms(kind)) == 0 {
+			t.Errorf("no %s terms", kind)
+		}
+	}
+}
+
+func TestParseRejectsAmbiguousNames(t *testing.T) {
+	_, err := Parse([]byte(`{"skill": [
+		{"id": "fight", "names": {"en": "Fight", "ru": "Драка"}},
+		{"id": "brawl", "names": {"en": "Brawl", "ru": "драка"}}
+	]}`))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	_, err = Parse([]byte(`{"skill": [{"id": "fight", "names": {"en": "Fight"}}]}`))
+	if err == nil {
+		t.Fatal("expected an error for a missing translation")
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	cases := map[string]string{
+		"ru-RU,ru;q=0.9,en-US;q=0.8,en;q=0.7": "ru",
+		"de-DE,en;q=0.5,ru;q=0.7":             "ru",
+		"en-GB":                               "en",
+		"de, fr;q=0.5":                        "",
+		"ru;q=0, en;q=0.1":                    "en",
+		"":                                    "",
+	}
+	for header, want := range cases {
+		if got := Negotiate(header); got != want {
+			t.Errorf("Negotiate(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
@@ -2,23 +2,34 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/minio/minio-go/v7"
 )
 
 func uploadFile(c *gin.Context) {
-	bucketName := os.Getenv("MINIO_BUCKET_NAME")
+	owner, err := resolveCaller(c)
+	if err != nil {
+		respondCallerError(c, err)
+		return
+	}
 
-	err := c.Request.ParseMultipartForm(10 << 20) // 10 MB max
+	// Reject oversized bodies up front; allow some slack for multipart framing.
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, policy.maxUploadSize+(1<<20))
+
+	err = c.Request.ParseMultipartForm(10 << 20) // 10 MB kept in memory, rest spills to disk
 	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("File exceeds the maximum upload size of %d bytes", policy.maxUploadSize)})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse multipart form: " + err.Error()})
 		return
 	}
@@ -30,26 +41,45 @@ func uploadFile(c *gin.Context) {
 	}
 	defer file.Close()
 
-	folder := c.PostForm("folder")
-	if folder != "" {
-		folder = strings.Trim(folder, "/") + "/"
+	if header.Size > policy.maxUploadSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("File exceeds the maximum upload size of %d bytes", policy.maxUploadSize)})
+		return
+	}
+
+	folder, err := cleanFolder(c.PostForm("folder"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	filename := fmt.Sprintf("file_%d", time.Now().Unix())
-	objectName := folder + filename
+	objectName := newObjectName(folder)
+
+	// Trust the file's magic bytes rather than the client-supplied header.
+	contentType, body, err := sniffContentType(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file: " + err.Error()})
+		return
+	}
+	if !policy.allows(objectName, contentType) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": fmt.Sprintf("Content type %s is not allowed in folder '%s'", contentType, folder)})
+		return
+	}
 
-	contentType := header.Header.Get("Content-Type")
-	if contentType == "" {
-		contentType = "application/octet-stream"
+	if err := usage.reserve(owner.ID, objectName, header.Size, policy.userQuota); err != nil {
+		if errors.Is(err, ErrQuotaExceeded) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Storage quota of %d bytes exceeded", policy.userQuota)})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record usage: " + err.Error()})
+		return
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	_, err = minioClient.PutObject(ctx, bucketName, objectName, file, header.Size, minio.PutObjectOptions{
-		ContentType: contentType,
-	})
+	_, err = store.Put(ctx, objectName, body, header.Size, contentType)
 	if err != nil {
+		usage.release(objectName)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload file: " + err.Error()})
 		return
 	}
@@ -65,8 +95,6 @@ func uploadFile(c *gin.Context) {
 }
 
 func downloadFile(c *gin.Context) {
-	bucketName := os.Getenv("MINIO_BUCKET_NAME")
-
 	// Get filepath parameter and remove leading slash
 	pathParam := c.Param("filepath")
 	if pathParam == "" {
@@ -79,22 +107,25 @@ func downloadFile(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	object, err := minioClient.GetObject(ctx, bucketName, filename, minio.GetObjectOptions{})
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "File not found: " + err.Error()})
-		return
-	}
-	defer object.Close()
-
-	objInfo, err := object.Stat()
+	object, objInfo, err := store.Get(ctx, filename)
 	if err != nil {
+		if errors.Is(err, ErrObjectNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found: " + err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file info: " + err.Error()})
 		return
 	}
+	defer object.Close()
 
 	// Set headers
+	disposition := "attachment"
+	if servedInline(objInfo.ContentType) {
+		disposition = "inline"
+	}
 	c.Header("Content-Type", objInfo.ContentType)
-	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", filepath.Base(filename)))
+	c.Header("Content-Disposition", fmt.Sprintf("%s; filename=\"%s\"", disposition, filepath.Base(filename)))
+	c.Header("X-Content-Type-Options", "nosniff")
 	c.Header("Content-Length", fmt.Sprintf("%d", objInfo.Size))
 
 	// Stream file to response
@@ -106,7 +137,11 @@ func downloadFile(c *gin.Context) {
 }
 
 func deleteFile(c *gin.Context) {
-	bucketName := os.Getenv("MINIO_BUCKET_NAME")
+	owner, err := resolveCaller(c)
+	if err != nil {
+		respondCallerError(c, err)
+		return
+	}
 
 	// Get filepath parameter and remove leading slash
 	pathParam := c.Param("filepath")
@@ -117,15 +152,27 @@ func deleteFile(c *gin.Context) {
 	// Remove leading slash if present
 	filename := strings.TrimPrefix(pathParam, "/")
 
+	// Only the uploader may delete a file. Files missing from the usage index have
+	// no known owner, so only storage managers may delete those.
+	if uploader, ok := usage.ownerOf(filename); (!ok || uploader != owner.ID) && !owner.can(permStorageManage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner of a file can delete it"})
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	err := minioClient.RemoveObject(ctx, bucketName, filename, minio.RemoveObjectOptions{})
+	err = store.Delete(ctx, filename)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete file: " + err.Error()})
 		return
 	}
 
+	if err := usage.release(filename); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update usage: " + err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message":  "File deleted successfully",
 		"filename": filename,
@@ -133,32 +180,43 @@ func deleteFile(c *gin.Context) {
 }
 
 func listFiles(c *gin.Context) {
-	bucketName := os.Getenv("MINIO_BUCKET_NAME")
-	if bucketName == "" {
-		bucketName = "fate-vault"
-	}
-
 	// Get optional folder prefix
 	folder := c.Query("folder")
 	if folder != "" {
 		folder = strings.Trim(folder, "/") + "/"
 	}
 
+	query, err := parseListQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	objectCh := minioClient.ListObjects(ctx, bucketName, minio.ListObjectsOptions{
-		Prefix:    folder,
-		Recursive: true,
-	})
-
-	var files []map[string]interface{}
-	for object := range objectCh {
-		if object.Err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list files: " + object.Err.Error()})
-			return
-		}
+	var page ListPage
+	var next *string
+	if query.reorders() {
+		page, next, err = listSorted(ctx, folder, query)
+	} else {
+		page, err = store.List(ctx, ListOptions{
+			Prefix:     folder,
+			StartAfter: query.startAfter,
+			Limit:      query.limit,
+			Recursive:  query.recursive,
+		})
+		next = encodeCursor(page.NextStartAfter)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list files: " + err.Error()})
+		return
+	}
 
+	files := []map[string]interface{}{}
+	var pageSize int64
+	for _, object := range page.Objects {
+		pageSize += object.Size
 		files = append(files, map[string]interface{}{
 			"name":         object.Key,
 			"size":         object.Size,
@@ -168,8 +226,57 @@ func listFiles(c *gin.Context) {
 		})
 	}
 
+	folders := []string{}
+	if page.Folders != nil {
+		folders = page.Folders
+	}
+
+	response := gin.H{
+		"files":      files,
+		"folders":    folders,
+		"count":      len(files),
+		"totalSize":  pageSize,
+		"nextCursor": next,
+	}
+
+	if query.summary {
+		summary, err := summarizePrefix(ctx, folder)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to summarize files: " + err.Error()})
+			return
+		}
+		response["summary"] = summary
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// getMyUsage returns the caller's storage usage and quota.
+func getMyUsage(c *gin.Context) {
+	owner, err := resolveCaller(c)
+	if err != nil {
+		respondCallerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, usage.usageFor(owner.ID, policy.userQuota))
+}
+
+// listUsage returns storage usage for every owner; admins only.
+func listUsage(c *gin.Context) {
+	owner, err := resolveCaller(c)
+	if err != nil {
+		respondCallerError(c, err)
+		return
+	}
+	if !owner.can(permStorageManage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "missing permission " + permStorageManage})
+		return
+	}
+
+	users := usage.all(policy.userQuota)
 	c.JSON(http.StatusOK, gin.H{
-		"files": files,
-		"count": len(files),
+		"users": users,
+		"count": len(users),
 	})
 }
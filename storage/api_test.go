@@ -0,0 +1,733 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testServiceSecret is the service secret setupTestRouter configures.
+const testServiceSecret = "test-secret"
+
+// asService marks req as a service call on behalf of user ("" for none).
+func asService(req *http.Request, user string) *http.Request {
+	req.Header.Set("X-Service-Secret", testServiceSecret)
+	if user != "" {
+		req.Header.Set("X-User-ID", user)
+	}
+	return req
+}
+
+// setupTestRouter swaps the global store for the given one and returns a router using it.
+func setupTestRouter(t *testing.T, s BlobStore) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	prevStore, prevPolicy, prevUsage := store, policy, usage
+	prevResumable, prevSessions, prevAuth := resumable, uploadSessions, auth
+	t.Cleanup(func() {
+		store, policy, usage = prevStore, prevPolicy, prevUsage
+		resumable, uploadSessions, auth = prevResumable, prevSessions, prevAuth
+	})
+
+	p, err := loadUploadPolicy()
+	if err != nil {
+		t.Fatalf("loadUploadPolicy: %v", err)
+	}
+	r, err := loadResumableLimits(s)
+	if err != nil {
+		t.Fatalf("loadResumableLimits: %v", err)
+	}
+	u, _ := newUsageStore("")
+	sessions, _ := newUploadSessionStore("")
+	store, policy, usage = s, p, u
+	resumable, uploadSessions = r, sessions
+	auth = authConfig{ServiceSecret: testServiceSecret}
+
+	return newRouter()
+}
+
+// pngHeader is enough of a PNG file for content sniffing to recognise it.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func newUploadRequest(t *testing.T, folder, contentType string, data []byte) *http.Request {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	if folder != "" {
+		if err := writer.WriteField("folder", folder); err != nil {
+			t.Fatalf("write folder field: %v", err)
+		}
+	}
+	header := make(map[string][]string)
+	header["Content-Disposition"] = []string{`form-data; name="file"; filename="upload.bin"`}
+	header["Content-Type"] = []string{contentType}
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		t.Fatalf("create part: %v", err)
+	}
+	part.Write(data)
+	writer.Close()
+
+	req := asService(httptest.NewRequest(http.MethodPost, "/upload", body), "")
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestUploadDownloadDelete_MemoryStore(t *testing.T) {
+	router := setupTestRouter(t, newMemoryStore())
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUploadRequest(t, "images/characters", "image/png", pngHeader))
+	if w.Code != http.StatusOK {
+		t.Fatalf("upload: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var uploaded map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &uploaded); err != nil {
+		t.Fatalf("decode upload response: %v", err)
+	}
+	url, _ := uploaded["url"].(string)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("download: expected 200, got %d", w.Code)
+	}
+	if w.Body.String() != string(pngHeader) {
+		t.Fatalf("download: expected body %q, got %q", pngHeader, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "image/png" {
+		t.Fatalf("download: expected content type image/png, got %q", ct)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, asService(httptest.NewRequest(http.MethodDelete, "/delete/"+uploaded["filename"].(string), nil), ""))
+	if w.Code != http.StatusOK {
+		t.Fatalf("delete: expected 200, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("download after delete: expected 404, got %d", w.Code)
+	}
+}
+
+func TestUpload_RejectsDisallowedContentBySniffing(t *testing.T) {
+	router := setupTestRouter(t, newMemoryStore())
+
+	// The client claims PNG, but the bytes are HTML.
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUploadRequest(t, "images/characters", "image/png", []byte("<html><script>alert(1)</script></html>")))
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Anything goes outside the restricted folders.
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newUploadRequest(t, "misc", "text/plain", []byte("notes")))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 outside images/, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestUpload_RejectsFolderTraversal(t *testing.T) {
+	router := setupTestRouter(t, newMemoryStore())
+
+	html := []byte("<html><script>alert(1)</script></html>")
+	for _, folder := range []string{"x/../images", "../images", "images/../../etc"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, folder, "text/html", html))
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("folder %q: expected 400, got %d: %s", folder, w.Code, w.Body.String())
+		}
+	}
+
+	// Redundant slashes and dots are tidied before the policy sees the folder.
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUploadRequest(t, "./images//characters/", "text/html", html))
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("cleaned folder: expected 415, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestDownload_ActiveContentIsAnAttachment(t *testing.T) {
+	router := setupTestRouter(t, newMemoryStore())
+
+	download := func(folder string, body []byte) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUploadRequest(t, folder, "text/plain", body))
+		if w.Code != http.StatusOK {
+			t.Fatalf("upload: expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var uploaded map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &uploaded)
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, uploaded["url"].(string), nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("download: expected 200, got %d", w.Code)
+		}
+		if got := w.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+			t.Fatalf("download: expected nosniff, got %q", got)
+		}
+		return w
+	}
+
+	w := download("misc", []byte("<html><script>alert(1)</script></html>"))
+	if got := w.Header().Get("Content-Disposition"); !strings.HasPrefix(got, "attachment;") {
+		t.Fatalf("html: expected an attachment, got %q", got)
+	}
+	w = download("images", pngHeader)
+	if got := w.Header().Get("Content-Disposition"); !strings.HasPrefix(got, "inline;") {
+		t.Fatalf("png: expected inline, got %q", got)
+	}
+}
+
+func TestUpload_EnforcesQuotaPerUser(t *testing.T) {
+	t.Setenv("STORAGE_USER_QUOTA", "20")
+	router := setupTestRouter(t, newMemoryStore())
+
+	upload := func(user string, key string) int {
+		req := newUploadRequest(t, "images/"+key, "image/png", pngHeader)
+		req.Header.Set("X-User-ID", user)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := upload("alice", "a"); code != http.StatusOK {
+		t.Fatalf("first upload: expected 200, got %d", code)
+	}
+	if code := upload("alice", "b"); code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("second upload: expected 413, got %d", code)
+	}
+	if code := upload("bob", "c"); code != http.StatusOK {
+		t.Fatalf("other user: expected 200, got %d", code)
+	}
+
+	auth.URL = fakeAuthService(t, "root", permStorageManage)
+	req := httptest.NewRequest(http.MethodGet, "/usage", nil)
+	req.Header.Set("Cookie", "session=root")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("usage: expected 200, got %d", w.Code)
+	}
+	var resp struct {
+		Count int `json:"count"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Count != 2 {
+		t.Fatalf("usage: expected 2 owners, got %d", resp.Count)
+	}
+}
+
+// fakeAuthService stands in for the backend's /users/me, answering for one user.
+func fakeAuthService(t *testing.T, id string, permissions ...string) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Cookie") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"user": map[string]string{"_id": id}, "permissions": permissions})
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestLoadAuthConfig_RequiresAnIdentitySource(t *testing.T) {
+	t.Setenv("STORAGE_AUTH_URL", "")
+	t.Setenv("STORAGE_SERVICE_SECRET", "")
+	if _, err := loadAuthConfig(); err == nil {
+		t.Fatalf("expected an error without STORAGE_AUTH_URL or STORAGE_SERVICE_SECRET")
+	}
+	t.Setenv("STORAGE_SERVICE_SECRET", "s3cret")
+	if _, err := loadAuthConfig(); err != nil {
+		t.Fatalf("loadAuthConfig: %v", err)
+	}
+}
+
+func TestResolveCaller_DoesNotTrustClientHeaders(t *testing.T) {
+	router := setupTestRouter(t, newMemoryStore())
+
+	get := func(req *http.Request) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/usage", nil)
+	req.Header.Set("X-User-ID", "mallory")
+	req.Header.Set("X-User-Role", "admin")
+	if code := get(req); code != http.StatusUnauthorized {
+		t.Fatalf("headers without the secret: expected 401, got %d", code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/usage/me", nil)
+	req.Header.Set("X-Service-Secret", "guess")
+	req.Header.Set("X-User-ID", "mallory")
+	if code := get(req); code != http.StatusUnauthorized {
+		t.Fatalf("wrong secret: expected 401, got %d", code)
+	}
+
+	req = asService(httptest.NewRequest(http.MethodGet, "/usage", nil), "mallory")
+	req.Header.Set("X-User-Role", "admin")
+	if code := get(req); code != http.StatusForbidden {
+		t.Fatalf("role header: expected 403, got %d", code)
+	}
+}
+
+func TestDelete_OnlyOwnerOrAdmin(t *testing.T) {
+	router := setupTestRouter(t, newMemoryStore())
+
+	req := newUploadRequest(t, "misc", "text/plain", []byte("notes"))
+	req.Header.Set("X-User-ID", "alice")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("upload: expected 200, got %d", w.Code)
+	}
+	var uploaded struct {
+		Filename string `json:"filename"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &uploaded)
+
+	del := func(req *http.Request) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := del(asService(httptest.NewRequest(http.MethodDelete, "/delete/"+uploaded.Filename, nil), "bob")); code != http.StatusForbidden {
+		t.Fatalf("other user: expected 403, got %d", code)
+	}
+	if got := usage.usageFor("alice", 0); got.Objects != 1 {
+		t.Fatalf("alice's usage should be untouched, got %+v", got)
+	}
+	if code := del(asService(httptest.NewRequest(http.MethodDelete, "/delete/untracked", nil), "bob")); code != http.StatusForbidden {
+		t.Fatalf("file without owner: expected 403, got %d", code)
+	}
+
+	auth.URL = fakeAuthService(t, "root", permStorageManage)
+	req = httptest.NewRequest(http.MethodDelete, "/delete/"+uploaded.Filename, nil)
+	req.Header.Set("Cookie", "session=root")
+	if code := del(req); code != http.StatusOK {
+		t.Fatalf("admin: expected 200, got %d", code)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int64{"1024": 1024, "10MB": 10 << 20, "1GiB": 1 << 30, "5k": 5 << 10}
+	for in, want := range cases {
+		got, err := parseSize(in)
+		if err != nil || got != want {
+			t.Fatalf("parseSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	if _, err := parseSize("lots"); err == nil {
+		t.Fatalf("parseSize(\"lots\") should fail")
+	}
+}
+
+func TestListFiles_FolderFilter(t *testing.T) {
+	s := newMemoryStore()
+	router := setupTestRouter(t, s)
+
+	ctx := t.Context()
+	s.Put(ctx, "images/a.png", bytes.NewReader([]byte("a")), 1, "image/png")
+	s.Put(ctx, "images/b.png", bytes.NewReader([]byte("b")), 1, "image/png")
+	s.Put(ctx, "docs/c.pdf", bytes.NewReader([]byte("c")), 1, "application/pdf")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/list?folder=images", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("list: expected 200, got %d", w.Code)
+	}
+
+	var resp struct {
+		Count int `json:"count"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode list response: %v", err)
+	}
+	if resp.Count != 2 {
+		t.Fatalf("list: expected 2 files under images/, got %d", resp.Count)
+	}
+}
+
+func TestListFiles_CursorPaginationAndFolders(t *testing.T) {
+	s := newMemoryStore()
+	router := setupTestRouter(t, s)
+
+	ctx := t.Context()
+	for _, key := range []string{"images/a.png", "images/b.png", "images/c.png", "images/sub/d.png", "images/sub/e.png"} {
+		s.Put(ctx, key, bytes.NewReader([]byte("xx")), 2, "image/png")
+	}
+
+	type listResponse struct {
+		Files []struct {
+			Name string `json:"name"`
+		} `json:"files"`
+		Folders    []string `json:"folders"`
+		TotalSize  int64    `json:"totalSize"`
+		NextCursor *string  `json:"nextCursor"`
+		Summary    *struct {
+			Objects   int   `json:"objects"`
+			TotalSize int64 `json:"totalSize"`
+		} `json:"summary"`
+	}
+	list := func(query string) listResponse {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/list?"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("list %q: expected 200, got %d: %s", query, w.Code, w.Body.String())
+		}
+		var resp listResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+
+	var names []string
+	query := "folder=images&limit=2"
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatalf("pagination did not terminate")
+		}
+		resp := list(query)
+		for _, f := range resp.Files {
+			names = append(names, f.Name)
+		}
+		if resp.NextCursor == nil {
+			break
+		}
+		query = "folder=images&limit=2&cursor=" + *resp.NextCursor
+	}
+	if len(names) != 5 {
+		t.Fatalf("expected 5 files across pages, got %v", names)
+	}
+
+	resp := list("folder=images&recursive=false&summary=true")
+	if len(resp.Files) != 3 || len(resp.Folders) != 1 || resp.Folders[0] != "images/sub/" {
+		t.Fatalf("non-recursive: unexpected files %v / folders %v", resp.Files, resp.Folders)
+	}
+	if resp.TotalSize != 6 || resp.Summary == nil || resp.Summary.Objects != 5 || resp.Summary.TotalSize != 10 {
+		t.Fatalf("unexpected size summary: page %d, summary %+v", resp.TotalSize, resp.Summary)
+	}
+
+	// Resuming after a folder skips everything inside it.
+	resp = list("folder=images&recursive=false&limit=3")
+	if len(resp.Files) != 3 || resp.NextCursor == nil {
+		t.Fatalf("expected three files and a cursor, got %+v", resp)
+	}
+	resp = list("folder=images&recursive=false&limit=1&cursor=" + *resp.NextCursor)
+	if len(resp.Folders) != 1 || resp.NextCursor != nil {
+		t.Fatalf("expected only the sub folder on the last page, got %+v", resp)
+	}
+	page := paginate([]ObjectInfo{{Key: "images/sub/d.png"}, {Key: "images/z.png"}},
+		ListOptions{Prefix: "images/", StartAfter: "images/sub/" + folderCursorSuffix})
+	if len(page.Objects) != 1 || page.Objects[0].Key != "images/z.png" {
+		t.Fatalf("expected only images/z.png after the folder cursor, got %+v", page.Objects)
+	}
+}
+
+func TestListFiles_SortedPages(t *testing.T) {
+	s := newMemoryStore()
+	router := setupTestRouter(t, s)
+
+	ctx := t.Context()
+	for name, size := range map[string]int{"a.txt": 3, "b.txt": 1, "c.txt": 2, "d.txt": 2, "e.txt": 5} {
+		s.Put(ctx, name, bytes.NewReader(bytes.Repeat([]byte("x"), size)), int64(size), "text/plain")
+	}
+
+	type listResponse struct {
+		Files []struct {
+			Name string `json:"name"`
+		} `json:"files"`
+		NextCursor *string `json:"nextCursor"`
+	}
+	list := func(query string) (*httptest.ResponseRecorder, listResponse) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/list?"+query, nil))
+		var resp listResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w, resp
+	}
+	pageThrough := func(query string, each func(names []string)) []string {
+		var names []string
+		cursor := ""
+		for {
+			w, resp := list(query + cursor)
+			if w.Code != http.StatusOK {
+				t.Fatalf("%s: expected 200, got %d: %s", query+cursor, w.Code, w.Body.String())
+			}
+			var page []string
+			for _, f := range resp.Files {
+				page = append(page, f.Name)
+			}
+			names = append(names, page...)
+			if each != nil {
+				each(page)
+			}
+			if resp.NextCursor == nil {
+				return names
+			}
+			cursor = "&cursor=" + *resp.NextCursor
+		}
+	}
+
+	for query, want := range map[string]string{
+		"sort=size&limit=2":            "b.txt c.txt d.txt a.txt e.txt",
+		"sort=size&order=desc&limit=2": "e.txt a.txt d.txt c.txt b.txt",
+		"order=desc&limit=3":           "e.txt d.txt c.txt b.txt a.txt",
+		"sort=name&limit=2":            "a.txt b.txt c.txt d.txt e.txt",
+	} {
+		if got := strings.Join(pageThrough(query, nil), " "); got != want {
+			t.Fatalf("%s: expected %s, got %s", query, want, got)
+		}
+	}
+
+	// Deleting the last file of a page must not lose or repeat files on the next.
+	got := pageThrough("sort=size&limit=2", func(page []string) {
+		s.Delete(ctx, page[len(page)-1])
+	})
+	if strings.Join(got, " ") != "b.txt c.txt d.txt a.txt e.txt" {
+		t.Fatalf("pages while deleting: unexpected %v", got)
+	}
+
+	s.Put(ctx, "a.txt", bytes.NewReader([]byte("aaa")), 3, "text/plain")
+	s.Put(ctx, "b.txt", bytes.NewReader([]byte("b")), 1, "text/plain")
+	_, resp := list("sort=size&limit=1")
+	if resp.NextCursor == nil {
+		t.Fatal("sort=size&limit=1: expected a next cursor")
+	}
+	for _, query := range []string{
+		"sort=lastModified&cursor=" + *resp.NextCursor,
+		"sort=size&order=desc&cursor=" + *resp.NextCursor,
+		"sort=size&cursor=" + *encodeCursor("a.txt"),
+	} {
+		if w, _ := list(query); w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestLocalStore_RoundTripAndTraversal(t *testing.T) {
+	s, err := newLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("newLocalStore: %v", err)
+	}
+	ctx := t.Context()
+
+	if _, err := s.Put(ctx, "images/x.txt", bytes.NewReader([]byte("xyz")), 3, "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	info, err := s.Stat(ctx, "images/x.txt")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Size != 3 || info.ContentType != "text/plain" {
+		t.Fatalf("Stat: unexpected info %+v", info)
+	}
+
+	page, err := s.List(ctx, ListOptions{Recursive: true})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(page.Objects) != 1 || page.Objects[0].Key != "images/x.txt" {
+		t.Fatalf("List: expected only images/x.txt, got %+v", page.Objects)
+	}
+
+	// Keys are rooted, so ".." cannot climb out of the storage directory.
+	dataPath, _, err := s.paths("../../etc/passwd")
+	if err != nil {
+		t.Fatalf("paths: %v", err)
+	}
+	if want := s.root + "/etc/passwd"; dataPath != want {
+		t.Fatalf("paths: expected %q, got %q", want, dataPath)
+	}
+
+	if _, err := s.Stat(ctx, "missing"); err != ErrObjectNotFound {
+		t.Fatalf("Stat missing: expected ErrObjectNotFound, got %v", err)
+	}
+}
+
+func TestLoadResumableLimits_MinioPartSize(t *testing.T) {
+	t.Setenv("STORAGE_UPLOAD_PART_SIZE", "4MB")
+	if _, err := loadResumableLimits(&minioStore{}); err == nil {
+		t.Fatalf("expected an error for parts below 5MB with the minio driver")
+	}
+	if _, err := loadResumableLimits(newMemoryStore()); err != nil {
+		t.Fatalf("loadResumableLimits(memory): %v", err)
+	}
+	t.Setenv("STORAGE_UPLOAD_PART_SIZE", "5MB")
+	if _, err := loadResumableLimits(&minioStore{}); err != nil {
+		t.Fatalf("loadResumableLimits(minio): %v", err)
+	}
+}
+
+func TestResumableUpload_ParallelParts(t *testing.T) {
+	t.Setenv("STORAGE_UPLOAD_PART_SIZE", "4")
+	router := setupTestRouter(t, newMemoryStore())
+	const parts = 32
+	content := strings.Repeat("abcd", parts)
+
+	req := asService(httptest.NewRequest(http.MethodPost, "/uploads",
+		bytes.NewBufferString(fmt.Sprintf(`{"folder":"misc","size":%d,"contentType":"text/plain"}`, len(content)))), "")
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("start: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var started map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &started)
+	base := "/uploads/" + started["id"].(string)
+
+	var wg sync.WaitGroup
+	codes := make([]int, parts)
+	for n := 1; n <= parts; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			body := bytes.NewBufferString(content[(n-1)*4 : n*4])
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, asService(httptest.NewRequest(http.MethodPut, fmt.Sprintf("%s/parts/%d", base, n), body), ""))
+			codes[n-1] = w.Code
+		}(n)
+	}
+	wg.Wait()
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Fatalf("part %d: expected 200, got %d", i+1, code)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, asService(httptest.NewRequest(http.MethodPost, base+"/complete", nil), ""))
+	if w.Code != http.StatusOK {
+		t.Fatalf("complete: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestResumableUpload_PartsOffsetAndComplete(t *testing.T) {
+	t.Setenv("STORAGE_UPLOAD_PART_SIZE", "4")
+	for name, s := range map[string]BlobStore{"memory": newMemoryStore(), "local": mustLocalStore(t)} {
+		t.Run(name, func(t *testing.T) {
+			router := setupTestRouter(t, s)
+			content := "hello world!"
+
+			do := func(method, url, body string) (int, map[string]interface{}) {
+				req := asService(httptest.NewRequest(method, url, bytes.NewBufferString(body)), "")
+				if method == http.MethodPost && url == "/uploads" {
+					req.Header.Set("Content-Type", "application/json")
+				}
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				var resp map[string]interface{}
+				json.Unmarshal(w.Body.Bytes(), &resp)
+				return w.Code, resp
+			}
+
+			code, started := do(http.MethodPost, "/uploads", `{"folder":"handouts","size":12,"contentType":"application/pdf"}`)
+			if code != http.StatusCreated {
+				t.Fatalf("start: expected 201, got %d: %v", code, started)
+			}
+			if started["totalParts"].(float64) != 3 {
+				t.Fatalf("start: expected 3 parts, got %v", started["totalParts"])
+			}
+			base := "/uploads/" + started["id"].(string)
+
+			// Declared as PDF, but the first bytes are plain text.
+			if code, _ := do(http.MethodPut, base+"/parts/1", content[0:4]); code != http.StatusUnsupportedMediaType {
+				t.Fatalf("part 1: expected 415 for text declared as PDF, got %d", code)
+			}
+			if code, _ := do(http.MethodDelete, base, ""); code != http.StatusOK {
+				t.Fatalf("abort: expected 200, got %d", code)
+			}
+
+			code, started = do(http.MethodPost, "/uploads", `{"folder":"misc","size":12,"contentType":"text/plain"}`)
+			if code != http.StatusCreated {
+				t.Fatalf("start: expected 201, got %d: %v", code, started)
+			}
+			base = "/uploads/" + started["id"].(string)
+
+			if code, _ := do(http.MethodPut, base+"/parts/1", content[0:4]); code != http.StatusOK {
+				t.Fatalf("part 1: expected 200, got %d", code)
+			}
+			if code, _ := do(http.MethodPut, base+"/parts/3", content[8:11]); code != http.StatusBadRequest {
+				t.Fatalf("part 3: expected 400 for wrong size, got %d", code)
+			}
+			if code, _ := do(http.MethodPut, base+"/parts/3", content[8:]); code != http.StatusOK {
+				t.Fatalf("part 3: expected 200, got %d", code)
+			}
+
+			_, status := do(http.MethodGet, base, "")
+			if status["offset"].(float64) != 4 {
+				t.Fatalf("offset: expected 4 with part 2 missing, got %v", status["offset"])
+			}
+			if code, _ := do(http.MethodPost, base+"/complete", ""); code != http.StatusConflict {
+				t.Fatalf("complete: expected 409 while incomplete, got %d", code)
+			}
+
+			do(http.MethodPut, base+"/parts/2", content[4:8])
+			code, done := do(http.MethodPost, base+"/complete", "")
+			if code != http.StatusOK {
+				t.Fatalf("complete: expected 200, got %d: %v", code, done)
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, done["url"].(string), nil))
+			if w.Body.String() != content {
+				t.Fatalf("download: expected %q, got %q", content, w.Body.String())
+			}
+			if code, _ := do(http.MethodGet, base, ""); code != http.StatusNotFound {
+				t.Fatalf("session should be gone after complete, got %d", code)
+			}
+		})
+	}
+}
+
+func TestCleanupExpiredUploads(t *testing.T) {
+	router := setupTestRouter(t, newMemoryStore())
+
+	req := asService(httptest.NewRequest(http.MethodPost, "/uploads", bytes.NewBufferString(`{"folder":"misc","size":3,"contentType":"text/plain"}`)), "")
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("start: expected 201, got %d", w.Code)
+	}
+
+	if n := cleanupExpiredUploads(t.Context(), time.Now()); n != 0 {
+		t.Fatalf("expected fresh upload to survive, removed %d", n)
+	}
+	if n := cleanupExpiredUploads(t.Context(), time.Now().Add(resumable.expiration+time.Minute)); n != 1 {
+		t.Fatalf("expected abandoned upload to be removed, removed %d", n)
+	}
+	if got := usage.usageFor(anonymousOwner, 0); got.Objects != 0 {
+		t.Fatalf("expected reserved quota to be released, got %+v", got)
+	}
+}
+
+func mustLocalStore(t *testing.T) *localStore {
+	t.Helper()
+	s, err := newLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("newLocalStore: %v", err)
+	}
+	return s
+}
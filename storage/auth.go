@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// anonymousOwner owns files uploaded by service calls made on behalf of no
+// particular user.
+const anonymousOwner = "anonymous"
+
+var errUnauthenticated = errors.New("authentication required")
+
+// permStorageManage is the backend permission to see and manage everyone's
+// files.
+const permStorageManage = "storage:manage"
+
+// caller identifies who is talking to the storage service.
+type caller struct {
+	ID string
+	// Permissions are the ones the backend reports for the user.
+	Permissions []string
+	// Service is set for calls made with the service secret but no X-User-ID,
+	// such as the backend's storage garbage collector.
+	Service bool
+}
+
+// authConfig says how callers are identified. At least one of URL and
+// ServiceSecret must be set; the service refuses to start otherwise.
+type authConfig struct {
+	// URL is a backend endpoint (e.g. http://localhost:8080/users/me) that receives
+	// the caller's Cookie and Authorization headers and returns the user.
+	URL string
+	// ServiceSecret lets other services act for a user by sending it in
+	// X-Service-Secret along with X-User-ID, or for themselves without one.
+	ServiceSecret string
+}
+
+var auth authConfig
+
+func loadAuthConfig() (authConfig, error) {
+	cfg := authConfig{
+		URL:           os.Getenv("STORAGE_AUTH_URL"),
+		ServiceSecret: os.Getenv("STORAGE_SERVICE_SECRET"),
+	}
+	if cfg.URL == "" && cfg.ServiceSecret == "" {
+		return cfg, errors.New("set STORAGE_AUTH_URL or STORAGE_SERVICE_SECRET so callers can be identified")
+	}
+	return cfg, nil
+}
+
+// can reports whether the caller has a backend permission. The calling service
+// itself can do everything.
+func (c *caller) can(permission string) bool {
+	if c.Service {
+		return true
+	}
+	for _, p := range c.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveCaller works out the caller's identity.
+//
+// A request carrying the service secret in X-Service-Secret is a trusted
+// service-to-service call for the user in X-User-ID, or for the service itself
+// when there is none. Any other request is
+// identified by forwarding its Cookie and Authorization headers to the auth URL.
+// Permissions only come from the auth service; X-User-Role is ignored.
+func resolveCaller(c *gin.Context) (*caller, error) {
+	if secret := c.GetHeader("X-Service-Secret"); secret != "" {
+		if auth.ServiceSecret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(auth.ServiceSecret)) != 1 {
+			return nil, errUnauthenticated
+		}
+		if id := c.GetHeader("X-User-ID"); id != "" {
+			return &caller{ID: id}, nil
+		}
+		return &caller{ID: anonymousOwner, Service: true}, nil
+	}
+
+	authURL := auth.URL
+	if authURL == "" {
+		return nil, errUnauthenticated
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, authURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cookie := c.GetHeader("Cookie"); cookie != "" {
+		req.Header.Set("Cookie", cookie)
+	}
+	if authz := c.GetHeader("Authorization"); authz != "" {
+		req.Header.Set("Authorization", authz)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return nil, errUnauthenticated
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("auth service returned " + resp.Status)
+	}
+
+	var body struct {
+		User struct {
+			ID string `json:"_id"`
+		} `json:"user"`
+		Permissions []string `json:"permissions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	if body.User.ID == "" {
+		return nil, errUnauthenticated
+	}
+
+	return &caller{ID: body.User.ID, Permissions: body.Permissions}, nil
+}
+
+// respondCallerError writes 401 for missing credentials and 502 when the auth service failed.
+func respondCallerError(c *gin.Context, err error) {
+	if errors.Is(err, errUnauthenticated) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to verify caller: " + err.Error()})
+}
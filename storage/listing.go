@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// listQuery holds the parsed query parameters of GET /list.
+type listQuery struct {
+	startAfter string
+	// after resumes a listing in another order than ascending name.
+	after     *sortCursor
+	limit     int
+	recursive bool
+	sort      string
+	desc      bool
+	summary   bool
+}
+
+// listSummary totals every object under a prefix, not just the current page.
+type listSummary struct {
+	Objects   int   `json:"objects"`
+	TotalSize int64 `json:"totalSize"`
+}
+
+func parseListQuery(c *gin.Context) (listQuery, error) {
+	q := listQuery{
+		limit:     defaultListLimit,
+		recursive: true,
+		sort:      "name",
+	}
+
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return q, errors.New("limit must be a positive integer")
+		}
+		if n > maxListLimit {
+			n = maxListLimit
+		}
+		q.limit = n
+	}
+
+	if v := c.Query("recursive"); v != "" {
+		recursive, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, errors.New("recursive must be true or false")
+		}
+		q.recursive = recursive
+	}
+
+	if v := c.Query("sort"); v != "" {
+		switch v {
+		case "name", "size", "lastModified":
+			q.sort = v
+		default:
+			return q, errors.New("sort must be one of name, size, lastModified")
+		}
+	}
+
+	switch strings.ToLower(c.Query("order")) {
+	case "", "asc":
+	case "desc":
+		q.desc = true
+	default:
+		return q, errors.New("order must be asc or desc")
+	}
+
+	q.summary, _ = strconv.ParseBool(c.Query("summary"))
+
+	if v := c.Query("cursor"); v != "" {
+		if !q.reorders() {
+			startAfter, err := decodeCursor(v)
+			if err != nil {
+				return q, err
+			}
+			q.startAfter = startAfter
+		} else {
+			after, err := decodeSortCursor(v)
+			if err != nil {
+				return q, err
+			}
+			if after.Sort != q.sort || after.Desc != q.desc {
+				return q, errors.New("cursor belongs to a listing with another sort or order")
+			}
+			q.after = after
+		}
+	}
+
+	return q, nil
+}
+
+// reorders reports whether the listing is returned in another order than the
+// ascending key order the stores page in.
+func (q listQuery) reorders() bool {
+	return q.sort != "name" || q.desc
+}
+
+// objectLess orders objects by the sort key, falling back to the object key so
+// that objects with the same size or modification time keep a stable order.
+func objectLess(a, b ObjectInfo, by string, desc bool) bool {
+	if desc {
+		a, b = b, a
+	}
+	switch by {
+	case "size":
+		if a.Size != b.Size {
+			return a.Size < b.Size
+		}
+	case "lastModified":
+		if !a.LastModified.Equal(b.LastModified) {
+			return a.LastModified.Before(b.LastModified)
+		}
+	}
+	return a.Key < b.Key
+}
+
+func sortObjects(objects []ObjectInfo, by string, desc bool) {
+	sort.Slice(objects, func(i, j int) bool { return objectLess(objects[i], objects[j], by, desc) })
+}
+
+// listSorted returns one page of a listing in another order than ascending
+// name. The stores only page by name, so it reads every object under prefix,
+// sorts them and resumes after the cursor's position in that order. Folders of
+// a non-recursive listing all come on the first page and do not count against
+// the limit.
+func listSorted(ctx context.Context, prefix string, q listQuery) (ListPage, *string, error) {
+	var all ListPage
+	opts := ListOptions{Prefix: prefix, Limit: maxListLimit, Recursive: q.recursive}
+	for {
+		page, err := store.List(ctx, opts)
+		if err != nil {
+			return ListPage{}, nil, err
+		}
+		all.Objects = append(all.Objects, page.Objects...)
+		if q.after == nil {
+			all.Folders = append(all.Folders, page.Folders...)
+		}
+		if page.NextStartAfter == "" {
+			break
+		}
+		opts.StartAfter = page.NextStartAfter
+	}
+
+	sortObjects(all.Objects, q.sort, q.desc)
+	if q.after != nil {
+		after := q.after.object()
+		start := sort.Search(len(all.Objects), func(i int) bool {
+			return objectLess(after, all.Objects[i], q.sort, q.desc)
+		})
+		all.Objects = all.Objects[start:]
+	}
+
+	var next *string
+	if len(all.Objects) > q.limit {
+		all.Objects = all.Objects[:q.limit]
+		next = encodeSortCursor(q, all.Objects[q.limit-1])
+	}
+	return all, next, nil
+}
+
+// sortCursor resumes a sorted listing after the object it names. It carries
+// the object's sort key as well as its name, so the next page starts at the
+// right place even if that object has been deleted in the meantime.
+type sortCursor struct {
+	Sort         string    `json:"sort"`
+	Desc         bool      `json:"desc,omitempty"`
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+func (s *sortCursor) object() ObjectInfo {
+	return ObjectInfo{Key: s.Key, Size: s.Size, LastModified: s.LastModified}
+}
+
+func encodeSortCursor(q listQuery, last ObjectInfo) *string {
+	raw, _ := json.Marshal(sortCursor{
+		Sort:         q.sort,
+		Desc:         q.desc,
+		Key:          last.Key,
+		Size:         last.Size,
+		LastModified: last.LastModified,
+	})
+	cursor := base64.RawURLEncoding.EncodeToString(raw)
+	return &cursor
+}
+
+func decodeSortCursor(cursor string) (*sortCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	var after sortCursor
+	if err := json.Unmarshal(raw, &after); err != nil || after.Key == "" {
+		return nil, errors.New("invalid cursor")
+	}
+	return &after, nil
+}
+
+// summarizePrefix walks every page under prefix to total object count and size.
+func summarizePrefix(ctx context.Context, prefix string) (listSummary, error) {
+	var summary listSummary
+	opts := ListOptions{Prefix: prefix, Limit: maxListLimit, Recursive: true}
+	for {
+		page, err := store.List(ctx, opts)
+		if err != nil {
+			return summary, err
+		}
+		for _, object := range page.Objects {
+			summary.Objects++
+			summary.TotalSize += object.Size
+		}
+		if page.NextStartAfter == "" {
+			return summary, nil
+		}
+		opts.StartAfter = page.NextStartAfter
+	}
+}
+
+// encodeCursor turns a resume key into an opaque cursor; an empty key means no more pages.
+func encodeCursor(startAfter string) *string {
+	if startAfter == "" {
+		return nil
+	}
+	cursor := base64.RawURLEncoding.EncodeToString([]byte(startAfter))
+	return &cursor
+}
+
+func decodeCursor(cursor string) (string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", errors.New("invalid cursor")
+	}
+	return string(raw), nil
+}
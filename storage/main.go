@@ -1,7 +1,6 @@
 package main
 
 import (
-	"context"
 	"fmt"
 	"log"
 	"os"
@@ -9,82 +8,54 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
-	"github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
 )
 
-var minioClient *minio.Client
-
 func main() {
 	if err := godotenv.Load(); err != nil {
 		log.Println(".env not found, using environment variables")
 	}
 
-	endpoint := os.Getenv("MINIO_ENDPOINT")
-	if endpoint == "" {
-		endpoint = "localhost:9000"
-	}
-
-	accessKeyID := os.Getenv("MINIO_USER")
-	secretAccessKey := os.Getenv("MINIO_PASSWORD")
-	useSSL := os.Getenv("MINIO_USE_SSL")
-	secure := useSSL == "true"
-
-	client, err := minio.New(endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(accessKeyID, secretAccessKey, ""),
-		Secure: secure,
-	})
+	s, err := newStoreFromEnv()
 	if err != nil {
-		log.Fatalf("Failed to initialize MinIO client: %v", err)
+		log.Fatalf("Failed to initialize storage backend: %v", err)
 	}
 
-	minioClient = client
-
-	// Test connection with retry logic
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	store = s
 
-	fmt.Printf("Attempting to connect to MinIO at %s...\n", endpoint)
-
-	maxRetries := 5
-	retryDelay := 2 * time.Second
-	var lastErr error
+	if auth, err = loadAuthConfig(); err != nil {
+		log.Fatalf("Failed to load auth settings: %v", err)
+	}
 
-	for i := 0; i < maxRetries; i++ {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		_, err = client.ListBuckets(ctx)
-		cancel()
+	if policy, err = loadUploadPolicy(); err != nil {
+		log.Fatalf("Failed to load upload policy: %v", err)
+	}
 
-		if err == nil {
-			fmt.Println("Connected to MinIO successfully")
-			break
-		}
+	if usage, err = newUsageStore(stateFilePath("STORAGE_USAGE_FILE", "usage.json")); err != nil {
+		log.Fatalf("Failed to load usage index: %v", err)
+	}
 
-		lastErr = err
-		if i < maxRetries-1 {
-			fmt.Printf("Connection attempt %d/%d failed: %v. Retrying in %v...\n", i+1, maxRetries, err, retryDelay)
-			time.Sleep(retryDelay)
-		}
+	if resumable, err = loadResumableLimits(store); err != nil {
+		log.Fatalf("Failed to load resumable upload settings: %v", err)
 	}
 
-	if lastErr != nil {
-		log.Fatalf("Failed to connect to MinIO after %d attempts: %v\n\n"+
-			"Please ensure MinIO is running. You can start it with:\n"+
-			"  1. Docker Compose: cd storage && docker-compose up -d\n"+
-			"  2. Docker: docker run -d -p 9000:9000 -p 9001:9001 -e MINIO_ROOT_USER=minioadmin -e MINIO_PASSWORD=minioadmin minio/minio server /data --console-address \":9001\"\n"+
-			"  3. Or set MINIO_ENDPOINT to point to your MinIO instance", maxRetries, lastErr)
+	if uploadSessions, err = newUploadSessionStore(stateFilePath("STORAGE_UPLOADS_FILE", "uploads.json")); err != nil {
+		log.Fatalf("Failed to load upload sessions: %v", err)
 	}
 
-	// Ensure default bucket exists
-	bucketName := os.Getenv("MINIO_BUCKET_NAME")
+	startUploadCleanup(time.Hour)
 
-	err = ensureBucketExists(ctx, bucketName)
-	if err != nil {
-		log.Fatalf("Failed to create bucket: %v", err)
-	}
+	router := newRouter()
+
+	port := os.Getenv("STORAGE_PORT")
 
-	fmt.Printf("Bucket '%s' is ready\n", bucketName)
+	fmt.Printf("Storage service starting on port %s\n", port)
+	if err := router.Run(":" + port); err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+}
 
+// newRouter builds the Gin engine with CORS and all storage endpoints registered.
+func newRouter() *gin.Engine {
 	router := gin.Default()
 
 	// CORS middleware
@@ -115,28 +86,27 @@ func main() {
 	router.GET("/download/*filepath", downloadFile)
 	router.DELETE("/delete/*filepath", deleteFile)
 	router.GET("/list", listFiles)
+	router.GET("/usage", listUsage)
+	router.GET("/usage/me", getMyUsage)
 
-	port := os.Getenv("STORAGE_PORT")
+	// Resumable uploads
+	router.POST("/uploads", startUpload)
+	router.GET("/uploads/:id", getUpload)
+	router.PUT("/uploads/:id/parts/:number", putUploadPart)
+	router.POST("/uploads/:id/complete", completeUpload)
+	router.DELETE("/uploads/:id", abortUpload)
 
-	fmt.Printf("Storage service starting on port %s\n", port)
-	if err := router.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
-	}
+	return router
 }
 
-func ensureBucketExists(ctx context.Context, bucketName string) error {
-	exists, err := minioClient.BucketExists(ctx, bucketName)
-	if err != nil {
-		return err
+// stateFilePath is where service state such as the usage index is persisted. The
+// memory driver keeps that state in memory too so a throwaway run leaves nothing behind.
+func stateFilePath(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
 	}
-
-	if !exists {
-		err = minioClient.MakeBucket(ctx, bucketName, minio.MakeBucketOptions{})
-		if err != nil {
-			return err
-		}
-		log.Printf("Created bucket: %s\n", bucketName)
+	if os.Getenv("STORAGE_DRIVER") == "memory" {
+		return ""
 	}
-
-	return nil
+	return fallback
 }
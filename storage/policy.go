@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultAllowedTypes applies when STORAGE_ALLOWED_TYPES is not set.
+const defaultAllowedTypes = "images/=image/png,image/jpeg,image/gif,image/webp;" +
+	"handouts/=application/pdf,image/png,image/jpeg,image/gif,image/webp;" +
+	"*=*"
+
+// policy holds the upload rules applied by uploadFile.
+var policy *uploadPolicy
+
+// folderPolicy lists the MIME types accepted under a folder prefix.
+type folderPolicy struct {
+	prefix string
+	types  []string
+}
+
+// uploadPolicy decides which content may be stored where.
+type uploadPolicy struct {
+	folders       []folderPolicy
+	maxUploadSize int64
+	userQuota     int64
+}
+
+// loadUploadPolicy reads folder type rules and size limits from the environment.
+//
+// STORAGE_ALLOWED_TYPES has the form "folder/=type,type;other/=type;*=*", where a
+// type may end in "/*" and "*" as folder is the fallback for everything else.
+func loadUploadPolicy() (*uploadPolicy, error) {
+	rules := os.Getenv("STORAGE_ALLOWED_TYPES")
+	if rules == "" {
+		rules = defaultAllowedTypes
+	}
+
+	p := &uploadPolicy{}
+	for _, rule := range strings.Split(rules, ";") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		folder, types, ok := strings.Cut(rule, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid STORAGE_ALLOWED_TYPES rule %q", rule)
+		}
+		folder = strings.TrimSpace(folder)
+		if folder != "*" {
+			folder = strings.Trim(folder, "/") + "/"
+		}
+		fp := folderPolicy{prefix: folder}
+		for _, t := range strings.Split(types, ",") {
+			if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+				fp.types = append(fp.types, t)
+			}
+		}
+		p.folders = append(p.folders, fp)
+	}
+
+	// Longest prefix first so the most specific folder wins; the "*" fallback goes last.
+	sort.SliceStable(p.folders, func(i, j int) bool {
+		if p.folders[i].prefix == "*" || p.folders[j].prefix == "*" {
+			return p.folders[j].prefix == "*" && p.folders[i].prefix != "*"
+		}
+		return len(p.folders[i].prefix) > len(p.folders[j].prefix)
+	})
+
+	var err error
+	if p.maxUploadSize, err = envSize("STORAGE_MAX_UPLOAD_SIZE", 10<<20); err != nil {
+		return nil, err
+	}
+	if p.userQuota, err = envSize("STORAGE_USER_QUOTA", 100<<20); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// allows reports whether contentType may be stored under objectName.
+func (p *uploadPolicy) allows(objectName, contentType string) bool {
+	mediaType := mediaTypeOf(contentType)
+	for _, fp := range p.folders {
+		if fp.prefix != "*" && !strings.HasPrefix(objectName, fp.prefix) {
+			continue
+		}
+		for _, t := range fp.types {
+			if t == "*" || t == mediaType {
+				return true
+			}
+			if strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(t, "*")) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// cleanFolder turns the folder of an upload into a key prefix ending in "/", or
+// "" for the root. Folders with ".." segments are rejected so that an upload
+// cannot match one folder's policy while being stored in another.
+func cleanFolder(raw string) (string, error) {
+	for _, segment := range strings.Split(raw, "/") {
+		if segment == ".." {
+			return "", fmt.Errorf("folder %q must not contain '..'", raw)
+		}
+	}
+	folder := strings.Trim(path.Clean("/"+raw), "/")
+	if folder == "" {
+		return "", nil
+	}
+	return folder + "/", nil
+}
+
+// mediaTypeOf strips parameters such as charset from a content type.
+func mediaTypeOf(contentType string) string {
+	return strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+}
+
+// servedInline reports whether downloadFile may let the browser display a file
+// of contentType. Anything that can run script, such as HTML, SVG or XML, is
+// sent as an attachment instead so it never executes on this origin.
+func servedInline(contentType string) bool {
+	mediaType := mediaTypeOf(contentType)
+	switch {
+	case mediaType == "image/svg+xml":
+		return false
+	case strings.HasPrefix(mediaType, "image/"), strings.HasPrefix(mediaType, "audio/"), strings.HasPrefix(mediaType, "video/"):
+		return true
+	}
+	return mediaType == "application/pdf" || mediaType == "text/plain"
+}
+
+// sniffContentType detects the content type from the leading bytes of r and
+// returns a reader that still yields the complete stream.
+func sniffContentType(r io.Reader) (string, io.Reader, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", nil, err
+	}
+	head = head[:n]
+
+	return http.DetectContentType(head), io.MultiReader(bytes.NewReader(head), r), nil
+}
+
+// envSize parses a byte size such as "10485760", "10MB" or "1GiB".
+func envSize(key string, fallback int64) (int64, error) {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return fallback, nil
+	}
+	n, err := parseSize(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return n, nil
+}
+
+func parseSize(v string) (int64, error) {
+	units := []struct {
+		suffix string
+		mult   int64
+	}{
+		{"gib", 1 << 30}, {"mib", 1 << 20}, {"kib", 1 << 10},
+		{"gb", 1 << 30}, {"mb", 1 << 20}, {"kb", 1 << 10},
+		{"g", 1 << 30}, {"m", 1 << 20}, {"k", 1 << 10},
+		{"b", 1},
+	}
+	lower := strings.ToLower(strings.TrimSpace(v))
+	mult := int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(lower, u.suffix) {
+			lower = strings.TrimSpace(strings.TrimSuffix(lower, u.suffix))
+			mult = u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseInt(lower, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not a valid size", v)
+	}
+	return n * mult, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrObjectNotFound is returned by blob stores when the requested key does not exist.
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectInfo describes a stored object independently of the backing driver.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+	ContentType  string
+}
+
+// BlobStore is the storage backend used by the HTTP handlers.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (ObjectInfo, error)
+	Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error)
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+	List(ctx context.Context, opts ListOptions) (ListPage, error)
+
+	// Multipart uploads assemble an object from separately uploaded parts.
+	NewMultipartUpload(ctx context.Context, key string, contentType string) (string, error)
+	PutPart(ctx context.Context, key string, uploadID string, number int, r io.Reader, size int64) (UploadedPart, error)
+	CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []UploadedPart) (ObjectInfo, error)
+	AbortMultipartUpload(ctx context.Context, key string, uploadID string) error
+}
+
+// UploadedPart identifies one stored part of a multipart upload.
+type UploadedPart struct {
+	Number int    `json:"number"`
+	ETag   string `json:"etag"`
+	Size   int64  `json:"size"`
+}
+
+// ListOptions selects one page of a listing. Pages always advance in key order.
+type ListOptions struct {
+	Prefix string
+	// StartAfter resumes the listing after this key.
+	StartAfter string
+	// Limit caps the number of entries (objects plus folders); 0 means no limit.
+	Limit int
+	// Recursive lists every key under Prefix; otherwise deeper keys are folded into folders.
+	Recursive bool
+}
+
+// ListPage is one page of objects and, for non-recursive listings, folder prefixes.
+type ListPage struct {
+	Objects []ObjectInfo
+	Folders []string
+	// NextStartAfter is set when more entries remain and is passed back as StartAfter.
+	NextStartAfter string
+}
+
+// folderCursorSuffix sorts after any real key inside a folder, so resuming after
+// "images/a/" + suffix skips the whole folder instead of returning it again.
+const folderCursorSuffix = "\U0010FFFF"
+
+// store is the blob store shared by all handlers.
+var store BlobStore
+
+// newStoreFromEnv builds the blob store selected by STORAGE_DRIVER (minio, local or memory).
+func newStoreFromEnv() (BlobStore, error) {
+	driver := strings.ToLower(os.Getenv("STORAGE_DRIVER"))
+	switch driver {
+	case "", "minio", "s3":
+		return newMinioStoreFromEnv()
+	case "local":
+		dir := os.Getenv("STORAGE_LOCAL_DIR")
+		if dir == "" {
+			dir = "./data"
+		}
+		return newLocalStore(dir)
+	case "memory":
+		return newMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_DRIVER %q (expected minio, local or memory)", driver)
+	}
+}
+
+// paginate applies ListOptions to a full set of objects. Drivers without native
+// paging (local, memory) use it after collecting every key under the prefix.
+func paginate(objects []ObjectInfo, opts ListOptions) ListPage {
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+
+	var page ListPage
+	seenFolders := make(map[string]bool)
+	entries := 0
+	last := ""
+
+	for _, object := range objects {
+		if !strings.HasPrefix(object.Key, opts.Prefix) || object.Key <= opts.StartAfter {
+			continue
+		}
+
+		folder := ""
+		if !opts.Recursive {
+			rest := strings.TrimPrefix(object.Key, opts.Prefix)
+			if i := strings.Index(rest, "/"); i >= 0 {
+				folder = opts.Prefix + rest[:i+1]
+			}
+		}
+		if folder != "" && seenFolders[folder] {
+			continue
+		}
+
+		if opts.Limit > 0 && entries == opts.Limit {
+			page.NextStartAfter = last
+			break
+		}
+
+		if folder != "" {
+			seenFolders[folder] = true
+			page.Folders = append(page.Folders, folder)
+			last = folder + folderCursorSuffix
+		} else {
+			page.Objects = append(page.Objects, object)
+			last = object.Key
+		}
+		entries++
+	}
+
+	return page
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// metaDirName holds per-object metadata (content type) next to the stored files.
+const metaDirName = ".meta"
+
+// localStore keeps objects as plain files under a root directory.
+type localStore struct {
+	root string
+}
+
+type localMeta struct {
+	ContentType string `json:"contentType"`
+}
+
+func newLocalStore(dir string) (*localStore, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(root, metaDirName), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	fmt.Printf("Using local storage directory '%s'\n", root)
+
+	return &localStore{root: root}, nil
+}
+
+// paths resolves a key to its data and metadata file, refusing keys that escape the root.
+func (s *localStore) paths(key string) (string, string, error) {
+	clean := filepath.Clean("/" + filepath.FromSlash(key))
+	if clean == string(filepath.Separator) {
+		return "", "", fmt.Errorf("invalid object key %q", key)
+	}
+	rel := strings.TrimPrefix(clean, string(filepath.Separator))
+	if rel == metaDirName || strings.HasPrefix(rel, metaDirName+string(filepath.Separator)) {
+		return "", "", fmt.Errorf("invalid object key %q", key)
+	}
+
+	return filepath.Join(s.root, rel), filepath.Join(s.root, metaDirName, rel+".json"), nil
+}
+
+func (s *localStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (ObjectInfo, error) {
+	dataPath, metaPath, err := s.paths(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(dataPath), 0o755); err != nil {
+		return ObjectInfo{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(metaPath), 0o755); err != nil {
+		return ObjectInfo{}, err
+	}
+
+	// Write to a temp file first so readers never observe a partial object.
+	tmp, err := os.CreateTemp(filepath.Dir(dataPath), ".upload-*")
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return ObjectInfo{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return ObjectInfo{}, err
+	}
+	if err := os.Rename(tmp.Name(), dataPath); err != nil {
+		return ObjectInfo{}, err
+	}
+
+	meta, err := json.Marshal(localMeta{ContentType: contentType})
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	if err := os.WriteFile(metaPath, meta, 0o644); err != nil {
+		return ObjectInfo{}, err
+	}
+
+	return s.Stat(ctx, key)
+}
+
+func (s *localStore) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	info, err := s.Stat(ctx, key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+
+	dataPath, _, _ := s.paths(key)
+	f, err := os.Open(dataPath)
+	if err != nil {
+		return nil, ObjectInfo{}, localError(err)
+	}
+
+	return f, info, nil
+}
+
+func (s *localStore) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	dataPath, metaPath, err := s.paths(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	fi, err := os.Stat(dataPath)
+	if err != nil {
+		return ObjectInfo{}, localError(err)
+	}
+	if fi.IsDir() {
+		return ObjectInfo{}, ErrObjectNotFound
+	}
+
+	contentType := "application/octet-stream"
+	if raw, err := os.ReadFile(metaPath); err == nil {
+		var meta localMeta
+		if json.Unmarshal(raw, &meta) == nil && meta.ContentType != "" {
+			contentType = meta.ContentType
+		}
+	}
+
+	return ObjectInfo{
+		Key:          key,
+		Size:         fi.Size(),
+		LastModified: fi.ModTime().UTC(),
+		ContentType:  contentType,
+	}, nil
+}
+
+func (s *localStore) Delete(ctx context.Context, key string) error {
+	dataPath, metaPath, err := s.paths(key)
+	if err != nil {
+		return err
+	}
+
+	// Match S3 semantics: deleting a missing key is not an error.
+	if err := os.Remove(dataPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.Remove(metaPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *localStore) List(ctx context.Context, opts ListOptions) (ListPage, error) {
+	var objects []ObjectInfo
+
+	err := filepath.WalkDir(s.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == metaDirName && filepath.Dir(path) == s.root {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.root, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, opts.Prefix) || key <= opts.StartAfter {
+			return nil
+		}
+
+		info, err := s.Stat(ctx, key)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, info)
+		return nil
+	})
+	if err != nil {
+		return ListPage{}, err
+	}
+
+	return paginate(objects, opts), nil
+}
+
+// uploadDir holds the parts and descriptor of an in-progress multipart upload.
+func (s *localStore) uploadDir(uploadID string) (string, error) {
+	if uploadID == "" || strings.ContainsAny(uploadID, `/\.`) {
+		return "", ErrUploadNotFound
+	}
+	return filepath.Join(s.root, metaDirName, "uploads", uploadID), nil
+}
+
+type localUpload struct {
+	Key         string `json:"key"`
+	ContentType string `json:"contentType"`
+}
+
+func (s *localStore) readUpload(key, uploadID string) (string, localUpload, error) {
+	dir, err := s.uploadDir(uploadID)
+	if err != nil {
+		return "", localUpload{}, err
+	}
+	raw, err := os.ReadFile(filepath.Join(dir, "upload.json"))
+	if err != nil {
+		return "", localUpload{}, ErrUploadNotFound
+	}
+	var upload localUpload
+	if err := json.Unmarshal(raw, &upload); err != nil {
+		return "", localUpload{}, err
+	}
+	if upload.Key != key {
+		return "", localUpload{}, ErrUploadNotFound
+	}
+	return dir, upload, nil
+}
+
+func (s *localStore) NewMultipartUpload(ctx context.Context, key string, contentType string) (string, error) {
+	if _, _, err := s.paths(key); err != nil {
+		return "", err
+	}
+
+	id := newRandomID()
+	dir, _ := s.uploadDir(id)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	raw, err := json.Marshal(localUpload{Key: key, ContentType: contentType})
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "upload.json"), raw, 0o644); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (s *localStore) PutPart(ctx context.Context, key string, uploadID string, number int, r io.Reader, size int64) (UploadedPart, error) {
+	dir, _, err := s.readUpload(key, uploadID)
+	if err != nil {
+		return UploadedPart{}, err
+	}
+
+	partPath := filepath.Join(dir, fmt.Sprintf("part-%05d", number))
+	tmp, err := os.CreateTemp(dir, ".part-*")
+	if err != nil {
+		return UploadedPart{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := md5.New()
+	n, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if err != nil {
+		tmp.Close()
+		return UploadedPart{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return UploadedPart{}, err
+	}
+	if err := os.Rename(tmp.Name(), partPath); err != nil {
+		return UploadedPart{}, err
+	}
+
+	return UploadedPart{Number: number, ETag: hex.EncodeToString(hash.Sum(nil)), Size: n}, nil
+}
+
+func (s *localStore) CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []UploadedPart) (ObjectInfo, error) {
+	dir, upload, err := s.readUpload(key, uploadID)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	var readers []io.Reader
+	var size int64
+	for _, part := range parts {
+		f, err := os.Open(filepath.Join(dir, fmt.Sprintf("part-%05d", part.Number)))
+		if err != nil {
+			return ObjectInfo{}, ErrUploadNotFound
+		}
+		defer f.Close()
+		readers = append(readers, f)
+		size += part.Size
+	}
+
+	info, err := s.Put(ctx, key, io.MultiReader(readers...), size, upload.ContentType)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	return info, os.RemoveAll(dir)
+}
+
+func (s *localStore) AbortMultipartUpload(ctx context.Context, key string, uploadID string) error {
+	dir, _, err := s.readUpload(key, uploadID)
+	if errors.Is(err, ErrUploadNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+func localError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrObjectNotFound
+	}
+	return err
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryStore keeps objects in process memory. It is meant for tests and throwaway dev runs.
+type memoryStore struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+	uploads map[string]*memoryUpload
+}
+
+type memoryUpload struct {
+	key         string
+	contentType string
+	parts       map[int][]byte
+}
+
+type memoryObject struct {
+	data []byte
+	info ObjectInfo
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		objects: make(map[string]memoryObject),
+		uploads: make(map[string]*memoryUpload),
+	}
+}
+
+func (s *memoryStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (ObjectInfo, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	info := ObjectInfo{
+		Key:          key,
+		Size:         int64(len(data)),
+		LastModified: time.Now().UTC(),
+		ContentType:  contentType,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = memoryObject{data: data, info: info}
+
+	return info, nil
+}
+
+func (s *memoryStore) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	obj, ok := s.objects[key]
+	if !ok {
+		return nil, ObjectInfo{}, ErrObjectNotFound
+	}
+
+	return io.NopCloser(bytes.NewReader(obj.data)), obj.info, nil
+}
+
+func (s *memoryStore) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	obj, ok := s.objects[key]
+	if !ok {
+		return ObjectInfo{}, ErrObjectNotFound
+	}
+
+	return obj.info, nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, key)
+	return nil
+}
+
+func (s *memoryStore) List(ctx context.Context, opts ListOptions) (ListPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var objects []ObjectInfo
+	for key, obj := range s.objects {
+		if strings.HasPrefix(key, opts.Prefix) {
+			objects = append(objects, obj.info)
+		}
+	}
+
+	return paginate(objects, opts), nil
+}
+
+func (s *memoryStore) NewMultipartUpload(ctx context.Context, key string, contentType string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := newRandomID()
+	s.uploads[id] = &memoryUpload{key: key, contentType: contentType, parts: make(map[int][]byte)}
+	return id, nil
+}
+
+func (s *memoryStore) PutPart(ctx context.Context, key string, uploadID string, number int, r io.Reader, size int64) (UploadedPart, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return UploadedPart{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	upload, ok := s.uploads[uploadID]
+	if !ok || upload.key != key {
+		return UploadedPart{}, ErrUploadNotFound
+	}
+	upload.parts[number] = data
+
+	return UploadedPart{Number: number, ETag: partETag(data), Size: int64(len(data))}, nil
+}
+
+func (s *memoryStore) CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []UploadedPart) (ObjectInfo, error) {
+	s.mu.Lock()
+	upload, ok := s.uploads[uploadID]
+	if !ok || upload.key != key {
+		s.mu.Unlock()
+		return ObjectInfo{}, ErrUploadNotFound
+	}
+	var buf bytes.Buffer
+	for _, part := range parts {
+		data, ok := upload.parts[part.Number]
+		if !ok {
+			s.mu.Unlock()
+			return ObjectInfo{}, ErrUploadNotFound
+		}
+		buf.Write(data)
+	}
+	delete(s.uploads, uploadID)
+	s.mu.Unlock()
+
+	return s.Put(ctx, key, &buf, int64(buf.Len()), upload.contentType)
+}
+
+func (s *memoryStore) AbortMultipartUpload(ctx context.Context, key string, uploadID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.uploads, uploadID)
+	return nil
+}
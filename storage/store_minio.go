@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+type minioStore struct {
+	client *minio.Client
+	bucket string
+}
+
+func newMinioStoreFromEnv() (*minioStore, error) {
+	endpoint := os.Getenv("MINIO_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "localhost:9000"
+	}
+
+	accessKeyID := os.Getenv("MINIO_USER")
+	secretAccessKey := os.Getenv("MINIO_PASSWORD")
+	useSSL := os.Getenv("MINIO_USE_SSL")
+	secure := useSSL == "true"
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKeyID, secretAccessKey, ""),
+		Secure: secure,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize MinIO client: %w", err)
+	}
+
+	// Test connection with retry logic
+	fmt.Printf("Attempting to connect to MinIO at %s...\n", endpoint)
+
+	maxRetries := 5
+	retryDelay := 2 * time.Second
+	var lastErr error
+
+	for i := 0; i < maxRetries; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err = client.ListBuckets(ctx)
+		cancel()
+
+		if err == nil {
+			fmt.Println("Connected to MinIO successfully")
+			lastErr = nil
+			break
+		}
+
+		lastErr = err
+		if i < maxRetries-1 {
+			fmt.Printf("Connection attempt %d/%d failed: %v. Retrying in %v...\n", i+1, maxRetries, err, retryDelay)
+			time.Sleep(retryDelay)
+		}
+	}
+
+	if lastErr != nil {
+		return nil, fmt.Errorf("failed to connect to MinIO after %d attempts: %w\n\n"+
+			"Please ensure MinIO is running. You can start it with:\n"+
+			"  1. Docker Compose: cd storage && docker-compose up -d\n"+
+			"  2. Docker: docker run -d -p 9000:9000 -p 9001:9001 -e MINIO_ROOT_USER=minioadmin -e MINIO_PASSWORD=minioadmin minio/minio server /data --console-address \":9001\"\n"+
+			"  3. Or set MINIO_ENDPOINT to point to your MinIO instance\n"+
+			"  4. Or set STORAGE_DRIVER=local to store files on disk", maxRetries, lastErr)
+	}
+
+	bucketName := os.Getenv("MINIO_BUCKET_NAME")
+	if bucketName == "" {
+		bucketName = "fate-vault"
+	}
+
+	s := &minioStore{client: client, bucket: bucketName}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := s.ensureBucketExists(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create bucket: %w", err)
+	}
+
+	fmt.Printf("Bucket '%s' is ready\n", bucketName)
+
+	return s, nil
+}
+
+func (s *minioStore) ensureBucketExists(ctx context.Context) error {
+	exists, err := s.client.BucketExists(ctx, s.bucket)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		err = s.client.MakeBucket(ctx, s.bucket, minio.MakeBucketOptions{})
+		if err != nil {
+			return err
+		}
+		log.Printf("Created bucket: %s\n", s.bucket)
+	}
+
+	return nil
+}
+
+func (s *minioStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (ObjectInfo, error) {
+	info, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	return ObjectInfo{
+		Key:          key,
+		Size:         info.Size,
+		LastModified: info.LastModified,
+		ContentType:  contentType,
+	}, nil
+}
+
+func (s *minioStore) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, ObjectInfo{}, minioError(err)
+	}
+
+	objInfo, err := object.Stat()
+	if err != nil {
+		object.Close()
+		return nil, ObjectInfo{}, minioError(err)
+	}
+
+	return object, objectInfoFromMinio(objInfo), nil
+}
+
+func (s *minioStore) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	objInfo, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return ObjectInfo{}, minioError(err)
+	}
+
+	return objectInfoFromMinio(objInfo), nil
+}
+
+func (s *minioStore) Delete(ctx context.Context, key string) error {
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (s *minioStore) List(ctx context.Context, opts ListOptions) (ListPage, error) {
+	// Cancel the listing once the page is full so MinIO stops fetching further keys.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	objectCh := s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
+		Prefix:     opts.Prefix,
+		StartAfter: opts.StartAfter,
+		Recursive:  opts.Recursive,
+	})
+
+	var page ListPage
+	entries := 0
+	last := ""
+	for object := range objectCh {
+		if object.Err != nil {
+			return ListPage{}, object.Err
+		}
+		if opts.Limit > 0 && entries == opts.Limit {
+			page.NextStartAfter = last
+			break
+		}
+
+		// Non-recursive listings report common prefixes as keys ending in "/".
+		if !opts.Recursive && strings.HasSuffix(object.Key, "/") {
+			page.Folders = append(page.Folders, object.Key)
+			last = object.Key + folderCursorSuffix
+		} else {
+			page.Objects = append(page.Objects, objectInfoFromMinio(object))
+			last = object.Key
+		}
+		entries++
+	}
+
+	return page, nil
+}
+
+func (s *minioStore) core() minio.Core {
+	return minio.Core{Client: s.client}
+}
+
+func (s *minioStore) NewMultipartUpload(ctx context.Context, key string, contentType string) (string, error) {
+	return s.core().NewMultipartUpload(ctx, s.bucket, key, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+}
+
+func (s *minioStore) PutPart(ctx context.Context, key string, uploadID string, number int, r io.Reader, size int64) (UploadedPart, error) {
+	part, err := s.core().PutObjectPart(ctx, s.bucket, key, uploadID, number, r, size, minio.PutObjectPartOptions{})
+	if err != nil {
+		return UploadedPart{}, err
+	}
+
+	return UploadedPart{Number: part.PartNumber, ETag: part.ETag, Size: part.Size}, nil
+}
+
+func (s *minioStore) CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []UploadedPart) (ObjectInfo, error) {
+	completeParts := make([]minio.CompletePart, 0, len(parts))
+	for _, part := range parts {
+		completeParts = append(completeParts, minio.CompletePart{PartNumber: part.Number, ETag: part.ETag})
+	}
+
+	if _, err := s.core().CompleteMultipartUpload(ctx, s.bucket, key, uploadID, completeParts, minio.PutObjectOptions{}); err != nil {
+		return ObjectInfo{}, err
+	}
+
+	return s.Stat(ctx, key)
+}
+
+func (s *minioStore) AbortMultipartUpload(ctx context.Context, key string, uploadID string) error {
+	return s.core().AbortMultipartUpload(ctx, s.bucket, key, uploadID)
+}
+
+func objectInfoFromMinio(info minio.ObjectInfo) ObjectInfo {
+	return ObjectInfo{
+		Key:          info.Key,
+		Size:         info.Size,
+		LastModified: info.LastModified,
+		ContentType:  info.ContentType,
+	}
+}
+
+// minioError maps MinIO "missing key" responses onto ErrObjectNotFound.
+func minioError(err error) error {
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return ErrObjectNotFound
+	}
+	return err
+}
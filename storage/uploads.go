@@ -0,0 +1,543 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrUploadNotFound is returned for unknown, finished or aborted multipart uploads.
+var ErrUploadNotFound = errors.New("upload not found")
+
+// uploadSession tracks one resumable upload between start and complete/abort.
+type uploadSession struct {
+	ID          string               `json:"id"`
+	UploadID    string               `json:"uploadId"`
+	Key         string               `json:"key"`
+	Owner       string               `json:"owner"`
+	ContentType string               `json:"contentType"`
+	Size        int64                `json:"size"`
+	PartSize    int64                `json:"partSize"`
+	Parts       map[int]UploadedPart `json:"parts"`
+	CreatedAt   time.Time            `json:"createdAt"`
+	UpdatedAt   time.Time            `json:"updatedAt"`
+}
+
+func (u *uploadSession) totalParts() int {
+	if u.Size == 0 {
+		return 1
+	}
+	return int((u.Size + u.PartSize - 1) / u.PartSize)
+}
+
+// expectedPartSize is PartSize for every part except the last, which holds the remainder.
+func (u *uploadSession) expectedPartSize(number int) int64 {
+	if number < u.totalParts() {
+		return u.PartSize
+	}
+	return u.Size - int64(u.totalParts()-1)*u.PartSize
+}
+
+// offset is the number of bytes received contiguously from the start of the file.
+func (u *uploadSession) offset() int64 {
+	var offset int64
+	for n := 1; n <= u.totalParts(); n++ {
+		part, ok := u.Parts[n]
+		if !ok {
+			break
+		}
+		offset += part.Size
+	}
+	return offset
+}
+
+func (u *uploadSession) view(expiration time.Duration) gin.H {
+	numbers := make([]int, 0, len(u.Parts))
+	for n := range u.Parts {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+
+	return gin.H{
+		"id":          u.ID,
+		"filename":    u.Key,
+		"contentType": u.ContentType,
+		"size":        u.Size,
+		"partSize":    u.PartSize,
+		"totalParts":  u.totalParts(),
+		"parts":       numbers,
+		"offset":      u.offset(),
+		"createdAt":   u.CreatedAt,
+		"expiresAt":   u.UpdatedAt.Add(expiration),
+	}
+}
+
+// uploadSessionStore keeps upload sessions, persisted as JSON when path is set.
+type uploadSessionStore struct {
+	mu       sync.Mutex
+	path     string
+	sessions map[string]*uploadSession
+}
+
+var uploadSessions *uploadSessionStore
+
+func newUploadSessionStore(path string) (*uploadSessionStore, error) {
+	s := &uploadSessionStore{
+		path:     path,
+		sessions: make(map[string]*uploadSession),
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &s.sessions); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// get returns a copy so callers can inspect it without holding the lock.
+func (s *uploadSessionStore) get(id string) (uploadSession, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return uploadSession{}, false
+	}
+	return session.clone(), true
+}
+
+// clone copies a session including its parts; call it with the store locked.
+func (u *uploadSession) clone() uploadSession {
+	copied := *u
+	copied.Parts = make(map[int]UploadedPart, len(u.Parts))
+	for n, p := range u.Parts {
+		copied.Parts[n] = p
+	}
+	return copied
+}
+
+func (s *uploadSessionStore) put(session *uploadSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[session.ID] = session
+	return s.saveLocked()
+}
+
+func (s *uploadSessionStore) recordPart(id string, part UploadedPart) (uploadSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return uploadSession{}, ErrUploadNotFound
+	}
+	session.Parts[part.Number] = part
+	session.UpdatedAt = time.Now().UTC()
+	if err := s.saveLocked(); err != nil {
+		return uploadSession{}, err
+	}
+	return session.clone(), nil
+}
+
+func (s *uploadSessionStore) remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+	return s.saveLocked()
+}
+
+func (s *uploadSessionStore) expired(cutoff time.Time) []uploadSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []uploadSession
+	for _, session := range s.sessions {
+		if session.UpdatedAt.Before(cutoff) {
+			result = append(result, session.clone())
+		}
+	}
+	return result
+}
+
+func (s *uploadSessionStore) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+
+	raw, err := json.Marshal(s.sessions)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// resumableLimits configures the resumable upload protocol.
+type resumableLimits struct {
+	maxSize    int64
+	partSize   int64
+	expiration time.Duration
+}
+
+var resumable resumableLimits
+
+// minioMinPartSize is the smallest part S3 accepts for every part but the last.
+const minioMinPartSize = 5 << 20
+
+// loadResumableLimits reads the resumable upload settings for the blob store s.
+func loadResumableLimits(s BlobStore) (resumableLimits, error) {
+	var l resumableLimits
+	var err error
+	if l.maxSize, err = envSize("STORAGE_MAX_RESUMABLE_SIZE", 1<<30); err != nil {
+		return l, err
+	}
+	if l.partSize, err = envSize("STORAGE_UPLOAD_PART_SIZE", 8<<20); err != nil {
+		return l, err
+	}
+	if l.partSize <= 0 {
+		return l, errors.New("STORAGE_UPLOAD_PART_SIZE must be positive")
+	}
+	// MinIO would only reject smaller parts when the upload is completed.
+	if _, ok := s.(*minioStore); ok && l.partSize < minioMinPartSize {
+		return l, errors.New("STORAGE_UPLOAD_PART_SIZE must be at least 5MB with the minio driver")
+	}
+	l.expiration = 24 * time.Hour
+	if v := os.Getenv("STORAGE_UPLOAD_EXPIRATION"); v != "" {
+		if l.expiration, err = time.ParseDuration(v); err != nil {
+			return l, fmt.Errorf("invalid STORAGE_UPLOAD_EXPIRATION: %w", err)
+		}
+	}
+	return l, nil
+}
+
+func newRandomID() string {
+	id := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, id); err != nil {
+		panic("failed to generate upload id")
+	}
+	return hex.EncodeToString(id)
+}
+
+func partETag(data []byte) string {
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// newObjectName generates the key for a newly uploaded file in folder.
+func newObjectName(folder string) string {
+	return folder + fmt.Sprintf("file_%d", time.Now().UnixNano())
+}
+
+type startUploadRequest struct {
+	Folder      string `json:"folder"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"contentType" binding:"required"`
+}
+
+// startUpload opens a resumable upload session backed by a multipart upload.
+func startUpload(c *gin.Context) {
+	owner, err := resolveCaller(c)
+	if err != nil {
+		respondCallerError(c, err)
+		return
+	}
+
+	var req startUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Size < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "size must not be negative"})
+		return
+	}
+	if req.Size > resumable.maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("File exceeds the maximum upload size of %d bytes", resumable.maxSize)})
+		return
+	}
+
+	folder, err := cleanFolder(req.Folder)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	objectName := newObjectName(folder)
+
+	if !policy.allows(objectName, req.ContentType) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": fmt.Sprintf("Content type %s is not allowed in folder '%s'", req.ContentType, folder)})
+		return
+	}
+
+	if err := usage.reserve(owner.ID, objectName, req.Size, policy.userQuota); err != nil {
+		if errors.Is(err, ErrQuotaExceeded) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Storage quota of %d bytes exceeded", policy.userQuota)})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record usage: " + err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	uploadID, err := store.NewMultipartUpload(ctx, objectName, req.ContentType)
+	if err != nil {
+		usage.release(objectName)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start upload: " + err.Error()})
+		return
+	}
+
+	now := time.Now().UTC()
+	session := &uploadSession{
+		ID:          newRandomID(),
+		UploadID:    uploadID,
+		Key:         objectName,
+		Owner:       owner.ID,
+		ContentType: req.ContentType,
+		Size:        req.Size,
+		PartSize:    resumable.partSize,
+		Parts:       make(map[int]UploadedPart),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := uploadSessions.put(session); err != nil {
+		store.AbortMultipartUpload(ctx, objectName, uploadID)
+		usage.release(objectName)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save upload session: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, session.view(resumable.expiration))
+}
+
+// loadOwnedUpload fetches the session named in the URL and checks the caller owns it.
+func loadOwnedUpload(c *gin.Context) (uploadSession, bool) {
+	owner, err := resolveCaller(c)
+	if err != nil {
+		respondCallerError(c, err)
+		return uploadSession{}, false
+	}
+
+	session, ok := uploadSessions.get(c.Param("id"))
+	if !ok || session.Owner != owner.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return uploadSession{}, false
+	}
+
+	return session, true
+}
+
+// getUpload reports which parts have arrived and the contiguous byte offset.
+func getUpload(c *gin.Context) {
+	session, ok := loadOwnedUpload(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, session.view(resumable.expiration))
+}
+
+// putUploadPart stores one part; the request body is the raw part content.
+func putUploadPart(c *gin.Context) {
+	session, ok := loadOwnedUpload(c)
+	if !ok {
+		return
+	}
+
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil || number < 1 || number > session.totalParts() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Part number must be between 1 and %d", session.totalParts())})
+		return
+	}
+
+	expected := session.expectedPartSize(number)
+	if c.Request.ContentLength >= 0 && c.Request.ContentLength != expected {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Part %d must be exactly %d bytes", number, expected)})
+		return
+	}
+
+	var body io.Reader = http.MaxBytesReader(c.Writer, c.Request.Body, expected)
+
+	// The first part carries the magic bytes, so verify the declared type here.
+	if number == 1 {
+		sniffed, rest, err := sniffContentType(body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read part: " + err.Error()})
+			return
+		}
+		if !sameMediaType(sniffed, session.ContentType) || !policy.allows(session.Key, sniffed) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": fmt.Sprintf("File content is %s, not the declared %s", sniffed, session.ContentType)})
+			return
+		}
+		body = rest
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	part, err := store.PutPart(ctx, session.Key, session.UploadID, number, body, expected)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Part %d must be exactly %d bytes", number, expected)})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload part: " + err.Error()})
+		return
+	}
+	if part.Size != expected {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Part %d must be exactly %d bytes, got %d", number, expected, part.Size)})
+		return
+	}
+
+	updated, err := uploadSessions.recordPart(session.ID, part)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record part: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated.view(resumable.expiration))
+}
+
+// completeUpload assembles the parts into the final object.
+func completeUpload(c *gin.Context) {
+	session, ok := loadOwnedUpload(c)
+	if !ok {
+		return
+	}
+
+	parts := make([]UploadedPart, 0, session.totalParts())
+	var missing []int
+	for n := 1; n <= session.totalParts(); n++ {
+		part, ok := session.Parts[n]
+		if !ok {
+			missing = append(missing, n)
+			continue
+		}
+		parts = append(parts, part)
+	}
+	if len(missing) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload is incomplete", "missingParts": missing})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	info, err := store.CompleteMultipartUpload(ctx, session.Key, session.UploadID, parts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete upload: " + err.Error()})
+		return
+	}
+
+	if err := uploadSessions.remove(session.ID); err != nil {
+		log.Printf("Failed to remove upload session %s: %v", session.ID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "File uploaded successfully",
+		"filename":    info.Key,
+		"size":        info.Size,
+		"contentType": session.ContentType,
+		"url":         fmt.Sprintf("/download/%s", info.Key),
+	})
+}
+
+// abortUpload discards an upload session and its stored parts.
+func abortUpload(c *gin.Context) {
+	session, ok := loadOwnedUpload(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := discardUpload(ctx, session); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to abort upload: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Upload aborted",
+		"id":      session.ID,
+	})
+}
+
+func discardUpload(ctx context.Context, session uploadSession) error {
+	if err := store.AbortMultipartUpload(ctx, session.Key, session.UploadID); err != nil {
+		return err
+	}
+	if err := usage.release(session.Key); err != nil {
+		return err
+	}
+	return uploadSessions.remove(session.ID)
+}
+
+// cleanupExpiredUploads aborts sessions that have not received a part for the expiration period.
+func cleanupExpiredUploads(ctx context.Context, now time.Time) int {
+	removed := 0
+	for _, session := range uploadSessions.expired(now.Add(-resumable.expiration)) {
+		if err := discardUpload(ctx, session); err != nil {
+			log.Printf("Failed to clean up upload %s: %v", session.ID, err)
+			continue
+		}
+		removed++
+	}
+	return removed
+}
+
+// startUploadCleanup periodically removes abandoned uploads.
+func startUploadCleanup(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		for range ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+			if n := cleanupExpiredUploads(ctx, time.Now()); n > 0 {
+				log.Printf("Removed %d abandoned uploads", n)
+			}
+			cancel()
+		}
+	}()
+}
+
+func sameMediaType(a, b string) bool {
+	trim := func(s string) string {
+		return strings.ToLower(strings.TrimSpace(strings.Split(s, ";")[0]))
+	}
+	return trim(a) == trim(b)
+}
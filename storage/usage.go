@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// ErrQuotaExceeded is returned when an upload would push an owner past their quota.
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// objectOwner records who uploaded an object and how large it is.
+type objectOwner struct {
+	Owner string `json:"owner"`
+	Size  int64  `json:"size"`
+}
+
+// UserUsage summarises the storage used by one owner.
+type UserUsage struct {
+	Owner   string `json:"owner"`
+	Bytes   int64  `json:"bytes"`
+	Objects int    `json:"objects"`
+	Quota   int64  `json:"quota"`
+}
+
+// usageStore tracks object ownership for quota accounting. When path is set the
+// index is persisted as JSON after each change; otherwise it lives in memory only.
+type usageStore struct {
+	mu      sync.Mutex
+	path    string
+	objects map[string]objectOwner
+}
+
+var usage *usageStore
+
+func newUsageStore(path string) (*usageStore, error) {
+	u := &usageStore{
+		path:    path,
+		objects: make(map[string]objectOwner),
+	}
+	if path == "" {
+		return u, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return u, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &u.objects); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// reserve records key for owner if the owner's total stays within quota (0 = unlimited).
+func (u *usageStore) reserve(owner, key string, size, quota int64) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if quota > 0 {
+		var used int64
+		for k, o := range u.objects {
+			if o.Owner == owner && k != key {
+				used += o.Size
+			}
+		}
+		if used+size > quota {
+			return ErrQuotaExceeded
+		}
+	}
+
+	u.objects[key] = objectOwner{Owner: owner, Size: size}
+	return u.saveLocked()
+}
+
+// release forgets key, e.g. after a failed upload or a delete.
+func (u *usageStore) release(key string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if _, ok := u.objects[key]; !ok {
+		return nil
+	}
+	delete(u.objects, key)
+	return u.saveLocked()
+}
+
+// ownerOf returns who uploaded key, if the index knows.
+func (u *usageStore) ownerOf(key string) (string, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	o, ok := u.objects[key]
+	return o.Owner, ok
+}
+
+func (u *usageStore) usageFor(owner string, quota int64) UserUsage {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	result := UserUsage{Owner: owner, Quota: quota}
+	for _, o := range u.objects {
+		if o.Owner == owner {
+			result.Bytes += o.Size
+			result.Objects++
+		}
+	}
+	return result
+}
+
+func (u *usageStore) all(quota int64) []UserUsage {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	byOwner := make(map[string]*UserUsage)
+	for _, o := range u.objects {
+		entry, ok := byOwner[o.Owner]
+		if !ok {
+			entry = &UserUsage{Owner: o.Owner, Quota: quota}
+			byOwner[o.Owner] = entry
+		}
+		entry.Bytes += o.Size
+		entry.Objects++
+	}
+
+	result := make([]UserUsage, 0, len(byOwner))
+	for _, entry := range byOwner {
+		result = append(result, *entry)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Bytes > result[j].Bytes })
+
+	return result
+}
+
+func (u *usageStore) saveLocked() error {
+	if u.path == "" {
+		return nil
+	}
+
+	raw, err := json.Marshal(u.objects)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(u.path), 0o755); err != nil {
+		return err
+	}
+
+	tmp := u.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, u.path)
+}
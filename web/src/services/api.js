@@ -8,10 +8,69 @@ const api = axios.create({
   }
 })
 
+// State-changing requests from a logged-in browser must echo the session's CSRF
+// token. It changes whenever the session does, so on rejection we refetch it and
+// retry once.
+const CSRF_ERROR = 'invalid or missing CSRF token'
+const SAFE_METHODS = ['get', 'head', 'options']
+let csrfToken = null
+
+async function refreshCsrfToken() {
+  try {
+    const response = await api.get('/users/csrf')
+    csrfToken = response.data.csrfToken
+  } catch {
+    // Not logged in (the stale cookie has now been cleared), so no token is needed.
+    csrfToken = null
+  }
+}
+
+api.interceptors.request.use((config) => {
+  if (csrfToken && !SAFE_METHODS.includes((config.method || 'get').toLowerCase())) {
+    config.headers['X-CSRF-Token'] = csrfToken
+  }
+  return config
+})
+
+api.interceptors.response.use(undefined, async (error) => {
+  const { config, response } = error
+  if (response?.status === 403 && response.data?.error === CSRF_ERROR && config && !config._csrfRetried) {
+    config._csrfRetried = true
+    await refreshCsrfToken()
+    if (csrfToken) {
+      config.headers['X-CSRF-Token'] = csrfToken
+    } else {
+      delete config.headers['X-CSRF-Token']
+    }
+    return api.request(config)
+  }
+  return Promise.reject(error)
+})
+
+/**
+ * Fetch every item of a paginated list endpoint by following nextCursor.
+ * @param {string} path e.g. '/characters'
+ * @param {{ sort?: string, fields?: string }} params
+ */
+async function listAll(path, params = {}) {
+  const items = []
+  let cursor
+  do {
+    const response = await api.get(path, {
+      params: { ...params, limit: 200, ...(cursor ? { cursor } : {}) },
+      // Repeat array params as tag=a&tag=b rather than tag[]=a&tag[]=b.
+      paramsSerializer: { indexes: null }
+    })
+    items.push(...response.data.items)
+    cursor = response.data.nextCursor
+  } while (cursor)
+  return items
+}
+
 export const characterService = {
-  async getCharacters() {
-    const response = await api.get('/characters')
-    return response.data
+  /** @param {{ tag?: string|string[] }} params */
+  async getCharacters(params = {}) {
+    return listAll('/characters', params)
   },
 
   /**
@@ -27,7 +86,12 @@ export const characterService = {
   },
 
   /**
-   * @param {{ edition?: string, name?: string, characterIds?: string|string[] }} params
+   * Filters are ANDed unless match is 'any'. skill is 'Name' or 'Name:+3' (at or above);
+   * filter is a JSON expression such as '{"or":[{"skill":"Воля:+3"},{"aspect":"вуду"}]}'.
+   * @param {{ edition?: string, name?: string, characterIds?: string|string[], aspect?: string,
+   *   skill?: string, stunt?: string, libraryStunt?: string, creator?: string, category?: string, published?: boolean,
+   *   createdAfter?: string, createdBefore?: string, updatedAfter?: string, updatedBefore?: string,
+   *   freeConsequence?: string, tag?: string|string[], match?: 'all'|'any', filter?: string }} params
    */
   async findCharacters(params = {}) {
     const response = await api.get('/characters/find', { params })
@@ -49,15 +113,72 @@ export const characterService = {
     return response.data
   },
 
+  /**
+   * Stunts of the character that apply to a roll, usable ones first.
+   * @param {{ skill: string, action?: 'overcome'|'createAdvantage'|'attack'|'defend' }} params
+   */
+  async applicableStunts(id, params) {
+    const response = await api.get(`/characters/${id}/stunts/applicable`, { params })
+    return response.data
+  },
+
+  /** Records a use of the stunt at index and spends its fate point cost. */
+  async useStunt(id, index) {
+    const response = await api.post(`/characters/${id}/stunts/use/${index}`)
+    return response.data
+  },
+
+  /** @param {'scene'|'session'} scope */
+  async resetStuntUses(id, scope) {
+    const response = await api.post(`/characters/${id}/stunts/reset`, { scope })
+    return response.data
+  },
+
+  /** Creates a character on the server from a template, optionally naming it. */
+  async createFromTemplate(templateId, name) {
+    const response = await api.post(`/characters/from-template/${templateId}`, name ? { name } : undefined)
+    return response.data
+  },
+
   async getTemplates() {
-    const response = await api.get('/templates')
+    return listAll('/templates')
+  }
+}
+
+export const templateService = {
+  async list() {
+    return listAll('/templates')
+  },
+  async get(id) {
+    const response = await api.get(`/templates/${id}`)
+    return response.data
+  },
+  /** Earlier versions of the template, newest first. */
+  async versions(id) {
+    const response = await api.get(`/templates/${id}/versions`)
+    return response.data
+  },
+  /** @param {object} body a character sheet with a title; shared: true needs templates:write */
+  async create(body) {
+    const response = await api.post('/templates/create', body)
+    return response.data
+  },
+  async update(id, body) {
+    const response = await api.post(`/templates/update/${id}`, body)
+    return response.data
+  },
+  async remove(id) {
+    const response = await api.delete(`/templates/delete/${id}`)
     return response.data
   }
 }
 
 export const categoryService = {
   async list() {
-    const response = await api.get('/categories')
+    return listAll('/categories')
+  },
+  async tree() {
+    const response = await api.get('/categories/tree')
     return response.data
   },
   async create(body) {
@@ -68,6 +189,22 @@ export const categoryService = {
     const response = await api.post(`/categories/update/${id}`, body)
     return response.data
   },
+  async move(id, parentId, position) {
+    const response = await api.post(`/categories/move/${id}`, { parentId, position })
+    return response.data
+  },
+  async reorder(parentId, order) {
+    const response = await api.post('/categories/reorder', { parentId, order })
+    return response.data
+  },
+  async addCharacters(id, characterIds) {
+    const response = await api.post(`/categories/characters/add/${id}`, { characterIds })
+    return response.data
+  },
+  async removeCharacters(id, characterIds) {
+    const response = await api.post(`/categories/characters/remove/${id}`, { characterIds })
+    return response.data
+  },
   async remove(id) {
     const response = await api.delete(`/categories/delete/${id}`)
     return response.data
@@ -75,9 +212,9 @@ export const categoryService = {
 }
 
 export const gameService = {
-  async list() {
-    const response = await api.get('/games')
-    return response.data
+  /** @param {{ tag?: string|string[] }} params */
+  async list(params = {}) {
+    return listAll('/games', params)
   },
   async create(body) {
     const response = await api.post('/games/create', body)
@@ -94,16 +231,32 @@ export const gameService = {
 }
 
 export const stuntService = {
-  async list() {
-    const response = await api.get('/stunts')
-    return response.data
+  /**
+   * @param {{ tag?: string|string[], edition?: string, skill?: string,
+   *   action?: 'overcome'|'createAdvantage'|'attack'|'defend' }} params
+   */
+  async list(params = {}) {
+    return listAll('/stunts', params)
   },
   async create(body) {
     const response = await api.post('/stunts/create', body)
     return response.data
   },
-  async update(id, body) {
-    const response = await api.post(`/stunts/update/${id}`, body)
+  /** With propagate, character sheets linking to the stunt get the new text unless they override it. */
+  async update(id, body, { propagate = false } = {}) {
+    const response = await api.post(`/stunts/update/${id}`, body, { params: propagate ? { propagate: true } : {} })
+    return response.data
+  },
+  /** Characters that use a library stunt. */
+  async characters(id) {
+    return listAll(`/stunts/${id}/characters`)
+  },
+  /**
+   * Copies one of a character's own stunts into the library and links it.
+   * @param {{ characterId: string, index: number, skill?: string, action?: string, tags?: string[] }} body
+   */
+  async promote(body) {
+    const response = await api.post('/stunts/promote', body)
     return response.data
   },
   async remove(id) {
@@ -112,9 +265,27 @@ export const stuntService = {
   }
 }
 
+export const tagService = {
+  /**
+   * Tags starting with q, most used first.
+   * @param {{ q?: string, type?: string, limit?: number }} params type is a comma-separated
+   *   list of character, stunt and game.
+   * @returns {Promise<{ tag: string, count: number }[]>}
+   */
+  async suggest(params = {}) {
+    const response = await api.get('/tags', { params })
+    return response.data
+  },
+  /** Renames a tag everywhere; renaming onto an existing tag merges them. */
+  async rename(from, to) {
+    const response = await api.post('/tags/rename', { from, to })
+    return response.data
+  }
+}
+
 export const userService = {
   /**
-   * @param {{ username: string, password: string, role?: string }} body
+   * @param {{ username: string, password: string, inviteCode?: string }} body
    * @returns {Promise<{ user: object }>} Sets HttpOnly session cookie on success.
    */
   async register(body) {
@@ -124,6 +295,8 @@ export const userService = {
 
   /**
    * Login with username/password. Session is the HttpOnly cookie; response body is `{ user }` only.
+   * Accounts with two-factor authentication get `{ twoFactorRequired: true, challenge }` instead;
+   * pass the challenge to completeLogin.
    * @param {{ username: string, password: string }} body
    */
   async auth(body) {
@@ -131,6 +304,17 @@ export const userService = {
     return response.data
   },
 
+  /**
+   * Second login step for two-factor accounts. After an OIDC login that redirected back with
+   * `twoFactorRequired=true`, leave out `challenge`: it is in an HttpOnly cookie.
+   * @param {{ challenge?: string, code?: string, recoveryCode?: string }} body
+   * @returns {Promise<{ user: object }>} Sets HttpOnly session cookie on success.
+   */
+  async completeLogin(body) {
+    const response = await api.post('/users/auth/2fa', body)
+    return response.data
+  },
+
   /**
    * Returns current authenticated user via middleware-protected route.
    */
@@ -150,5 +334,66 @@ export const userService = {
   async update(id, body) {
     const response = await api.post(`/users/update/${id}`, body)
     return response.data
+  },
+
+  /**
+   * Sets the locale sheets are shown in; an empty string follows the browser again.
+   * @param {'en'|'ru'|''} locale
+   */
+  async setLocale(locale) {
+    const response = await api.post('/users/me/locale', { locale })
+    return response.data
+  }
+}
+
+export const vocabularyService = {
+  /**
+   * Skills, approaches and aspect, stress and consequence types with their names
+   * in the user's locale, or in the given one.
+   * @param {string} [locale]
+   */
+  async get(locale) {
+    const response = await api.get('/vocabulary', { params: locale ? { locale } : {} })
+    return response.data
+  }
+}
+
+export const searchService = {
+  /**
+   * Full-text search over characters, stunts and games, best matches first.
+   * @param {string} q
+   * @param {{ types?: string, limit?: number }} params types is e.g. 'character,stunt'
+   * @returns {Promise<{ query: string, hits: Array<{ type: string, id: string, name: string, snippet?: string, score: number }> }>}
+   */
+  async search(q, params = {}) {
+    const response = await api.get('/search', { params: { q, ...params } })
+    return response.data
+  }
+}
+
+export const savedSearchService = {
+  /** Own saved searches plus those shared through games. */
+  async list() {
+    const response = await api.get('/searches')
+    return response.data
+  },
+  /**
+   * @param {{ name: string, query: string, gameIds?: string[] }} body query uses /characters/find parameters
+   */
+  async create(body) {
+    const response = await api.post('/searches/create', body)
+    return response.data
+  },
+  async update(id, body) {
+    const response = await api.post(`/searches/update/${id}`, body)
+    return response.data
+  },
+  async remove(id) {
+    const response = await api.delete(`/searches/delete/${id}`)
+    return response.data
+  },
+  /** Current results of a saved search. */
+  async characters(id) {
+    return listAll('/characters', { savedSearch: id })
   }
 }